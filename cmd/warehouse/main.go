@@ -8,9 +8,16 @@ import (
 
 func main() {
 	var path string
+	var workerMode bool
 	flag.StringVar(&path, "path", "", "config file dir")
+	flag.BoolVar(&workerMode, "worker", false, "consume script runs queue instead of serving http")
 	flag.Parse()
 
 	application := app.NewApplication(path)
+	if workerMode {
+		application.RunWorker()
+		return
+	}
+
 	application.Run()
 }
@@ -0,0 +1,73 @@
+package blob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+
+	"github.com/warehouse/ai-service/internal/config"
+
+	"github.com/rs/xid"
+)
+
+const (
+	LocalStorage = "local"
+	S3Storage    = "s3"
+)
+
+// ErrNotFound объекта с таким ключом нет в хранилище
+var ErrNotFound = errors.New("blob not found")
+
+// ErrLinkInvalid ссылка на скачивание не подписана сервисом или истекла
+var ErrLinkInvalid = errors.New("blob download link is invalid or expired")
+
+// ключ - xid и случайный суффикс, чтобы ссылку на скачивание нельзя было подобрать, плюс расширение по mime
+var keyRegexp = regexp.MustCompile(`^[0-9a-v]{20}-[0-9a-f]{16}(\.[0-9a-z]+)?$`)
+
+type (
+	// Adapter хранилище бинарных результатов нод. Дальше по сценарию результат передается ключом
+	Adapter interface {
+		Put(ctx context.Context, data []byte, mime string) (string, error)
+		Get(ctx context.Context, key string) ([]byte, string, error)
+		DownloadURL(ctx context.Context, key string) (string, error)
+		// CheckLink проверяет подпись и срок ссылки, которую сервис отдает сам (локальное хранилище)
+		CheckLink(key, expires, signature string) error
+	}
+)
+
+func NewAdapter(cfg config.Blob) (Adapter, error) {
+	switch cfg.Storage {
+	case "", LocalStorage:
+		return newLocalAdapter(cfg)
+	case S3Storage:
+		return newS3Adapter(cfg)
+	default:
+		return nil, fmt.Errorf("unknown blob storage %s", cfg.Storage)
+	}
+}
+
+func newKey(mimeType string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	key := fmt.Sprintf("%s-%s", xid.New().String(), hex.EncodeToString(suffix))
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) != 0 {
+		key += exts[0]
+	}
+
+	return key, nil
+}
+
+func validateKey(key string) error {
+	if !keyRegexp.MatchString(key) {
+		return fmt.Errorf("invalid blob key %s: %w", key, ErrNotFound)
+	}
+
+	return nil
+}
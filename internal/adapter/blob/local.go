@@ -0,0 +1,112 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+)
+
+const defaultLinkTTL = 24 * time.Hour
+
+// localAdapter хранит файлы в каталоге, скачиваются они через сам сервис по подписанной ссылке с ограниченным
+// сроком. Каталог должен быть общим для api и воркера, поэтому хранилище только для локальной разработки
+type localAdapter struct {
+	dir       string
+	publicURL string
+	signKey   []byte
+	urlTTL    time.Duration
+}
+
+func newLocalAdapter(cfg config.Blob) (Adapter, error) {
+	if cfg.SignKey == "" {
+		return nil, fmt.Errorf("local blob storage: sign_key is required")
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+
+	a := &localAdapter{
+		dir:       cfg.Dir,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+		signKey:   []byte(cfg.SignKey),
+		urlTTL:    cfg.UrlTTL,
+	}
+	if a.urlTTL <= 0 {
+		a.urlTTL = defaultLinkTTL
+	}
+
+	return a, nil
+}
+
+func (a *localAdapter) Put(_ context.Context, data []byte, mimeType string) (string, error) {
+	key, err := newKey(mimeType)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(a.dir, key), data, 0o640); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Get mime определяется по расширению ключа
+func (a *localAdapter) Get(_ context.Context, key string) ([]byte, string, error) {
+	if err := validateKey(key); err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(filepath.Join(a.dir, key))
+	if os.IsNotExist(err) {
+		return nil, "", fmt.Errorf("blob %s: %w", key, ErrNotFound)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, mime.TypeByExtension(filepath.Ext(key)), nil
+}
+
+func (a *localAdapter) DownloadURL(_ context.Context, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(a.urlTTL).Unix(), 10)
+	return fmt.Sprintf("%s/script/blob/%s?expires=%s&signature=%s", a.publicURL, key, expires, a.sign(key, expires)), nil
+}
+
+func (a *localAdapter) CheckLink(key, expires, signature string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrLinkInvalid
+	}
+
+	if !hmac.Equal([]byte(a.sign(key, expires)), []byte(signature)) {
+		return ErrLinkInvalid
+	}
+
+	return nil
+}
+
+func (a *localAdapter) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, a.signKey)
+	fmt.Fprintf(mac, "%s\n%s", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,261 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4Service     = "s3"
+	sigV4DateFormat  = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	defaultS3Region  = "ru-central1"
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// s3Adapter S3-совместимое хранилище, запросы подписываются AWS Signature V4 с path-style адресами объектов
+type s3Adapter struct {
+	client *http.Client
+
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	urlTTL    time.Duration
+}
+
+func newS3Adapter(cfg config.Blob) (Adapter, error) {
+	if cfg.S3.Bucket == "" || cfg.S3.AccessKey == "" || cfg.S3.SecretKey == "" {
+		return nil, fmt.Errorf("s3 blob storage: bucket and credentials are required")
+	}
+
+	a := &s3Adapter{
+		client:    &http.Client{},
+		endpoint:  cfg.S3.Endpoint,
+		region:    cfg.S3.Region,
+		bucket:    cfg.S3.Bucket,
+		accessKey: cfg.S3.AccessKey,
+		secretKey: cfg.S3.SecretKey,
+		urlTTL:    cfg.UrlTTL,
+	}
+	if a.endpoint == "" {
+		a.endpoint = domain.S3Endpoint
+	}
+	if a.region == "" {
+		a.region = defaultS3Region
+	}
+	// подписанная ссылка не может жить дольше недели
+	if a.urlTTL <= 0 || a.urlTTL > maxPresignExpiry {
+		a.urlTTL = maxPresignExpiry
+	}
+
+	return a, nil
+}
+
+func (a *s3Adapter) objectURL(key string) *url.URL {
+	return &url.URL{
+		Scheme: "https",
+		Host:   a.endpoint,
+		Path:   fmt.Sprintf("/%s/%s", a.bucket, key),
+	}
+}
+
+func (a *s3Adapter) Put(ctx context.Context, data []byte, mimeType string) (string, error) {
+	key, err := newKey(mimeType)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.objectURL(key).String(), bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	if mimeType != "" {
+		req.Header.Set(domain.HeaderContentType, mimeType)
+	}
+	a.sign(req, data, time.Now())
+
+	if _, err := a.do(req); err != nil {
+		return "", fmt.Errorf("put blob %s: %w", key, err)
+	}
+
+	return key, nil
+}
+
+func (a *s3Adapter) Get(ctx context.Context, key string) ([]byte, string, error) {
+	if err := validateKey(key); err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	a.sign(req, nil, time.Now())
+
+	res, err := a.do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("get blob %s: %w", key, err)
+	}
+
+	return res.body, res.mime, nil
+}
+
+// DownloadURL подписанная ссылка на объект, доступная без авторизации до истечения urlTTL
+func (a *s3Adapter) DownloadURL(_ context.Context, key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	u := a.objectURL(key)
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", a.accessKey+"/"+a.scope(now))
+	query.Set("X-Amz-Date", now.Format(sigV4DateFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(a.urlTTL.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	u.RawQuery = canonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", a.signature(now, canonicalRequest))
+	u.RawQuery = canonicalQuery(query)
+
+	return u.String(), nil
+}
+
+type s3Response struct {
+	body []byte
+	mime string
+}
+
+func (a *s3Adapter) do(req *http.Request) (s3Response, error) {
+	res, err := a.client.Do(req)
+	if err != nil {
+		return s3Response{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return s3Response{}, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		return s3Response{}, ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return s3Response{}, fmt.Errorf("storage responded with status %d: %s", res.StatusCode, string(body))
+	}
+
+	return s3Response{body: body, mime: res.Header.Get(domain.HeaderContentType)}, nil
+}
+
+// sign подписывает запрос заголовком Authorization, подписываются host, content-type и x-amz-* заголовки
+func (a *s3Adapter) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", now.Format(sigV4DateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(req.Header.Get(name))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	req.Header.Set(domain.HeaderAuth, fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, a.accessKey, a.scope(now), signedHeaders, a.signature(now, canonicalRequest),
+	))
+}
+
+func (a *s3Adapter) scope(now time.Time) string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", now.Format("20060102"), a.region, sigV4Service)
+}
+
+func (a *s3Adapter) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		now.Format(sigV4DateFormat),
+		a.scope(now),
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+a.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, a.region)
+	key = hmacSHA256(key, sigV4Service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// canonicalQuery параметры отсортированы по имени и закодированы по RFC 3986
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// CheckLink объекты s3 скачиваются по подписанным ссылкам прямо из хранилища, через сервис они не отдаются
+func (a *s3Adapter) CheckLink(_, _, _ string) error {
+	return ErrLinkInvalid
+}
@@ -0,0 +1,33 @@
+package breaker
+
+import (
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	breakersRepo "github.com/warehouse/ai-service/internal/repository/operations/breakers"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+const (
+	LocalStorage    = "local"
+	PostgresStorage = "postgres"
+)
+
+// NewRegistry local - состояние в памяти процесса, postgres - общее для api и всех воркеров
+func NewRegistry(cfg config.Breaker, txRepo transactions.Repository, repo breakersRepo.Repository) (breaker.Registry, error) {
+	settings := breaker.Settings{
+		FailureThreshold: cfg.FailureThreshold,
+		OpenTimeout:      cfg.OpenTimeout,
+		HalfOpenMaxCalls: cfg.HalfOpenMaxCalls,
+	}
+
+	switch cfg.Storage {
+	case "", LocalStorage:
+		return breaker.NewRegistry(settings, breaker.NewMemoryStore()), nil
+	case PostgresStorage:
+		return breaker.NewRegistry(settings, newPostgresStore(txRepo, repo)), nil
+	default:
+		return nil, fmt.Errorf("unknown breaker storage %s", cfg.Storage)
+	}
+}
@@ -0,0 +1,90 @@
+package breaker
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	breakersRepo "github.com/warehouse/ai-service/internal/repository/operations/breakers"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// postgresStore брейкеры в таблице node_breakers: ошибки ноды считаются по всем воркерам,
+// а api показывает то же состояние, по которому воркеры отклоняют вызовы
+type postgresStore struct {
+	txRepo transactions.Repository
+	repo   breakersRepo.Repository
+}
+
+func newPostgresStore(txRepo transactions.Repository, repo breakersRepo.Repository) *postgresStore {
+	return &postgresStore{
+		txRepo: txRepo,
+		repo:   repo,
+	}
+}
+
+func (s *postgresStore) Update(ctx context.Context, key string, change func(record *breaker.Record)) error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	model, err := s.repo.Lock(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+
+	record := toRecord(model)
+	change(&record)
+
+	if err := s.repo.Save(ctx, tx, toModel(record)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *postgresStore) List(ctx context.Context) ([]breaker.Record, error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	list, err := s.repo.GetAll(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]breaker.Record, len(list))
+	for i, model := range list {
+		records[i] = toRecord(model)
+	}
+
+	return records, nil
+}
+
+func toRecord(model models.NodeBreaker) breaker.Record {
+	return breaker.Record{
+		Key:      model.Key,
+		State:    breaker.State(model.State),
+		Failures: model.Failures,
+		OpenedAt: model.OpenedAt,
+		InFlight: model.InFlight,
+		Passed:   model.Passed,
+		ProbedAt: model.ProbedAt,
+	}
+}
+
+func toModel(record breaker.Record) models.NodeBreaker {
+	return models.NodeBreaker{
+		Key:      record.Key,
+		State:    string(record.State),
+		Failures: record.Failures,
+		OpenedAt: record.OpenedAt,
+		InFlight: record.InFlight,
+		Passed:   record.Passed,
+		ProbedAt: record.ProbedAt,
+	}
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	responseCacheRepo "github.com/warehouse/ai-service/internal/repository/operations/responsecache"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+const (
+	LocalStorage    = "local"
+	PostgresStorage = "postgres"
+
+	defaultMaxEntries    = 1000
+	defaultMaxEntryBytes = 1 << 20
+)
+
+type (
+	// Adapter кеш успешных ответов нод. Просроченные ответы не возвращаются
+	Adapter interface {
+		Get(ctx context.Context, key string) (domain.CachedResponse, bool, error)
+		Put(ctx context.Context, entry domain.CachedResponse) error
+	}
+)
+
+func NewAdapter(cfg config.Cache, txRepo transactions.Repository, repo responseCacheRepo.Repository) (Adapter, error) {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = defaultMaxEntries
+	}
+	if cfg.MaxEntryBytes <= 0 {
+		cfg.MaxEntryBytes = defaultMaxEntryBytes
+	}
+
+	switch cfg.Storage {
+	case "", LocalStorage:
+		return newLocalAdapter(cfg), nil
+	case PostgresStorage:
+		return newPostgresAdapter(cfg, txRepo, repo), nil
+	default:
+		return nil, fmt.Errorf("unknown cache storage %s", cfg.Storage)
+	}
+}
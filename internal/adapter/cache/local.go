@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// localAdapter кеш в памяти процесса. При переполнении вытесняется ответ, который дольше всех не запрашивали
+type localAdapter struct {
+	cfg config.Cache
+
+	mu      sync.Mutex
+	order   *list.List // от недавно использованных к давно использованным
+	entries map[string]*list.Element
+}
+
+func newLocalAdapter(cfg config.Cache) Adapter {
+	return &localAdapter{
+		cfg:     cfg,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (a *localAdapter) Get(_ context.Context, key string) (domain.CachedResponse, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	el, ok := a.entries[key]
+	if !ok {
+		return domain.CachedResponse{}, false, nil
+	}
+
+	entry := el.Value.(domain.CachedResponse)
+	if !entry.ExpiresAt.After(time.Now()) {
+		a.remove(el)
+		return domain.CachedResponse{}, false, nil
+	}
+
+	a.order.MoveToFront(el)
+	return entry, true, nil
+}
+
+func (a *localAdapter) Put(_ context.Context, entry domain.CachedResponse) error {
+	if entry.Size() > a.cfg.MaxEntryBytes {
+		return nil
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if el, ok := a.entries[entry.Key]; ok {
+		el.Value = entry
+		a.order.MoveToFront(el)
+		return nil
+	}
+
+	a.entries[entry.Key] = a.order.PushFront(entry)
+	for a.order.Len() > a.cfg.MaxEntries {
+		a.remove(a.order.Back())
+	}
+
+	return nil
+}
+
+func (a *localAdapter) remove(el *list.Element) {
+	a.order.Remove(el)
+	delete(a.entries, el.Value.(domain.CachedResponse).Key)
+}
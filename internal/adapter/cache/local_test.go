@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestLocalAdapter(t *testing.T) {
+	ctx := context.Background()
+	a, err := NewAdapter(config.Cache{MaxEntries: 2, MaxEntryBytes: 8}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewAdapter() unexpected error: %v", err)
+	}
+
+	entry := func(key, body string, ttl time.Duration) domain.CachedResponse {
+		return domain.CachedResponse{Key: key, Body: []byte(body), ExpiresAt: time.Now().Add(ttl)}
+	}
+	cached := func(key string) bool {
+		_, ok, err := a.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%s) unexpected error: %v", key, err)
+		}
+		return ok
+	}
+
+	_ = a.Put(ctx, entry("a", "a", time.Minute))
+	_ = a.Put(ctx, entry("b", "b", time.Minute))
+	// a запрошен позже b, при переполнении вытесняется b
+	if !cached("a") {
+		t.Fatalf("entry a is not cached")
+	}
+	_ = a.Put(ctx, entry("c", "c", time.Minute))
+	if cached("b") || !cached("a") || !cached("c") {
+		t.Fatalf("want the least recently used entry b to be evicted")
+	}
+
+	_ = a.Put(ctx, entry("big", "more than 8 bytes", time.Minute))
+	if cached("big") || !cached("a") {
+		t.Fatalf("want the entry over max_entry_bytes to be skipped without evictions")
+	}
+
+	_ = a.Put(ctx, entry("expired", "x", -time.Second))
+	if cached("expired") {
+		t.Fatalf("want the expired entry to be missed")
+	}
+}
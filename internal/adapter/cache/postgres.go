@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	responseCacheRepo "github.com/warehouse/ai-service/internal/repository/operations/responsecache"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// postgresAdapter хранит ответы в таблице node_response_cache, кеш общий для всех инстансов сервиса.
+// При переполнении вытесняются самые старые ответы
+type postgresAdapter struct {
+	cfg    config.Cache
+	txRepo transactions.Repository
+	repo   responseCacheRepo.Repository
+}
+
+func newPostgresAdapter(cfg config.Cache, txRepo transactions.Repository, repo responseCacheRepo.Repository) Adapter {
+	return &postgresAdapter{
+		cfg:    cfg,
+		txRepo: txRepo,
+		repo:   repo,
+	}
+}
+
+func (a *postgresAdapter) Get(ctx context.Context, key string) (domain.CachedResponse, bool, error) {
+	tx, err := a.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.CachedResponse{}, false, err
+	}
+	defer tx.Rollback()
+
+	res, err := a.repo.Get(ctx, tx, key)
+	if errors.Is(err, responseCacheRepo.ErrNotFound) {
+		return domain.CachedResponse{}, false, nil
+	}
+	if err != nil {
+		return domain.CachedResponse{}, false, err
+	}
+
+	entry, err := domain.CachedResponse{}.FromModel(res)
+	if err != nil {
+		return domain.CachedResponse{}, false, err
+	}
+
+	return entry, true, nil
+}
+
+func (a *postgresAdapter) Put(ctx context.Context, entry domain.CachedResponse) error {
+	if entry.Size() > a.cfg.MaxEntryBytes {
+		return nil
+	}
+
+	tx, err := a.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	modelEntry, err := entry.ToModel()
+	if err != nil {
+		return err
+	}
+
+	if err := a.repo.Save(ctx, tx, modelEntry); err != nil {
+		return err
+	}
+
+	if err := a.repo.Prune(ctx, tx, a.cfg.MaxEntries); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,39 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	cassettesRepo "github.com/warehouse/ai-service/internal/repository/operations/cassettes"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+const (
+	LocalStorage    = "local"
+	PostgresStorage = "postgres"
+)
+
+// ErrNotFound в кассете нет ответа на такой запрос
+var ErrNotFound = errors.New("cassette entry not found")
+
+type (
+	// Adapter хранилище кассет с записанными ответами нод
+	Adapter interface {
+		Get(ctx context.Context, owner, cassette, nodeId, requestHash string) (domain.CassetteEntry, error)
+		Save(ctx context.Context, entry domain.CassetteEntry) error
+	}
+)
+
+func NewAdapter(cfg config.Cassette, txRepo transactions.Repository, repo cassettesRepo.Repository) (Adapter, error) {
+	switch cfg.Storage {
+	case "", LocalStorage:
+		return newLocalAdapter(cfg)
+	case PostgresStorage:
+		return newPostgresAdapter(txRepo, repo), nil
+	default:
+		return nil, fmt.Errorf("unknown cassette storage %s", cfg.Storage)
+	}
+}
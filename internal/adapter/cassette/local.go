@@ -0,0 +1,103 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+type (
+	// localAdapter хранит кассету каталогом, каждый ответ - json файл <владелец>/<кассета>/<нода>/<хеш запроса>.json,
+	// такие кассеты удобно класть в репозиторий рядом с тестами и демо
+	localAdapter struct {
+		dir string
+	}
+
+	localEntry struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"headers"`
+		Body       []byte      `json:"body"`
+		RecordedAt time.Time   `json:"recorded_at"`
+	}
+)
+
+func newLocalAdapter(cfg config.Cassette) (Adapter, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create cassette dir: %w", err)
+	}
+
+	return &localAdapter{dir: cfg.Dir}, nil
+}
+
+func (a *localAdapter) Get(_ context.Context, owner, cassette, nodeId, requestHash string) (domain.CassetteEntry, error) {
+	path, err := a.path(owner, cassette, nodeId, requestHash)
+	if err != nil {
+		return domain.CassetteEntry{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return domain.CassetteEntry{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.CassetteEntry{}, err
+	}
+
+	var entry localEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return domain.CassetteEntry{}, fmt.Errorf("cassette file %s: %w", path, err)
+	}
+
+	return domain.CassetteEntry{
+		Owner:       owner,
+		Cassette:    cassette,
+		NodeId:      nodeId,
+		RequestHash: requestHash,
+		StatusCode:  entry.StatusCode,
+		Header:      entry.Header,
+		Body:        entry.Body,
+		RecordedAt:  entry.RecordedAt,
+	}, nil
+}
+
+func (a *localAdapter) Save(_ context.Context, entry domain.CassetteEntry) error {
+	path, err := a.path(entry.Owner, entry.Cassette, entry.NodeId, entry.RequestHash)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(localEntry{
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header,
+		Body:       entry.Body,
+		RecordedAt: entry.RecordedAt,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o640)
+}
+
+// path части пути приходят из запуска и базы, но из каталога кассет выйти все равно нельзя
+func (a *localAdapter) path(owner, cassette, nodeId, requestHash string) (string, error) {
+	for _, part := range []string{owner, cassette, nodeId, requestHash} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid cassette path part %q", part)
+		}
+	}
+
+	return filepath.Join(a.dir, owner, cassette, nodeId, requestHash+".json"), nil
+}
@@ -0,0 +1,60 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	cassettesRepo "github.com/warehouse/ai-service/internal/repository/operations/cassettes"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// postgresAdapter хранит кассеты в таблице cassette_entries, кассета доступна всем инстансам сервиса
+type postgresAdapter struct {
+	txRepo transactions.Repository
+	repo   cassettesRepo.Repository
+}
+
+func newPostgresAdapter(txRepo transactions.Repository, repo cassettesRepo.Repository) Adapter {
+	return &postgresAdapter{
+		txRepo: txRepo,
+		repo:   repo,
+	}
+}
+
+func (a *postgresAdapter) Get(ctx context.Context, owner, cassette, nodeId, requestHash string) (domain.CassetteEntry, error) {
+	tx, err := a.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.CassetteEntry{}, err
+	}
+	defer tx.Rollback()
+
+	res, err := a.repo.Get(ctx, tx, owner, cassette, nodeId, requestHash)
+	if errors.Is(err, cassettesRepo.ErrNotFound) {
+		return domain.CassetteEntry{}, ErrNotFound
+	}
+	if err != nil {
+		return domain.CassetteEntry{}, err
+	}
+
+	return domain.CassetteEntry{}.FromModel(res)
+}
+
+func (a *postgresAdapter) Save(ctx context.Context, entry domain.CassetteEntry) error {
+	tx, err := a.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	modelEntry, err := entry.ToModel()
+	if err != nil {
+		return err
+	}
+
+	if err := a.repo.Save(ctx, tx, modelEntry); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
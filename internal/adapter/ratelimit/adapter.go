@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	ratelimitsRepo "github.com/warehouse/ai-service/internal/repository/operations/ratelimits"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+const (
+	LocalStorage    = "local"
+	PostgresStorage = "postgres"
+)
+
+// ErrDeadline очередь к ноде не подойдет до дедлайна запуска
+var ErrDeadline = errors.New("rate limit wait exceeds deadline")
+
+type (
+	// Adapter ограничение частоты запросов по ключу. Wait ждет своей очереди, но не дольше дедлайна ctx
+	Adapter interface {
+		Wait(ctx context.Context, key string, limit domain.RateLimit) error
+	}
+
+	// store хранилище бакетов: take занимает запрос и возвращает, сколько ждать его очереди,
+	// refund возвращает запрос, который так и не был отправлен
+	store interface {
+		take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (time.Duration, error)
+		refund(ctx context.Context, key string, limit domain.RateLimit, now time.Time) error
+	}
+
+	adapter struct {
+		store store
+	}
+)
+
+// NewAdapter local - бакеты в памяти процесса, postgres - общие для всех инстансов сервиса
+func NewAdapter(cfg config.RateLimit, txRepo transactions.Repository, repo ratelimitsRepo.Repository) (Adapter, error) {
+	switch cfg.Storage {
+	case "", LocalStorage:
+		return &adapter{store: newLocalStore()}, nil
+	case PostgresStorage:
+		return &adapter{store: newPostgresStore(txRepo, repo)}, nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit storage %s", cfg.Storage)
+	}
+}
+
+func (a *adapter) Wait(ctx context.Context, key string, limit domain.RateLimit) error {
+	if !limit.Enabled() {
+		return nil
+	}
+
+	delay, err := a.store.take(ctx, key, limit, time.Now())
+	if err != nil {
+		return err
+	}
+	if delay <= 0 {
+		return nil
+	}
+
+	// не ждем заведомо напрасно и сразу освобождаем место в очереди для других запусков
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		_ = a.store.refund(context.WithoutCancel(ctx), key, limit, time.Now())
+		return fmt.Errorf("wait %s: %w", delay.Round(time.Millisecond), ErrDeadline)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		_ = a.store.refund(context.WithoutCancel(ctx), key, limit, time.Now())
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// refill токены бакета к моменту now, но не больше емкости
+func refill(tokens float64, updatedAt, now time.Time, limit domain.RateLimit) float64 {
+	if elapsed := now.Sub(updatedAt); elapsed > 0 {
+		tokens += limit.Refill(elapsed)
+	}
+
+	return math.Min(tokens, limit.Capacity())
+}
+
+// take токенов может стать меньше нуля: это запросы, которые ждут своей очереди
+func take(tokens float64, updatedAt, now time.Time, limit domain.RateLimit) (float64, time.Duration) {
+	tokens = refill(tokens, updatedAt, now, limit) - 1
+	if tokens >= 0 {
+		return tokens, 0
+	}
+
+	return tokens, limit.Delay(-tokens)
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestLocalStoreTake(t *testing.T) {
+	type call struct {
+		at        time.Duration // время вызова от начала
+		refund    bool
+		wantDelay time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		limit domain.RateLimit
+		calls []call
+	}{
+		{
+			name:  "burst equals requests",
+			limit: domain.RateLimit{Requests: 2, IntervalMs: 1000},
+			calls: []call{
+				{at: 0},
+				{at: 0},
+				{at: 0, wantDelay: 500 * time.Millisecond},
+				{at: 0, wantDelay: time.Second},
+			},
+		},
+		{
+			name:  "tokens refill over time",
+			limit: domain.RateLimit{Requests: 2, IntervalMs: 1000},
+			calls: []call{
+				{at: 0},
+				{at: 0},
+				{at: 250 * time.Millisecond, wantDelay: 250 * time.Millisecond},
+				{at: time.Second},
+			},
+		},
+		{
+			name:  "refill is capped by burst",
+			limit: domain.RateLimit{Requests: 10, IntervalMs: 1000, Burst: 1},
+			calls: []call{
+				{at: 0},
+				{at: 10 * time.Second},
+				{at: 10 * time.Second, wantDelay: 100 * time.Millisecond},
+			},
+		},
+		{
+			name:  "bigger burst",
+			limit: domain.RateLimit{Requests: 1, IntervalMs: 1000, Burst: 3},
+			calls: []call{
+				{at: 0},
+				{at: 0},
+				{at: 0},
+				{at: 0, wantDelay: time.Second},
+			},
+		},
+		{
+			name:  "refund frees the queue place",
+			limit: domain.RateLimit{Requests: 1, IntervalMs: 1000},
+			calls: []call{
+				{at: 0},
+				{at: 0, wantDelay: time.Second},
+				{at: 0, refund: true},
+				{at: 0, wantDelay: time.Second},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newLocalStore()
+			start := time.Now()
+
+			for i, c := range tt.calls {
+				now := start.Add(c.at)
+				if c.refund {
+					if err := store.refund(context.Background(), "node", tt.limit, now); err != nil {
+						t.Fatalf("call %d: refund() unexpected error: %v", i, err)
+					}
+					continue
+				}
+
+				delay, err := store.take(context.Background(), "node", tt.limit, now)
+				if err != nil {
+					t.Fatalf("call %d: take() unexpected error: %v", i, err)
+				}
+				if delay != c.wantDelay {
+					t.Fatalf("call %d: take() delay = %s, want %s", i, delay, c.wantDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalStoreKeysAreIndependent(t *testing.T) {
+	store := newLocalStore()
+	limit := domain.RateLimit{Requests: 1, IntervalMs: 1000}
+	now := time.Now()
+
+	for _, key := range []string{"a", "b"} {
+		if delay, _ := store.take(context.Background(), key, limit, now); delay != 0 {
+			t.Fatalf("take(%s) delay = %s, want 0", key, delay)
+		}
+	}
+}
+
+func TestAdapterWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   domain.RateLimit
+		timeout time.Duration
+		wantErr error
+	}{
+		{name: "disabled limit", limit: domain.RateLimit{}},
+		{name: "short wait", limit: domain.RateLimit{Requests: 100, IntervalMs: 1000}, timeout: time.Second},
+		{name: "deadline before the queue", limit: domain.RateLimit{Requests: 1, IntervalMs: 60_000}, timeout: time.Second, wantErr: ErrDeadline},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewAdapter(config.RateLimit{}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tt.timeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			// первый запрос занимает бакет, второй ждет своей очереди
+			if err := a.Wait(ctx, "node", tt.limit); err != nil {
+				t.Fatalf("first Wait() unexpected error: %v", err)
+			}
+			err = a.Wait(ctx, "node", tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second Wait() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
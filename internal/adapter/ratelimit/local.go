@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+type (
+	// localStore бакеты в памяти, ограничение общее для всех горутин процесса
+	localStore struct {
+		mu      sync.Mutex
+		buckets map[string]*localBucket
+	}
+
+	localBucket struct {
+		tokens    float64
+		updatedAt time.Time
+	}
+)
+
+func newLocalStore() *localStore {
+	return &localStore{buckets: make(map[string]*localBucket)}
+}
+
+func (s *localStore) bucket(key string, limit domain.RateLimit, now time.Time) *localBucket {
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &localBucket{tokens: limit.Capacity(), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	return b
+}
+
+func (s *localStore) take(_ context.Context, key string, limit domain.RateLimit, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bucket(key, limit, now)
+
+	var delay time.Duration
+	b.tokens, delay = take(b.tokens, b.updatedAt, now, limit)
+	b.updatedAt = now
+
+	return delay, nil
+}
+
+func (s *localStore) refund(_ context.Context, key string, limit domain.RateLimit, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bucket(key, limit, now)
+	b.tokens = refill(b.tokens+1, b.updatedAt, now, limit)
+	b.updatedAt = now
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	ratelimitsRepo "github.com/warehouse/ai-service/internal/repository/operations/ratelimits"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// postgresStore бакеты в таблице rate_limit_buckets, ограничение общее для всех инстансов.
+// Бакет блокируется только на время пересчета токенов, а не на время ожидания очереди
+type postgresStore struct {
+	txRepo transactions.Repository
+	repo   ratelimitsRepo.Repository
+}
+
+func newPostgresStore(txRepo transactions.Repository, repo ratelimitsRepo.Repository) *postgresStore {
+	return &postgresStore{
+		txRepo: txRepo,
+		repo:   repo,
+	}
+}
+
+func (s *postgresStore) take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (time.Duration, error) {
+	var delay time.Duration
+	err := s.update(ctx, key, limit, now, func(bucket *models.RateLimitBucket) {
+		bucket.Tokens, delay = take(bucket.Tokens, bucket.UpdatedAt, now, limit)
+	})
+
+	return delay, err
+}
+
+func (s *postgresStore) refund(ctx context.Context, key string, limit domain.RateLimit, now time.Time) error {
+	return s.update(ctx, key, limit, now, func(bucket *models.RateLimitBucket) {
+		bucket.Tokens = refill(bucket.Tokens+1, bucket.UpdatedAt, now, limit)
+	})
+}
+
+func (s *postgresStore) update(ctx context.Context, key string, limit domain.RateLimit, now time.Time, change func(bucket *models.RateLimitBucket)) error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	bucket, err := s.repo.Lock(ctx, tx, key, limit.Capacity(), now)
+	if err != nil {
+		return err
+	}
+
+	change(&bucket)
+	// часы инстансов могут расходиться, время бакета не откатываем назад
+	if now.After(bucket.UpdatedAt) {
+		bucket.UpdatedAt = now
+	}
+
+	if err := s.repo.Save(ctx, tx, bucket); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
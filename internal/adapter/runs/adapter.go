@@ -0,0 +1,58 @@
+package runs
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/warehouse/ai-service/internal/broker"
+	"github.com/warehouse/ai-service/internal/domain"
+
+	rmq "github.com/rabbitmq/amqp091-go"
+)
+
+type (
+	Adapter interface {
+		Publish(ctx context.Context, message domain.RunMessage) error
+	}
+
+	adapter struct {
+		channel *rmq.Channel
+		queue   rmq.Queue
+	}
+)
+
+func NewAdapter(scriptQueue string, client *broker.RabbitClient) (Adapter, error) {
+	queue, err := client.DeclareDurableQueue(scriptQueue)
+	if err != nil {
+		return nil, err
+	}
+
+	return adapter{
+		channel: client.Chan,
+		queue:   queue,
+	}, nil
+}
+
+func (a adapter) Publish(ctx context.Context, message domain.RunMessage) error {
+	messageStr, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	if err := a.channel.PublishWithContext(
+		ctx,
+		"",
+		a.queue.Name,
+		false,
+		false,
+		rmq.Publishing{
+			ContentType:  domain.JsonContentType,
+			DeliveryMode: rmq.Persistent,
+			Body:         messageStr,
+		},
+	); err != nil {
+		return err
+	}
+
+	return nil
+}
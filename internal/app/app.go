@@ -5,6 +5,7 @@ import "github.com/warehouse/ai-service/internal/dependencies"
 type (
 	Application interface {
 		Run()
+		RunWorker()
 	}
 
 	application struct {
@@ -30,3 +31,11 @@ func (app *application) Run() {
 	app.deps.WaitForInterrupr() // программа будет "стоять" тут пока не придет системный сигнал
 	app.deps.Close()
 }
+
+func (app *application) RunWorker() {
+	scriptWorker := app.deps.ScriptWorker()
+	scriptWorker.Start()
+
+	app.deps.WaitForInterrupr()
+	app.deps.Close()
+}
@@ -48,3 +48,25 @@ func NewRabbitClient(URL string, queues ...string) (*RabbitClient, error) {
 		Chan:   ch,
 	}, nil
 }
+
+// DeclareDurableQueue объявляет очередь, которая переживает рестарт брокера (для задач, которые нельзя терять)
+func (c *RabbitClient) DeclareDurableQueue(queue string) (rmq.Queue, error) {
+	if q, ok := c.Queues[queue]; ok {
+		return q, nil
+	}
+
+	q, err := c.Chan.QueueDeclare(
+		queue,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return rmq.Queue{}, fmt.Errorf("error while declaring the durable queue %w", err)
+	}
+
+	c.Queues[queue] = q
+	return q, nil
+}
@@ -29,6 +29,8 @@ type (
 	Timeouts struct {
 		AuthTimeout    time.Duration
 		RequestTimeout time.Duration
+		RunTimeout     time.Duration
+		NodeTimeout    time.Duration
 		AccCookie      time.Duration
 	}
 
@@ -38,9 +40,71 @@ type (
 	}
 
 	Rabbit struct {
-		URL       string
-		MailQueue string
-		UserQueue string
+		URL         string
+		MailQueue   string
+		UserQueue   string
+		ScriptQueue string
+	}
+
+	Worker struct {
+		Concurrency int
+	}
+
+	Breaker struct {
+		Storage          string
+		FailureThreshold int
+		OpenTimeout      time.Duration
+		HalfOpenMaxCalls int
+	}
+
+	S3 struct {
+		Endpoint  string
+		Region    string
+		Bucket    string
+		AccessKey string
+		SecretKey string
+	}
+
+	Blob struct {
+		Storage   string
+		Dir       string
+		PublicURL string
+		UrlTTL    time.Duration
+		SignKey   string // подпись ссылок на локальные файлы, общая для api и воркера
+		S3        S3
+	}
+
+	// Cassette запись и воспроизведение ответов нод. Mode и Name - кассета по умолчанию для запусков,
+	// в которых она не указана, например на стейджинге. Кассету в запросе может выбрать админ, а остальные -
+	// только при AllowPerRun, который включается вне прода: запись без ограничений растит хранилище
+	Cassette struct {
+		Mode        string
+		Name        string
+		Storage     string
+		Dir         string
+		AllowPerRun bool
+	}
+
+	// Egress политика исходящих запросов к нодам, см. pkg/egress
+	Egress struct {
+		AllowedSchemes []string
+		AllowedPorts   []int
+		AllowedHosts   []string
+		DeniedHosts    []string
+		AllowPrivate   bool
+		AllowedCIDRs   []string
+		MaxRedirects   int
+	}
+
+	RateLimit struct {
+		Storage string
+	}
+
+	// Cache кеш ответов нод, у которых включен cache
+	Cache struct {
+		Storage       string
+		MaxEntries    int // 0 - 1000
+		MaxEntryBytes int // ответы больше не кешируются, 0 - 1 Мб
 	}
 
 	Server struct {
@@ -61,14 +125,21 @@ type (
 	}
 
 	Config struct {
-		Server   Server
-		Rabbit   Rabbit
-		Auth     Auth
-		Mail     Mail
-		Timeouts Timeouts
-		Postgres Postgres
-		Grpc     Grpc
-		Time     Time
+		Server    Server
+		Rabbit    Rabbit
+		Worker    Worker
+		Breaker   Breaker
+		Blob      Blob
+		Cassette  Cassette
+		Egress    Egress
+		RateLimit RateLimit
+		Cache     Cache
+		Auth      Auth
+		Mail      Mail
+		Timeouts  Timeouts
+		Postgres  Postgres
+		Grpc      Grpc
+		Time      Time
 	}
 )
 
@@ -149,7 +220,7 @@ func NewConfig(cfgPath string) (*Config, error) {
 		return nil, err
 	}
 
-	return &Config{
+	cfg := &Config{
 		Mail: Mail{
 			Email:    v.GetString("mail.email"),
 			Password: v.GetString("mail.password"),
@@ -165,6 +236,8 @@ func NewConfig(cfgPath string) (*Config, error) {
 		Timeouts: Timeouts{
 			RequestTimeout: v.GetDuration("request_timeout.request"), // общие таймауты (можно переносить между сервисами)
 			AuthTimeout:    v.GetDuration("request_timeout.auth"),
+			RunTimeout:     v.GetDuration("request_timeout.run"),  // таймаут на выполнение одного запуска скрипта воркером
+			NodeTimeout:    v.GetDuration("request_timeout.node"), // таймаут запроса к ноде, если в ноде не задан свой
 			AccCookie:      v.GetDuration("acc_cookie"),
 		},
 		Grpc: Grpc{
@@ -186,14 +259,108 @@ func NewConfig(cfgPath string) (*Config, error) {
 		},
 
 		Rabbit: Rabbit{
-			URL:       generateRabbitUrl(v),
-			MailQueue: v.GetString("rabbitmq.queues.mail"),
-			UserQueue: v.GetString("rabbitmq.queues.user"),
+			URL:         generateRabbitUrl(v),
+			MailQueue:   v.GetString("rabbitmq.queues.mail"),
+			UserQueue:   v.GetString("rabbitmq.queues.user"),
+			ScriptQueue: v.GetString("rabbitmq.queues.script"),
+		},
+
+		Worker: Worker{
+			Concurrency: v.GetInt("worker.concurrency"),
+		},
+
+		Breaker: Breaker{
+			Storage:          v.GetString("breaker.storage"),        // local - в памяти процесса, postgres - общий для api и воркеров
+			FailureThreshold: v.GetInt("breaker.failure_threshold"), // 0 выключает брейкеры нод
+			OpenTimeout:      v.GetDuration("breaker.open_timeout"),
+			HalfOpenMaxCalls: v.GetInt("breaker.half_open_max_calls"),
+		},
+
+		Blob: Blob{
+			Storage:   v.GetString("blob.storage"), // local или s3
+			Dir:       v.GetString("blob.dir"),
+			PublicURL: v.GetString("blob.public_url"), // адрес сервиса для ссылок на локальные файлы
+			UrlTTL:    v.GetDuration("blob.url_ttl"),  // время жизни подписанных ссылок
+			SignKey:   v.GetString("blob.sign_key"),
+			S3: S3{
+				Endpoint:  v.GetString("blob.s3.endpoint"),
+				Region:    v.GetString("blob.s3.region"),
+				Bucket:    v.GetString("blob.s3.bucket"),
+				AccessKey: v.GetString("blob.s3.access_key"),
+				SecretKey: v.GetString("blob.s3.secret_key"),
+			},
+		},
+
+		Cassette: Cassette{
+			Mode:    v.GetString("cassette.mode"), // record, replay или пусто - кассета не используется
+			Name:    v.GetString("cassette.name"),
+			Storage: v.GetString("cassette.storage"), // local или postgres
+			Dir:     v.GetString("cassette.dir"),
+
+			AllowPerRun: v.GetBool("cassette.allow_per_run"),
+		},
+
+		Egress: Egress{
+			AllowedSchemes: v.GetStringSlice("egress.allowed_schemes"), // пусто - http и https
+			AllowedPorts:   v.GetIntSlice("egress.allowed_ports"),      // пусто - любой порт
+			AllowedHosts:   v.GetStringSlice("egress.allowed_hosts"),
+			DeniedHosts:    v.GetStringSlice("egress.denied_hosts"),
+			AllowPrivate:   v.GetBool("egress.allow_private"), // только для локальной разработки
+			AllowedCIDRs:   v.GetStringSlice("egress.allowed_cidrs"),
+			MaxRedirects:   v.GetInt("egress.max_redirects"),
+		},
+
+		RateLimit: RateLimit{
+			Storage: v.GetString("rate_limit.storage"), // local - в памяти процесса, postgres - общий для всех инстансов
+		},
+
+		Cache: Cache{
+			Storage:       v.GetString("cache.storage"), // local - в памяти процесса, postgres - общий для всех инстансов
+			MaxEntries:    v.GetInt("cache.max_entries"),
+			MaxEntryBytes: v.GetInt("cache.max_entry_bytes"),
 		},
 
 		Time: Time{
 			Locale: v.GetInt64("locale"),
 		},
-	}, nil
+	}
+
+	if mode != "local" {
+		if err := checkDeployed(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if mode == "prod" {
+		if err := checkProd(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// checkDeployed настройки, допустимые только при запуске на машине разработчика (mode local)
+func checkDeployed(cfg *Config) error {
+	// внутренние сети развернутого сервиса - это его инфраструктура, нужные сети разрешаются через allowed_cidrs
+	if cfg.Egress.AllowPrivate {
+		return fmt.Errorf("egress.allow_private is for local development only (mode local), use egress.allowed_cidrs")
+	}
+
+	return nil
+}
+
+// checkProd настройки, недопустимые в проде
+func checkProd(cfg *Config) error {
+	// api и воркер в проде работают отдельно и не видят файлы друг друга
+	if cfg.Blob.Storage == "" || cfg.Blob.Storage == "local" {
+		return fmt.Errorf("blob.storage local is for development only, use s3 in prod")
+	}
+
+	// с local каждый воркер размыкает брейкер сам, а api не видит ни одного
+	if cfg.Breaker.FailureThreshold > 0 && (cfg.Breaker.Storage == "" || cfg.Breaker.Storage == "local") {
+		return fmt.Errorf("breaker.storage local is for development only, use postgres in prod")
+	}
 
+	return nil
 }
@@ -2,8 +2,13 @@ package dependencies
 
 import (
 	"github.com/warehouse/ai-service/internal/adapter/auth"
+	"github.com/warehouse/ai-service/internal/adapter/blob"
+	"github.com/warehouse/ai-service/internal/adapter/cache"
+	"github.com/warehouse/ai-service/internal/adapter/cassette"
 	"github.com/warehouse/ai-service/internal/adapter/mail"
 	"github.com/warehouse/ai-service/internal/adapter/random"
+	"github.com/warehouse/ai-service/internal/adapter/ratelimit"
+	"github.com/warehouse/ai-service/internal/adapter/runs"
 	"github.com/warehouse/ai-service/internal/adapter/time"
 
 	"go.uber.org/zap"
@@ -47,3 +52,58 @@ func (d *dependencies) MailAdapter() mail.Adapter {
 
 	return d.mailAdapter
 }
+
+func (d *dependencies) RunsAdapter() runs.Adapter {
+	if d.runsAdapter == nil {
+		var err error
+		if d.runsAdapter, err = runs.NewAdapter(d.cfg.Rabbit.ScriptQueue, d.RabbitClient()); err != nil {
+			d.log.Zap().Panic("create runs broker adapter", zap.Error(err))
+		}
+	}
+
+	return d.runsAdapter
+}
+
+func (d *dependencies) BlobAdapter() blob.Adapter {
+	if d.blobAdapter == nil {
+		var err error
+		if d.blobAdapter, err = blob.NewAdapter(d.cfg.Blob); err != nil {
+			d.log.Zap().Panic("create blob storage adapter", zap.Error(err))
+		}
+	}
+
+	return d.blobAdapter
+}
+
+func (d *dependencies) CassetteAdapter() cassette.Adapter {
+	if d.cassetteAdapter == nil {
+		var err error
+		if d.cassetteAdapter, err = cassette.NewAdapter(d.cfg.Cassette, d.PgxTransactionRepo(), d.CassettesRepo()); err != nil {
+			d.log.Zap().Panic("create cassette storage adapter", zap.Error(err))
+		}
+	}
+
+	return d.cassetteAdapter
+}
+
+func (d *dependencies) RateLimitAdapter() ratelimit.Adapter {
+	if d.ratelimitAdapter == nil {
+		var err error
+		if d.ratelimitAdapter, err = ratelimit.NewAdapter(d.cfg.RateLimit, d.PgxTransactionRepo(), d.RateLimitsRepo()); err != nil {
+			d.log.Zap().Panic("create rate limit adapter", zap.Error(err))
+		}
+	}
+
+	return d.ratelimitAdapter
+}
+
+func (d *dependencies) CacheAdapter() cache.Adapter {
+	if d.cacheAdapter == nil {
+		var err error
+		if d.cacheAdapter, err = cache.NewAdapter(d.cfg.Cache, d.PgxTransactionRepo(), d.ResponseCacheRepo()); err != nil {
+			d.log.Zap().Panic("create cache adapter", zap.Error(err))
+		}
+	}
+
+	return d.cacheAdapter
+}
@@ -6,21 +6,35 @@ import (
 	"syscall"
 
 	authAdpt "github.com/warehouse/ai-service/internal/adapter/auth"
+	blobAdpt "github.com/warehouse/ai-service/internal/adapter/blob"
+	cacheAdpt "github.com/warehouse/ai-service/internal/adapter/cache"
+	cassetteAdpt "github.com/warehouse/ai-service/internal/adapter/cassette"
 	mailAdpt "github.com/warehouse/ai-service/internal/adapter/mail"
 	randomAdpt "github.com/warehouse/ai-service/internal/adapter/random"
+	ratelimitAdpt "github.com/warehouse/ai-service/internal/adapter/ratelimit"
+	runsAdpt "github.com/warehouse/ai-service/internal/adapter/runs"
 	timeAdpt "github.com/warehouse/ai-service/internal/adapter/time"
 	"github.com/warehouse/ai-service/internal/broker"
 	"github.com/warehouse/ai-service/internal/config"
 	"github.com/warehouse/ai-service/internal/db"
 	"github.com/warehouse/ai-service/internal/handler/http"
 	"github.com/warehouse/ai-service/internal/handler/middlewares"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
 	"github.com/warehouse/ai-service/internal/pkg/logger"
+	breakersRepo "github.com/warehouse/ai-service/internal/repository/operations/breakers"
+	cassettesRepo "github.com/warehouse/ai-service/internal/repository/operations/cassettes"
 	nodesRepo "github.com/warehouse/ai-service/internal/repository/operations/nodes"
+	ratelimitsRepo "github.com/warehouse/ai-service/internal/repository/operations/ratelimits"
+	responseCacheRepo "github.com/warehouse/ai-service/internal/repository/operations/responsecache"
+	runsRepo "github.com/warehouse/ai-service/internal/repository/operations/runs"
 	scriptRepo "github.com/warehouse/ai-service/internal/repository/operations/script"
+	stepsRepo "github.com/warehouse/ai-service/internal/repository/operations/steps"
 	transactionsRepo "github.com/warehouse/ai-service/internal/repository/operations/transactions"
 	"github.com/warehouse/ai-service/internal/server"
 	nodeSvc "github.com/warehouse/ai-service/internal/service/node"
 	scriptSvc "github.com/warehouse/ai-service/internal/service/script"
+	"github.com/warehouse/ai-service/internal/worker"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -34,6 +48,7 @@ type (
 		WaitForInterrupr()
 
 		AppServer() server.Server
+		ScriptWorker() worker.Worker
 	}
 
 	dependencies struct {
@@ -51,16 +66,31 @@ type (
 		scriptService scriptSvc.Service
 		nodeService   nodeSvc.Service
 
+		breakerRegistry breaker.Registry
+		egressPolicy    *egress.Policy
+
 		pgxTransactionRepo transactionsRepo.Repository
 		scriptRepo         scriptRepo.Repository
 		nodesRepo          nodesRepo.Repository
-
-		timeAdapter   timeAdpt.Adapter
-		randomAdapter randomAdpt.Adapter
-		authAdapter   authAdpt.Adapter
-		mailAdapter   mailAdpt.Adapter
-
-		appServer server.Server
+		runsRepo           runsRepo.Repository
+		stepsRepo          stepsRepo.Repository
+		cassettesRepo      cassettesRepo.Repository
+		ratelimitsRepo     ratelimitsRepo.Repository
+		responseCacheRepo  responseCacheRepo.Repository
+		breakersRepo       breakersRepo.Repository
+
+		timeAdapter      timeAdpt.Adapter
+		randomAdapter    randomAdpt.Adapter
+		authAdapter      authAdpt.Adapter
+		mailAdapter      mailAdpt.Adapter
+		runsAdapter      runsAdpt.Adapter
+		blobAdapter      blobAdpt.Adapter
+		cassetteAdapter  cassetteAdpt.Adapter
+		ratelimitAdapter ratelimitAdpt.Adapter
+		cacheAdapter     cacheAdpt.Adapter
+
+		appServer    server.Server
+		scriptWorker worker.Worker
 
 		shutdownChannel chan os.Signal
 		closeCallbacks  []func()
@@ -153,6 +183,33 @@ func (d *dependencies) AppServer() server.Server {
 	return d.appServer
 }
 
+func (d *dependencies) ScriptWorker() worker.Worker {
+	if d.scriptWorker == nil {
+		var err error
+		msg := "initialize script worker"
+		if d.scriptWorker, err = worker.NewScriptWorker(
+			d.log,
+			d.cfg.Worker,
+			d.cfg.Timeouts,
+			d.RabbitClient(),
+			d.cfg.Rabbit.ScriptQueue,
+			d.ScriptService(),
+		); err != nil {
+			d.log.Zap().Panic(msg, zap.Error(err))
+		}
+
+		d.closeCallbacks = append(d.closeCallbacks, func() {
+			msg := "shutting down script worker"
+			if err := d.scriptWorker.Stop(); err != nil {
+				d.log.Zap().Warn(msg, zap.Error(err))
+				return
+			}
+			d.log.Zap().Info(msg)
+		})
+	}
+	return d.scriptWorker
+}
+
 func (d *dependencies) WaitForInterrupr() {
 	signal.Notify(d.shutdownChannel, syscall.SIGINT, syscall.SIGTERM)
 	d.log.Zap().Info("Wait for receive interrupt signal")
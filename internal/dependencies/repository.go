@@ -1,8 +1,14 @@
 package dependencies
 
 import (
+	"github.com/warehouse/ai-service/internal/repository/operations/breakers"
+	"github.com/warehouse/ai-service/internal/repository/operations/cassettes"
 	"github.com/warehouse/ai-service/internal/repository/operations/nodes"
+	"github.com/warehouse/ai-service/internal/repository/operations/ratelimits"
+	"github.com/warehouse/ai-service/internal/repository/operations/responsecache"
+	"github.com/warehouse/ai-service/internal/repository/operations/runs"
 	"github.com/warehouse/ai-service/internal/repository/operations/script"
+	"github.com/warehouse/ai-service/internal/repository/operations/steps"
 	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
 )
 
@@ -28,3 +34,51 @@ func (d *dependencies) NodesRepo() nodes.Repository {
 
 	return d.nodesRepo
 }
+
+func (d *dependencies) RunsRepo() runs.Repository {
+	if d.runsRepo == nil {
+		d.runsRepo = runs.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.runsRepo
+}
+
+func (d *dependencies) StepsRepo() steps.Repository {
+	if d.stepsRepo == nil {
+		d.stepsRepo = steps.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.stepsRepo
+}
+
+func (d *dependencies) CassettesRepo() cassettes.Repository {
+	if d.cassettesRepo == nil {
+		d.cassettesRepo = cassettes.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.cassettesRepo
+}
+
+func (d *dependencies) RateLimitsRepo() ratelimits.Repository {
+	if d.ratelimitsRepo == nil {
+		d.ratelimitsRepo = ratelimits.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.ratelimitsRepo
+}
+
+func (d *dependencies) BreakersRepo() breakers.Repository {
+	if d.breakersRepo == nil {
+		d.breakersRepo = breakers.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.breakersRepo
+}
+
+func (d *dependencies) ResponseCacheRepo() responsecache.Repository {
+	if d.responseCacheRepo == nil {
+		d.responseCacheRepo = responsecache.NewPGRepository(d.log, d.PostgresClient())
+	}
+
+	return d.responseCacheRepo
+}
@@ -1,8 +1,13 @@
 package dependencies
 
 import (
+	breakerAdpt "github.com/warehouse/ai-service/internal/adapter/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
 	"github.com/warehouse/ai-service/internal/service/node"
 	"github.com/warehouse/ai-service/internal/service/script"
+
+	"go.uber.org/zap"
 )
 
 func (d *dependencies) ScriptService() script.Service {
@@ -10,9 +15,18 @@ func (d *dependencies) ScriptService() script.Service {
 		d.scriptService = script.NewService(
 			*d.cfg,
 			d.log,
-			d.pgxTransactionRepo,
-			d.nodesRepo,
-			d.scriptRepo,
+			d.PgxTransactionRepo(),
+			d.NodesRepo(),
+			d.ScriptRepo(),
+			d.RunsRepo(),
+			d.StepsRepo(),
+			d.RunsAdapter(),
+			d.BlobAdapter(),
+			d.CassetteAdapter(),
+			d.RateLimitAdapter(),
+			d.CacheAdapter(),
+			d.BreakerRegistry(),
+			d.EgressPolicy(),
 		)
 	}
 
@@ -24,10 +38,42 @@ func (d *dependencies) NodeService() node.Service {
 		d.nodeService = node.NewService(
 			*d.cfg,
 			d.log,
-			d.pgxTransactionRepo,
-			d.nodesRepo,
+			d.PgxTransactionRepo(),
+			d.NodesRepo(),
+			d.BreakerRegistry(),
+			d.EgressPolicy(),
 		)
 	}
 
 	return d.nodeService
 }
+
+func (d *dependencies) BreakerRegistry() breaker.Registry {
+	if d.breakerRegistry == nil {
+		var err error
+		if d.breakerRegistry, err = breakerAdpt.NewRegistry(d.cfg.Breaker, d.PgxTransactionRepo(), d.BreakersRepo()); err != nil {
+			d.log.Zap().Panic("create breaker registry", zap.Error(err))
+		}
+	}
+
+	return d.breakerRegistry
+}
+
+func (d *dependencies) EgressPolicy() *egress.Policy {
+	if d.egressPolicy == nil {
+		var err error
+		if d.egressPolicy, err = egress.NewPolicy(egress.Settings{
+			AllowedSchemes: d.cfg.Egress.AllowedSchemes,
+			AllowedPorts:   d.cfg.Egress.AllowedPorts,
+			AllowedHosts:   d.cfg.Egress.AllowedHosts,
+			DeniedHosts:    d.cfg.Egress.DeniedHosts,
+			AllowPrivate:   d.cfg.Egress.AllowPrivate,
+			AllowedCIDRs:   d.cfg.Egress.AllowedCIDRs,
+			MaxRedirects:   d.cfg.Egress.MaxRedirects,
+		}); err != nil {
+			d.log.Zap().Panic("create egress policy", zap.Error(err))
+		}
+	}
+
+	return d.egressPolicy
+}
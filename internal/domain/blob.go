@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"mime"
+	"strings"
+)
+
+// BlobRefPrefix бинарный результат ноды сохраняется в хранилище и передается дальше ссылкой blob://<ключ>
+const BlobRefPrefix = "blob://"
+
+func BlobRef(key string) string {
+	return BlobRefPrefix + key
+}
+
+// BlobKey ключ в хранилище, если значение - ссылка на бинарный результат
+func BlobKey(value string) (string, bool) {
+	key, ok := strings.CutPrefix(value, BlobRefPrefix)
+	return key, ok && key != ""
+}
+
+// IsBinaryMime результат с таким mime не текст и сохраняется в хранилище. Пустой mime - текст
+func IsBinaryMime(mimeType string) bool {
+	if mimeType == "" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == JsonContentType,
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return false
+	}
+
+	return true
+}
+
+// IsJsonMime ответ в json, для бинарной ноды это значит, что результат лежит в base64 поле ответа
+func IsJsonMime(mimeType string) bool {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == JsonContentType || strings.HasSuffix(mediaType, "+json")
+}
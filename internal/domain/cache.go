@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+)
+
+type (
+	// CacheOptions кеш успешных ответов ноды на одинаковые запросы. Ключ - нода, метод, адрес и тело запроса
+	CacheOptions struct {
+		TtlMs int64 `json:"ttl_ms,omitempty"` // 0 - ответы не кешируются
+	}
+
+	// CachedResponse сохраненный успешный ответ ноды
+	CachedResponse struct {
+		Key        string
+		NodeId     string
+		StatusCode int
+		Header     http.Header
+		Body       []byte
+		Streamed   string // склейка дельт, если нода стримит
+		ExpiresAt  time.Time
+		CreatedAt  time.Time
+	}
+)
+
+func (o CacheOptions) Enabled() bool {
+	return o.TtlMs > 0
+}
+
+func (o CacheOptions) Validate() error {
+	if o.TtlMs < 0 {
+		return fmt.Errorf("cache: ttl_ms can't be negative")
+	}
+
+	return nil
+}
+
+func (o CacheOptions) Ttl() time.Duration {
+	return time.Duration(o.TtlMs) * time.Millisecond
+}
+
+// Size сколько места ответ занимает в кеше
+func (r CachedResponse) Size() int {
+	return len(r.Body) + len(r.Streamed)
+}
+
+func (r CachedResponse) ToModel() (models.CachedResponse, error) {
+	header, err := toJSONMap(r.Header)
+	if err != nil {
+		return models.CachedResponse{}, err
+	}
+
+	return models.CachedResponse{
+		Key:        r.Key,
+		NodeId:     r.NodeId,
+		StatusCode: r.StatusCode,
+		Header:     header,
+		Body:       r.Body,
+		Streamed:   r.Streamed,
+		ExpiresAt:  r.ExpiresAt,
+		CreatedAt:  r.CreatedAt,
+	}, nil
+}
+
+func (CachedResponse) FromModel(m models.CachedResponse) (CachedResponse, error) {
+	header := http.Header{}
+	if err := fromJSONMap(m.Header, &header); err != nil {
+		return CachedResponse{}, err
+	}
+
+	return CachedResponse{
+		Key:        m.Key,
+		NodeId:     m.NodeId,
+		StatusCode: m.StatusCode,
+		Header:     header,
+		Body:       m.Body,
+		Streamed:   m.Streamed,
+		ExpiresAt:  m.ExpiresAt,
+		CreatedAt:  m.CreatedAt,
+	}, nil
+}
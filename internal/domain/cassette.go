@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+)
+
+type CassetteMode string
+
+const (
+	CassetteOff    CassetteMode = ""
+	CassetteRecord CassetteMode = "record" // запросы уходят в ноды, пары запрос-ответ сохраняются в кассету
+	CassetteReplay CassetteMode = "replay" // ответы берутся из кассеты, ноды не вызываются
+)
+
+// имя кассеты становится каталогом на диске, поэтому без разделителей пути
+var cassetteNameRegexp = regexp.MustCompile(`^[0-9A-Za-z_.-]{1,128}$`)
+
+type (
+	// RunCassette кассета запуска: куда записывать ответы нод или откуда их воспроизводить
+	RunCassette struct {
+		Name  string       `json:"name,omitempty"`
+		Mode  CassetteMode `json:"mode,omitempty"`
+		Owner string       `json:"-"` // автор запуска, кассеты разных авторов не пересекаются
+	}
+
+	// CassetteEntry сохраненный ответ ноды. Ключ - владелец, кассета, нода и хеш нормализованного запроса
+	CassetteEntry struct {
+		Owner       string
+		Cassette    string
+		NodeId      string
+		RequestHash string
+		StatusCode  int
+		Header      http.Header
+		Body        []byte
+		RecordedAt  time.Time
+	}
+)
+
+func (c RunCassette) Enabled() bool {
+	return c.Mode != CassetteOff
+}
+
+func (c RunCassette) Validate() error {
+	switch c.Mode {
+	case CassetteOff:
+		if c.Name != "" {
+			return fmt.Errorf("cassette: mode is required")
+		}
+		return nil
+	case CassetteRecord, CassetteReplay:
+	default:
+		return fmt.Errorf("cassette: unknown mode %s", c.Mode)
+	}
+
+	if !cassetteNameRegexp.MatchString(c.Name) || c.Name == "." || c.Name == ".." {
+		return fmt.Errorf("cassette: name is required and should contain only letters, digits, '_', '-' and '.'")
+	}
+
+	return nil
+}
+
+func (e CassetteEntry) ToModel() (models.CassetteEntry, error) {
+	header, err := toJSONMap(e.Header)
+	if err != nil {
+		return models.CassetteEntry{}, err
+	}
+
+	return models.CassetteEntry{
+		Owner:       e.Owner,
+		Cassette:    e.Cassette,
+		NodeId:      e.NodeId,
+		RequestHash: e.RequestHash,
+		StatusCode:  e.StatusCode,
+		Header:      header,
+		Body:        e.Body,
+		RecordedAt:  e.RecordedAt,
+	}, nil
+}
+
+func (CassetteEntry) FromModel(m models.CassetteEntry) (CassetteEntry, error) {
+	header := http.Header{}
+	if err := fromJSONMap(m.Header, &header); err != nil {
+		return CassetteEntry{}, err
+	}
+
+	return CassetteEntry{
+		Owner:       m.Owner,
+		Cassette:    m.Cassette,
+		NodeId:      m.NodeId,
+		RequestHash: m.RequestHash,
+		StatusCode:  m.StatusCode,
+		Header:      header,
+		Body:        m.Body,
+		RecordedAt:  m.RecordedAt,
+	}, nil
+}
@@ -1,9 +0,0 @@
-package domain
-
-type (
-	ChainResult struct {
-		Response string
-		Mime     string
-		Error    error
-	}
-)
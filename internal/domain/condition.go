@@ -0,0 +1,224 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+type ConditionOp string
+
+const (
+	EqConditionOp     ConditionOp = "eq"
+	NeConditionOp     ConditionOp = "ne"
+	RegexConditionOp  ConditionOp = "regex"
+	GtConditionOp     ConditionOp = "gt"
+	GteConditionOp    ConditionOp = "gte"
+	LtConditionOp     ConditionOp = "lt"
+	LteConditionOp    ConditionOp = "lte"
+	ExistsConditionOp ConditionOp = "exists"
+)
+
+type (
+	// OutputRef ссылка на результат шага, а если указана цепочка - на результат цепочки шага.
+	// В сценарии-графе ссылка указывает на узел графа по имени
+	OutputRef struct {
+		Step  int    `json:"step"`
+		Chain *int   `json:"chain,omitempty"`
+		Node  string `json:"node,omitempty"`
+	}
+
+	// Condition предикат над результатом, при котором шаг или цепочка выполняются
+	Condition struct {
+		Source *OutputRef  `json:"source,omitempty"` // без источника проверяется вход шага
+		Path   string      `json:"path,omitempty"`   // путь gojsonq внутри результата, пусто - результат целиком
+		Op     ConditionOp `json:"op"`
+		Value  interface{} `json:"value,omitempty"`
+	}
+
+	ChainOptions struct {
+		Name string      `json:"name,omitempty"` // ключ цепочки при объединении шага в json_object
+		When *Condition  `json:"when,omitempty"`
+		Map  *MapOptions `json:"map,omitempty"` // цепочка выполняется для каждого элемента массива из входа шага
+
+		OnError *ErrorPolicy `json:"on_error,omitempty"` // перекрывает политику шага
+
+		// Fallbacks запасные ноды элементов цепочки, ключ - позиция ноды в цепочке
+		Fallbacks map[int][]string `json:"fallbacks,omitempty"`
+	}
+
+	// StepOptions настройки шага сценария, ключ цепочки - ее номер внутри шага
+	StepOptions struct {
+		When   *Condition           `json:"when,omitempty"`
+		Merge  *MergeOptions        `json:"merge,omitempty"` // как объединить результаты цепочек, по умолчанию склейка через ". "
+		Chains map[int]ChainOptions `json:"chains,omitempty"`
+
+		OnError *ErrorPolicy `json:"on_error,omitempty"` // политика ошибок для цепочек шага без своей политики
+	}
+)
+
+// ChainErrorPolicy политика ошибок цепочки с учетом политики шага
+func (o StepOptions) ChainErrorPolicy(chain int) *ErrorPolicy {
+	if policy := o.Chains[chain].OnError; policy != nil {
+		return policy
+	}
+
+	return o.OnError
+}
+
+func (c Condition) Validate() error {
+	switch c.Op {
+	case EqConditionOp, NeConditionOp:
+		if c.Value == nil {
+			return fmt.Errorf("%s: value is required", c.Op)
+		}
+	case RegexConditionOp:
+		pattern, ok := c.Value.(string)
+		if !ok {
+			return fmt.Errorf("regex: value should be string")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("regex: %s", err.Error())
+		}
+	case GtConditionOp, GteConditionOp, LtConditionOp, LteConditionOp:
+		if _, ok := toNumber(c.Value); !ok {
+			return fmt.Errorf("%s: value should be number", c.Op)
+		}
+	case ExistsConditionOp:
+	default:
+		return fmt.Errorf("unknown condition op %s", c.Op)
+	}
+
+	if c.Source != nil && c.Source.Step < 0 {
+		return fmt.Errorf("source step can't be negative")
+	}
+
+	return nil
+}
+
+// Eval проверяет предикат над результатом. Если значение по пути не найдено
+// или не подходит по типу, предикат не выполняется
+func (c Condition) Eval(data string) bool {
+	value, found := c.lookup(data)
+	if c.Op == ExistsConditionOp {
+		return found
+	}
+	if !found {
+		return false
+	}
+
+	switch c.Op {
+	case EqConditionOp:
+		return equalValues(value, c.Value)
+	case NeConditionOp:
+		return !equalValues(value, c.Value)
+	case RegexConditionOp:
+		pattern, _ := c.Value.(string)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(stringValue(value))
+	}
+
+	left, ok := toNumber(value)
+	if !ok {
+		return false
+	}
+	right, ok := toNumber(c.Value)
+	if !ok {
+		return false
+	}
+
+	switch c.Op {
+	case GtConditionOp:
+		return left > right
+	case GteConditionOp:
+		return left >= right
+	case LtConditionOp:
+		return left < right
+	case LteConditionOp:
+		return left <= right
+	}
+
+	return false
+}
+
+func (c Condition) lookup(data string) (interface{}, bool) {
+	return lookupPath(data, c.Path)
+}
+
+func lookupPath(data, path string) (interface{}, bool) {
+	if path == "" {
+		return data, true
+	}
+
+	jq := gojsonq.New().FromString(data)
+	value := jq.Find(path)
+	if jq.Error() != nil || value == nil {
+		return nil, false
+	}
+
+	return value, true
+}
+
+// ExtractValue значение по пути gojsonq внутри json результата в виде строки, объекты и массивы - json
+func ExtractValue(data, path string) (string, bool) {
+	value, ok := lookupPath(data, path)
+	if !ok {
+		return "", false
+	}
+
+	return stringValue(value), true
+}
+
+func equalValues(a, b interface{}) bool {
+	if left, ok := toNumber(a); ok {
+		if right, ok := toNumber(b); ok {
+			return left == right
+		}
+	}
+
+	switch a.(type) {
+	case map[string]interface{}, []interface{}:
+		return reflect.DeepEqual(a, b)
+	}
+
+	return stringValue(a) == stringValue(b)
+}
+
+func stringValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConditionEval(t *testing.T) {
+	data := `{"status":"ok","score":0.75,"count":"3","tags":["a","b"],"user":{"name":"bob"}}`
+
+	tests := []struct {
+		name      string
+		condition Condition
+		data      string
+		want      bool
+	}{
+		{name: "eq string", condition: Condition{Path: "status", Op: EqConditionOp, Value: "ok"}, data: data, want: true},
+		{name: "eq string mismatch", condition: Condition{Path: "status", Op: EqConditionOp, Value: "fail"}, data: data, want: false},
+		{name: "eq number from string", condition: Condition{Path: "count", Op: EqConditionOp, Value: 3.0}, data: data, want: true},
+		{name: "eq array", condition: Condition{Path: "tags", Op: EqConditionOp, Value: []interface{}{"a", "b"}}, data: data, want: true},
+		{name: "eq whole text result", condition: Condition{Op: EqConditionOp, Value: "yes"}, data: "yes", want: true},
+		{name: "ne", condition: Condition{Path: "status", Op: NeConditionOp, Value: "fail"}, data: data, want: true},
+		{name: "ne missing path", condition: Condition{Path: "missing", Op: NeConditionOp, Value: "fail"}, data: data, want: false},
+		{name: "regex", condition: Condition{Path: "user.name", Op: RegexConditionOp, Value: "^b.b$"}, data: data, want: true},
+		{name: "regex on whole result", condition: Condition{Op: RegexConditionOp, Value: "(?i)error"}, data: "Internal ERROR", want: true},
+		{name: "gt", condition: Condition{Path: "score", Op: GtConditionOp, Value: 0.5}, data: data, want: true},
+		{name: "gt equal", condition: Condition{Path: "score", Op: GtConditionOp, Value: 0.75}, data: data, want: false},
+		{name: "gte equal", condition: Condition{Path: "score", Op: GteConditionOp, Value: 0.75}, data: data, want: true},
+		{name: "lt numeric string", condition: Condition{Path: "count", Op: LtConditionOp, Value: "10"}, data: data, want: true},
+		{name: "lte", condition: Condition{Path: "score", Op: LteConditionOp, Value: 0.7}, data: data, want: false},
+		{name: "gt not a number", condition: Condition{Path: "status", Op: GtConditionOp, Value: 1.0}, data: data, want: false},
+		{name: "exists", condition: Condition{Path: "user.name", Op: ExistsConditionOp}, data: data, want: true},
+		{name: "exists missing", condition: Condition{Path: "user.age", Op: ExistsConditionOp}, data: data, want: false},
+		{name: "path in non json result", condition: Condition{Path: "status", Op: ExistsConditionOp}, data: "plain text", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.condition.Eval(tt.data); got != tt.want {
+				t.Fatalf("Eval(%s) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		wantErr   string
+	}{
+		{name: "eq", condition: Condition{Op: EqConditionOp, Value: "x"}},
+		{name: "eq without value", condition: Condition{Op: EqConditionOp}, wantErr: "value is required"},
+		{name: "regex", condition: Condition{Op: RegexConditionOp, Value: "^a+$"}},
+		{name: "regex not a string", condition: Condition{Op: RegexConditionOp, Value: 1.0}, wantErr: "should be string"},
+		{name: "regex invalid", condition: Condition{Op: RegexConditionOp, Value: "(a"}, wantErr: "regex:"},
+		{name: "gt numeric string", condition: Condition{Op: GtConditionOp, Value: "1.5"}},
+		{name: "gt not a number", condition: Condition{Op: GtConditionOp, Value: "many"}, wantErr: "should be number"},
+		{name: "exists", condition: Condition{Op: ExistsConditionOp, Path: "a"}},
+		{name: "unknown op", condition: Condition{Op: "contains"}, wantErr: "unknown condition op"},
+		{name: "negative source step", condition: Condition{Op: ExistsConditionOp, Source: &OutputRef{Step: -1}}, wantErr: "can't be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.condition.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExtractValue(t *testing.T) {
+	data := `{"a":{"b":[1,2]},"n":2,"s":"text"}`
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{path: "", want: data, wantOk: true},
+		{path: "s", want: "text", wantOk: true},
+		{path: "n", want: "2", wantOk: true},
+		{path: "a", want: `{"b":[1,2]}`, wantOk: true},
+		{path: "a.b", want: "[1,2]", wantOk: true},
+		{path: "missing", wantOk: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := ExtractValue(data, tt.path)
+			if got != tt.want || ok != tt.wantOk {
+				t.Fatalf("ExtractValue(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -5,9 +5,16 @@ type CtxKey string
 const (
 	JsonContentType  = "application/json"
 	ProtoContentType = "application/x-protobuf"
+	TextContentType  = "text/plain"
 
-	HeaderContentType   = "Content-Type"
-	HeaderXForwardedFor = "X-Forwarded-For"
+	MultipartContentType   = "multipart/form-data"
+	FormContentType        = "application/x-www-form-urlencoded"
+	OctetStreamContentType = "application/octet-stream"
+
+	HeaderContentType        = "Content-Type"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderContentTypeOptions = "X-Content-Type-Options"
+	HeaderXForwardedFor      = "X-Forwarded-For"
 
 	HeaderAuth       = "Authorization"
 	HeaderVersion    = "Coffee-Version"
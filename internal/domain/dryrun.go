@@ -0,0 +1,25 @@
+package domain
+
+type (
+	// DryRunRequest запрос, который запуск отправил бы в ноду. Заголовки с секретами замаскированы,
+	// тело - заполненный json, как в истории запуска, его кодирование по request_mime не выполняется
+	DryRunRequest struct {
+		GraphNode   string
+		NodeId      string
+		NodeName    string
+		Step        int
+		Chain       int
+		Position    int
+		Url         string
+		Method      string
+		Headers     map[string]string
+		ContentType string
+		Body        string
+		Error       string // шаблоны или тело не удалось заполнить
+	}
+)
+
+// DryRunPlaceholder заглушка результата узла, для которого не передано значение в mocks
+func DryRunPlaceholder(graphNode string) string {
+	return "<output of " + graphNode + ">"
+}
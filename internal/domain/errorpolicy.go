@@ -0,0 +1,47 @@
+package domain
+
+import "fmt"
+
+type ErrorAction string
+
+const (
+	FailErrorAction    ErrorAction = "fail"    // ошибка завершает запуск
+	SkipErrorAction    ErrorAction = "skip"    // узел считается пропущенным
+	DefaultErrorAction ErrorAction = "default" // результатом узла становится значение по умолчанию
+)
+
+// ErrorPolicy что делать, если узел (цепочка) завершился ошибкой. Без политики ошибка завершает запуск.
+// Узлы, у которых все входы пропущены из-за ошибки, тоже считаются упавшими: с default они отдают
+// значение по умолчанию без вызова, иначе пропускаются
+type ErrorPolicy struct {
+	Action  ErrorAction `json:"action"`
+	Default string      `json:"default,omitempty"` // для default
+}
+
+func (p *ErrorPolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+
+	switch p.Action {
+	case FailErrorAction, SkipErrorAction:
+		if p.Default != "" {
+			return fmt.Errorf("on_error: default value is allowed only for default action")
+		}
+	case DefaultErrorAction:
+	default:
+		return fmt.Errorf("on_error: unknown action %s", p.Action)
+	}
+
+	return nil
+}
+
+// Handles политика не дает ошибке завершить запуск
+func (p *ErrorPolicy) Handles() bool {
+	return p != nil && p.Action != FailErrorAction
+}
+
+// Substitutes вместо результата упавшего узла отдается значение по умолчанию
+func (p *ErrorPolicy) Substitutes() bool {
+	return p != nil && p.Action == DefaultErrorAction
+}
@@ -0,0 +1,373 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/jmespath/go-jmespath"
+)
+
+type ExtractorKind string
+
+const (
+	JsonPathExtractor ExtractorKind = "json_path" // путь через точку: choices.[0].message.content, items.[*].id, data.*.url
+	JmesPathExtractor ExtractorKind = "jmespath"
+	XPathExtractor    ExtractorKind = "xpath" // для xml ответов
+	RegexExtractor    ExtractorKind = "regex" // для text/plain, результат - группа захвата
+	BodyExtractor     ExtractorKind = "body"  // ответ целиком
+)
+
+// ResponseExtractor как достать результат из ответа ноды. Пустой экстрактор - json_path по response_direction,
+// а без response_direction - ответ целиком. Строки отдаются как есть (text/plain), остальные значения и несколько
+// совпадений - json текстом с mime application/json
+type ResponseExtractor struct {
+	Kind  ExtractorKind `json:"kind,omitempty"`
+	Expr  string        `json:"expr,omitempty"`
+	Group string        `json:"group,omitempty"` // группа захвата regex: номер или имя, по умолчанию первая группа
+}
+
+// Extractor экстрактор ноды с учетом старого поля response_direction
+func (n Node) Extractor() ResponseExtractor {
+	if n.ResponseExtractor.Kind != "" {
+		return n.ResponseExtractor
+	}
+
+	if n.ResponseDirection == "" {
+		return ResponseExtractor{Kind: BodyExtractor}
+	}
+
+	return ResponseExtractor{Kind: JsonPathExtractor, Expr: n.ResponseDirection}
+}
+
+func (e ResponseExtractor) Validate() error {
+	if e.Kind == "" {
+		if e.Expr != "" || e.Group != "" {
+			return fmt.Errorf("response_extractor: kind is required")
+		}
+		return nil
+	}
+
+	if e.Group != "" && e.Kind != RegexExtractor {
+		return fmt.Errorf("response_extractor: group is allowed only for regex")
+	}
+
+	switch e.Kind {
+	case BodyExtractor:
+		if e.Expr != "" {
+			return fmt.Errorf("response_extractor: body extractor has no expr")
+		}
+		return nil
+	case JsonPathExtractor, JmesPathExtractor, XPathExtractor, RegexExtractor:
+	default:
+		return fmt.Errorf("response_extractor: unknown kind %s", e.Kind)
+	}
+
+	if e.Expr == "" {
+		return fmt.Errorf("response_extractor: %s expr is required", e.Kind)
+	}
+
+	var err error
+	switch e.Kind {
+	case JsonPathExtractor:
+		_, err = parseJsonPath(e.Expr)
+	case JmesPathExtractor:
+		_, err = jmespath.Compile(e.Expr)
+	case XPathExtractor:
+		_, err = xpath.Compile(e.Expr)
+	case RegexExtractor:
+		_, err = e.regexGroup()
+	}
+	if err != nil {
+		return fmt.Errorf("response_extractor: %s: %s", e.Kind, err.Error())
+	}
+
+	return nil
+}
+
+// Extract достает результат и его mime из тела ответа, contentType - заголовок ответа. Ничего не найдено
+// или ответ не в том формате - ошибка, паника разбора тоже возвращается ошибкой, чтобы не уронить воркер
+func (e ResponseExtractor) Extract(body []byte, contentType string) (result string, mime string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s %s: %v", e.Kind, e.Expr, r)
+		}
+	}()
+
+	switch e.Kind {
+	case "", BodyExtractor:
+		if IsJsonMime(contentType) {
+			return string(body), JsonContentType, nil
+		}
+		return string(body), TextContentType, nil
+	case JsonPathExtractor:
+		result, mime, err = e.extractJsonPath(body)
+	case JmesPathExtractor:
+		result, mime, err = e.extractJmesPath(body)
+	case XPathExtractor:
+		result, mime, err = e.extractXPath(body)
+	case RegexExtractor:
+		result, err = e.extractRegex(body)
+		mime = TextContentType
+	default:
+		err = fmt.Errorf("unknown kind")
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("%s %s: %s", e.Kind, e.Expr, err.Error())
+	}
+
+	return result, mime, nil
+}
+
+func (e ResponseExtractor) extractJsonPath(body []byte) (string, string, error) {
+	path, err := parseJsonPath(e.Expr)
+	if err != nil {
+		return "", "", err
+	}
+
+	data, err := decodeJson(body)
+	if err != nil {
+		return "", "", err
+	}
+
+	value, found := path.find(data)
+	if !found {
+		return "", "", fmt.Errorf("value not found in response")
+	}
+
+	return extractedValue(value)
+}
+
+func (e ResponseExtractor) extractJmesPath(body []byte) (string, string, error) {
+	data, err := decodeJson(body)
+	if err != nil {
+		return "", "", err
+	}
+
+	value, err := jmespath.Search(e.Expr, data)
+	if err != nil {
+		return "", "", err
+	}
+	if value == nil {
+		return "", "", fmt.Errorf("value not found in response")
+	}
+
+	return extractedValue(value)
+}
+
+func (e ResponseExtractor) extractXPath(body []byte) (string, string, error) {
+	expr, err := xpath.Compile(e.Expr)
+	if err != nil {
+		return "", "", err
+	}
+
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return "", "", fmt.Errorf("response is not xml: %s", err.Error())
+	}
+
+	switch value := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		values := []interface{}{}
+		for value.MoveNext() {
+			values = append(values, value.Current().Value())
+		}
+
+		switch len(values) {
+		case 0:
+			return "", "", fmt.Errorf("value not found in response")
+		case 1:
+			return extractedValue(values[0])
+		}
+		return extractedValue(values)
+	case string:
+		return value, TextContentType, nil
+	case float64, bool:
+		return extractedValue(value)
+	}
+
+	return "", "", fmt.Errorf("unsupported xpath result")
+}
+
+func (e ResponseExtractor) extractRegex(body []byte) (string, error) {
+	group, err := e.regexGroup()
+	if err != nil {
+		return "", err
+	}
+	re := regexp.MustCompile(e.Expr)
+
+	match := re.FindSubmatchIndex(body)
+	if match == nil || match[2*group] < 0 {
+		return "", fmt.Errorf("no match in response")
+	}
+
+	return string(body[match[2*group]:match[2*group+1]]), nil
+}
+
+// decodeJson числа остаются json.Number, чтобы большие целые не теряли точность
+func decodeJson(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("response is not json")
+	}
+
+	return data, nil
+}
+
+// regexGroup номер группы захвата: указанная по номеру или имени, иначе первая группа, а без групп - совпадение целиком
+func (e ResponseExtractor) regexGroup() (int, error) {
+	re, err := regexp.Compile(e.Expr)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.Group == "" {
+		if re.NumSubexp() > 0 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	group, err := strconv.Atoi(e.Group)
+	if err != nil {
+		group = re.SubexpIndex(e.Group)
+		if group < 0 {
+			return 0, fmt.Errorf("group %s not found in expr", e.Group)
+		}
+	}
+	if group < 0 || group > re.NumSubexp() {
+		return 0, fmt.Errorf("group %d not found in expr", group)
+	}
+
+	return group, nil
+}
+
+// extractedValue строки отдаются как есть, остальное - json
+func extractedValue(value interface{}) (string, string, error) {
+	if str, ok := value.(string); ok {
+		return str, TextContentType, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(raw), JsonContentType, nil
+}
+
+type (
+	// jsonPath путь в формате gojsonq с wildcard: a.b.[0].c, a.[*].c, a.*.c
+	jsonPath []jsonPathSegment
+
+	jsonPathSegment struct {
+		key      string
+		index    int
+		isIndex  bool
+		wildcard bool
+	}
+)
+
+var jsonPathIndexRegexp = regexp.MustCompile(`^(.*?)\[(\*|\d+)\]$`)
+
+func parseJsonPath(expr string) (jsonPath, error) {
+	path := jsonPath{}
+	for _, part := range strings.Split(expr, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("empty segment in path %s", expr)
+		}
+
+		// сегмент вида key[0] - ключ и индекс подряд
+		if match := jsonPathIndexRegexp.FindStringSubmatch(part); match != nil {
+			if match[1] != "" {
+				path = append(path, keySegment(match[1]))
+			}
+			if match[2] == "*" {
+				path = append(path, jsonPathSegment{wildcard: true})
+				continue
+			}
+
+			index, _ := strconv.Atoi(match[2])
+			path = append(path, jsonPathSegment{index: index, isIndex: true})
+			continue
+		}
+
+		if strings.ContainsAny(part, "[]") {
+			return nil, fmt.Errorf("bad segment %s in path %s", part, expr)
+		}
+		path = append(path, keySegment(part))
+	}
+
+	return path, nil
+}
+
+func keySegment(key string) jsonPathSegment {
+	if key == "*" {
+		return jsonPathSegment{wildcard: true}
+	}
+
+	return jsonPathSegment{key: key}
+}
+
+// find после wildcard результат - массив совпадений, элементы без значения по пути пропускаются
+func (p jsonPath) find(data interface{}) (interface{}, bool) {
+	if len(p) == 0 {
+		return data, true
+	}
+
+	segment, rest := p[0], p[1:]
+	if segment.wildcard {
+		children := []interface{}{}
+		switch v := data.(type) {
+		case []interface{}:
+			children = v
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				children = append(children, v[key])
+			}
+		default:
+			return nil, false
+		}
+
+		values := []interface{}{}
+		for _, child := range children {
+			if value, ok := rest.find(child); ok {
+				values = append(values, value)
+			}
+		}
+		return values, true
+	}
+
+	if segment.isIndex {
+		array, ok := data.([]interface{})
+		if !ok || segment.index >= len(array) {
+			return nil, false
+		}
+		return rest.find(array[segment.index])
+	}
+
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := object[segment.key]
+	if !ok || value == nil {
+		return nil, false
+	}
+
+	return rest.find(value)
+}
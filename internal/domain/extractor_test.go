@@ -0,0 +1,240 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResponseExtractorExtract(t *testing.T) {
+	chat := `{"id":12345678901234567890,"choices":[{"message":{"content":"hi"}},{"message":{"content":"there"}}],"usage":{"total":3}}`
+	xml := `<root><item id="1">a</item><item id="2">b</item><count>2</count></root>`
+
+	tests := []struct {
+		name        string
+		extractor   ResponseExtractor
+		body        string
+		contentType string
+		want        string
+		wantMime    string
+		wantErr     string
+	}{
+		{
+			name:        "body json",
+			extractor:   ResponseExtractor{Kind: BodyExtractor},
+			body:        `{"a":1}`,
+			contentType: "application/json; charset=utf-8",
+			want:        `{"a":1}`,
+			wantMime:    JsonContentType,
+		},
+		{
+			name:        "empty kind is body",
+			extractor:   ResponseExtractor{},
+			body:        "plain",
+			contentType: TextContentType,
+			want:        "plain",
+			wantMime:    TextContentType,
+		},
+		{
+			name:      "json_path string",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "choices.[0].message.content"},
+			body:      chat,
+			want:      "hi",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "json_path key with index",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "choices[1].message.content"},
+			body:      chat,
+			want:      "there",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "json_path wildcard",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "choices.[*].message.content"},
+			body:      chat,
+			want:      `["hi","there"]`,
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "json_path object wildcard in key order",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "data.*.url"},
+			body:      `{"data":{"b":{"url":"u2"},"a":{"url":"u1"},"c":{}}}`,
+			want:      `["u1","u2"]`,
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "json_path object",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "usage"},
+			body:      chat,
+			want:      `{"total":3}`,
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "json_path keeps big integers",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "id"},
+			body:      chat,
+			want:      "12345678901234567890",
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "json_path not found",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "choices.[5].message"},
+			body:      chat,
+			wantErr:   "value not found",
+		},
+		{
+			name:      "json_path on non json",
+			extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "a"},
+			body:      "plain",
+			wantErr:   "not json",
+		},
+		{
+			name:      "jmespath",
+			extractor: ResponseExtractor{Kind: JmesPathExtractor, Expr: "choices[].message.content | [1]"},
+			body:      chat,
+			want:      "there",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "jmespath not found",
+			extractor: ResponseExtractor{Kind: JmesPathExtractor, Expr: "missing"},
+			body:      chat,
+			wantErr:   "value not found",
+		},
+		{
+			name:      "xpath one node",
+			extractor: ResponseExtractor{Kind: XPathExtractor, Expr: "//item[@id='2']"},
+			body:      xml,
+			want:      "b",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "xpath several nodes",
+			extractor: ResponseExtractor{Kind: XPathExtractor, Expr: "//item"},
+			body:      xml,
+			want:      `["a","b"]`,
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "xpath number",
+			extractor: ResponseExtractor{Kind: XPathExtractor, Expr: "count(//item)"},
+			body:      xml,
+			want:      "2",
+			wantMime:  JsonContentType,
+		},
+		{
+			name:      "xpath not found",
+			extractor: ResponseExtractor{Kind: XPathExtractor, Expr: "//missing"},
+			body:      xml,
+			wantErr:   "value not found",
+		},
+		{
+			name:      "regex first group",
+			extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `answer: (\w+)`},
+			body:      "the answer: yes, probably",
+			want:      "yes",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "regex named group",
+			extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `(?P<key>\w+)=(?P<value>\w+)`, Group: "value"},
+			body:      "a=b",
+			want:      "b",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "regex whole match without groups",
+			extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `\d+`},
+			body:      "total 42 items",
+			want:      "42",
+			wantMime:  TextContentType,
+		},
+		{
+			name:      "regex no match",
+			extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `\d+`},
+			body:      "none",
+			wantErr:   "no match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mime, err := tt.extractor.Extract([]byte(tt.body), tt.contentType)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Extract() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Extract() unexpected error: %v", err)
+			}
+			if got != tt.want || mime != tt.wantMime {
+				t.Fatalf("Extract() = %q, %q, want %q, %q", got, mime, tt.want, tt.wantMime)
+			}
+		})
+	}
+}
+
+func TestResponseExtractorValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor ResponseExtractor
+		wantErr   string
+	}{
+		{name: "empty", extractor: ResponseExtractor{}},
+		{name: "body", extractor: ResponseExtractor{Kind: BodyExtractor}},
+		{name: "json_path", extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "a.[0].b"}},
+		{name: "regex group by number", extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `(a)(b)`, Group: "2"}},
+		{name: "expr without kind", extractor: ResponseExtractor{Expr: "a"}, wantErr: "kind is required"},
+		{name: "unknown kind", extractor: ResponseExtractor{Kind: "css", Expr: "a"}, wantErr: "unknown kind"},
+		{name: "body with expr", extractor: ResponseExtractor{Kind: BodyExtractor, Expr: "a"}, wantErr: "has no expr"},
+		{name: "missing expr", extractor: ResponseExtractor{Kind: XPathExtractor}, wantErr: "expr is required"},
+		{name: "group outside regex", extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "a", Group: "1"}, wantErr: "only for regex"},
+		{name: "bad json_path", extractor: ResponseExtractor{Kind: JsonPathExtractor, Expr: "a..b"}, wantErr: "empty segment"},
+		{name: "bad jmespath", extractor: ResponseExtractor{Kind: JmesPathExtractor, Expr: "a[?"}, wantErr: "jmespath"},
+		{name: "bad xpath", extractor: ResponseExtractor{Kind: XPathExtractor, Expr: "//["}, wantErr: "xpath"},
+		{name: "bad regex", extractor: ResponseExtractor{Kind: RegexExtractor, Expr: "(a"}, wantErr: "regex"},
+		{name: "regex group out of range", extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `(a)`, Group: "2"}, wantErr: "group 2 not found"},
+		{name: "regex unknown named group", extractor: ResponseExtractor{Kind: RegexExtractor, Expr: `(a)`, Group: "name"}, wantErr: "group name not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.extractor.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNodeExtractor(t *testing.T) {
+	tests := []struct {
+		name string
+		node Node
+		want ResponseExtractor
+	}{
+		{name: "no direction", node: Node{}, want: ResponseExtractor{Kind: BodyExtractor}},
+		{name: "legacy direction", node: Node{ResponseDirection: "a.b"}, want: ResponseExtractor{Kind: JsonPathExtractor, Expr: "a.b"}},
+		{
+			name: "extractor wins over direction",
+			node: Node{ResponseDirection: "a.b", ResponseExtractor: ResponseExtractor{Kind: RegexExtractor, Expr: "x"}},
+			want: ResponseExtractor{Kind: RegexExtractor, Expr: "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.node.Extractor(); got != tt.want {
+				t.Fatalf("Extractor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
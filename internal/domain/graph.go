@@ -0,0 +1,668 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GraphInput зарезервированное имя входа сценария, его можно указывать во входах узлов графа
+const GraphInput = "input"
+
+// MaxMapConcurrency ограничение на количество элементов массива, которые map обрабатывает одновременно
+const MaxMapConcurrency = 32
+
+const (
+	DefaultMapMaxItems = 1000  // сколько элементов map принимает, если max_items не задан
+	MaxMapMaxItems     = 10000 // верхняя граница max_items: под каждый элемент держатся результат и история вызовов
+)
+
+// ScriptRefPrefix в старом формате workflow элемент цепочки с этим префиксом - айди вложенного сценария, а не ноды
+const ScriptRefPrefix = "script:"
+
+type GraphNodeKind string
+
+const (
+	CallGraphNodeKind   GraphNodeKind = "node"   // вызов ноды
+	JoinGraphNodeKind   GraphNodeKind = "join"   // объединение результатов входов без вызова ноды
+	MapGraphNodeKind    GraphNodeKind = "map"    // цепочка нод для каждого элемента массива из входа
+	ScriptGraphNodeKind GraphNodeKind = "script" // вложенный сценарий, его вход - вход узла
+)
+
+type (
+	// Graph сценарий в виде ациклического графа именованных узлов
+	Graph struct {
+		Nodes  []GraphNode `json:"nodes"`
+		Output string      `json:"output,omitempty"` // узел, результат которого - результат сценария, по умолчанию единственный узел без потомков
+
+		legacy bool // граф получен из шагов и цепочек
+	}
+
+	// GraphNode узел графа. Узел запускается, как только готовы все его входы,
+	// на вход приходят результаты входов по порядку, пропущенные входы не учитываются.
+	// Узел пропускается, если пропущены все его входы или не выполнилось условие
+	GraphNode struct {
+		Name      string        `json:"name"`
+		Kind      GraphNodeKind `json:"kind,omitempty"`
+		NodeId    string        `json:"node_id,omitempty"`
+		Fallbacks []string      `json:"fallbacks,omitempty"` // для node: запасные ноды по порядку, если основная не ответила
+		ScriptId  string        `json:"script_id,omitempty"` // для script: айди вложенного сценария
+		Inputs    []string      `json:"inputs,omitempty"`    // без входов узел получает вход сценария
+		Otherwise string        `json:"otherwise,omitempty"` // для join: чей результат отдать, если все входы пропущены
+		When      *Condition    `json:"when,omitempty"`      // источник условия - имя узла-предка в OutputRef.Node
+		Each      []string      `json:"each,omitempty"`      // для map: айди нод, которые по очереди обрабатывают элемент
+		Map       *MapOptions   `json:"map,omitempty"`
+		Merge     *MergeOptions `json:"merge,omitempty"` // для join: как объединить результаты входов
+		OnError   *ErrorPolicy  `json:"on_error,omitempty"`
+
+		// Координаты узла в истории и событиях запуска. Для старого формата это шаг, цепочка
+		// и позиция ноды в цепочке, для графа - глубина узла и его номер на этой глубине
+		Step     int  `json:"-"`
+		Chain    *int `json:"-"`
+		Position int  `json:"-"`
+
+		// Для старого формата: нода в середине цепочки при ошибке не подставляет значение по умолчанию,
+		// а пропускается, и значение подставляет последняя нода цепочки
+		PassError bool `json:"-"`
+	}
+)
+
+// MapOptions настройки обработки массива. Результат map - json массив результатов в порядке элементов
+type MapOptions struct {
+	ItemsPath   string `json:"items_path,omitempty"`  // путь gojsonq до массива во входе, пусто - вход и есть массив
+	Concurrency int    `json:"concurrency,omitempty"` // сколько элементов обрабатывается одновременно, 0 - все сразу (но не больше MaxMapConcurrency)
+	MaxItems    int    `json:"max_items,omitempty"`   // массив длиннее - ошибка узла, 0 - DefaultMapMaxItems
+}
+
+// Limit сколько элементов map принимает
+func (o MapOptions) Limit() int {
+	if o.MaxItems == 0 {
+		return DefaultMapMaxItems
+	}
+
+	return o.MaxItems
+}
+
+// MergeKey ключ входа для объединения в json объект
+func (n GraphNode) MergeKey(input int) string {
+	if n.Merge != nil && len(n.Merge.Keys) != 0 {
+		return n.Merge.Keys[input]
+	}
+
+	return n.Inputs[input]
+}
+
+func (n GraphNode) IsJoin() bool {
+	return n.Kind == JoinGraphNodeKind
+}
+
+func (n GraphNode) IsMap() bool {
+	return n.Kind == MapGraphNodeKind
+}
+
+func (n GraphNode) IsScript() bool {
+	return n.Kind == ScriptGraphNodeKind
+}
+
+// RunStep заготовка записи истории для вызова ноды внутри узла
+func (n GraphNode) RunStep(name string, position int) RunStep {
+	step := RunStep{
+		Step:      n.Step,
+		Position:  position,
+		GraphNode: name,
+	}
+	if n.Chain != nil {
+		step.Chain = *n.Chain
+	}
+
+	return step
+}
+
+// Dependencies узлы, которые должны завершиться до запуска узла
+func (n GraphNode) Dependencies() []string {
+	deps := make([]string, 0, len(n.Inputs)+1)
+	for _, input := range n.Inputs {
+		if input != GraphInput {
+			deps = append(deps, input)
+		}
+	}
+
+	if n.Otherwise != "" && n.Otherwise != GraphInput {
+		deps = append(deps, n.Otherwise)
+	}
+
+	return deps
+}
+
+func (g Graph) Empty() bool {
+	return len(g.Nodes) == 0
+}
+
+// NodeIds айди нод, которые вызывает граф
+func (g Graph) NodeIds() []string {
+	ids := []string{}
+	for _, node := range g.Nodes {
+		if node.NodeId != "" {
+			ids = append(ids, node.NodeId)
+		}
+		ids = append(ids, node.Fallbacks...)
+		ids = append(ids, node.Each...)
+	}
+
+	return ids
+}
+
+// ScriptIds айди вложенных сценариев графа
+func (g Graph) ScriptIds() []string {
+	ids := []string{}
+	for _, node := range g.Nodes {
+		if node.ScriptId != "" {
+			ids = append(ids, node.ScriptId)
+		}
+	}
+
+	return ids
+}
+
+// Validate проверяет структуру графа, отсутствие циклов и ссылки условий.
+// Возвращает имена узлов в порядке топологической сортировки
+func (g Graph) Validate() ([]string, error) {
+	if g.Empty() {
+		return nil, fmt.Errorf("graph should have at least one node")
+	}
+
+	nodes := make(map[string]GraphNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		if node.Name == "" {
+			return nil, fmt.Errorf("graph node name can't be empty")
+		}
+		if node.Name == GraphInput {
+			return nil, fmt.Errorf("graph node name %s is reserved for script input", GraphInput)
+		}
+		if _, ok := nodes[node.Name]; ok {
+			return nil, fmt.Errorf("duplicated graph node name %s", node.Name)
+		}
+
+		nodes[node.Name] = node
+	}
+
+	for _, node := range g.Nodes {
+		if err := node.validate(nodes); err != nil {
+			return nil, fmt.Errorf("graph node %s: %s", node.Name, err.Error())
+		}
+	}
+
+	order, err := g.topologicalOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range g.Nodes {
+		if node.When == nil || node.When.Source == nil {
+			continue
+		}
+
+		if !g.isAncestor(nodes, node.When.Source.Node, node.Name) {
+			return nil, fmt.Errorf("graph node %s: condition source %s should be an ancestor of the node", node.Name, node.When.Source.Node)
+		}
+	}
+
+	if _, err := g.OutputNode(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+func (n GraphNode) validate(nodes map[string]GraphNode) error {
+	switch n.Kind {
+	case "", CallGraphNodeKind:
+		if n.NodeId == "" {
+			return fmt.Errorf("node_id is required")
+		}
+	case JoinGraphNodeKind:
+		if n.NodeId != "" {
+			return fmt.Errorf("join can't call node")
+		}
+		if len(n.Inputs) == 0 {
+			return fmt.Errorf("join should have inputs")
+		}
+	case MapGraphNodeKind:
+		if n.NodeId != "" {
+			return fmt.Errorf("map calls nodes from each, node_id is not allowed")
+		}
+		if len(n.Each) == 0 {
+			return fmt.Errorf("map should have at least one node in each")
+		}
+		if n.Map != nil && (n.Map.Concurrency < 0 || n.Map.Concurrency > MaxMapConcurrency) {
+			return fmt.Errorf("map concurrency should be between 0 and %d", MaxMapConcurrency)
+		}
+		if n.Map != nil && (n.Map.MaxItems < 0 || n.Map.MaxItems > MaxMapMaxItems) {
+			return fmt.Errorf("map max_items should be between 0 and %d", MaxMapMaxItems)
+		}
+	case ScriptGraphNodeKind:
+		if n.ScriptId == "" {
+			return fmt.Errorf("script_id is required")
+		}
+		if n.NodeId != "" {
+			return fmt.Errorf("script can't call node")
+		}
+	default:
+		return fmt.Errorf("unknown kind %s", n.Kind)
+	}
+
+	if !n.IsScript() && n.ScriptId != "" {
+		return fmt.Errorf("script_id is allowed only for script")
+	}
+
+	if !n.IsMap() && (len(n.Each) != 0 || n.Map != nil) {
+		return fmt.Errorf("each and map are allowed only for map")
+	}
+	if !n.IsJoin() && (n.Otherwise != "" || n.Merge != nil) {
+		return fmt.Errorf("otherwise and merge are allowed only for join")
+	}
+
+	if err := n.validateFallbacks(); err != nil {
+		return err
+	}
+
+	if n.IsJoin() && n.OnError != nil {
+		return fmt.Errorf("join can't fail, on_error is not allowed")
+	}
+	if err := n.OnError.Validate(); err != nil {
+		return err
+	}
+
+	if err := n.Merge.Validate(len(n.Inputs)); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(n.Inputs))
+	for _, input := range n.Inputs {
+		if seen[input] {
+			return fmt.Errorf("duplicated input %s", input)
+		}
+		seen[input] = true
+
+		if _, ok := nodes[input]; !ok && input != GraphInput {
+			return fmt.Errorf("unknown input %s", input)
+		}
+	}
+
+	if _, ok := nodes[n.Otherwise]; n.Otherwise != "" && !ok && n.Otherwise != GraphInput {
+		return fmt.Errorf("unknown otherwise node %s", n.Otherwise)
+	}
+
+	if n.When != nil {
+		if err := n.When.Validate(); err != nil {
+			return err
+		}
+
+		if n.When.Source != nil {
+			if _, ok := nodes[n.When.Source.Node]; !ok {
+				return fmt.Errorf("condition references unknown node %s", n.When.Source.Node)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (n GraphNode) validateFallbacks() error {
+	if len(n.Fallbacks) == 0 {
+		return nil
+	}
+
+	if n.Kind != "" && n.Kind != CallGraphNodeKind {
+		return fmt.Errorf("fallbacks are allowed only for node")
+	}
+
+	seen := map[string]bool{n.NodeId: true}
+	for _, fallback := range n.Fallbacks {
+		if fallback == "" {
+			return fmt.Errorf("fallback node id can't be empty")
+		}
+		if seen[fallback] {
+			return fmt.Errorf("fallback %s duplicates node or another fallback", fallback)
+		}
+		seen[fallback] = true
+	}
+
+	return nil
+}
+
+// topologicalOrder сортировка Кана, узлы одного уровня идут в порядке объявления
+func (g Graph) topologicalOrder() ([]string, error) {
+	inDegree := make(map[string]int, len(g.Nodes))
+	dependents := make(map[string][]string, len(g.Nodes))
+	for _, node := range g.Nodes {
+		deps := node.Dependencies()
+		inDegree[node.Name] = len(deps)
+		for _, dep := range deps {
+			dependents[dep] = append(dependents[dep], node.Name)
+		}
+	}
+
+	queue := []string{}
+	for _, node := range g.Nodes {
+		if inDegree[node.Name] == 0 {
+			queue = append(queue, node.Name)
+		}
+	}
+
+	order := make([]string, 0, len(g.Nodes))
+	for len(queue) != 0 {
+		name := queue[0]
+		queue = queue[1:]
+		order = append(order, name)
+
+		for _, dependent := range dependents[name] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	if len(order) != len(g.Nodes) {
+		cycle := []string{}
+		for _, node := range g.Nodes {
+			if inDegree[node.Name] > 0 {
+				cycle = append(cycle, node.Name)
+			}
+		}
+		sort.Strings(cycle)
+
+		return nil, fmt.Errorf("graph has a cycle through nodes [%s]", strings.Join(cycle, ", "))
+	}
+
+	return order, nil
+}
+
+// IsAncestor узел ancestor гарантированно завершается до запуска узла name
+func (g Graph) IsAncestor(ancestor, name string) bool {
+	nodes := make(map[string]GraphNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.Name] = node
+	}
+
+	return g.isAncestor(nodes, ancestor, name)
+}
+
+func (g Graph) isAncestor(nodes map[string]GraphNode, ancestor, name string) bool {
+	visited := make(map[string]bool)
+	stack := nodes[name].Dependencies()
+	for len(stack) != 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if current == ancestor {
+			return true
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		stack = append(stack, nodes[current].Dependencies()...)
+	}
+
+	return false
+}
+
+// OutputNode имя узла, результат которого возвращается из сценария
+func (g Graph) OutputNode() (string, error) {
+	if g.Output != "" {
+		for _, node := range g.Nodes {
+			if node.Name == g.Output {
+				return g.Output, nil
+			}
+		}
+
+		return "", fmt.Errorf("unknown graph output node %s", g.Output)
+	}
+
+	hasDependents := make(map[string]bool, len(g.Nodes))
+	for _, node := range g.Nodes {
+		for _, dep := range node.Dependencies() {
+			hasDependents[dep] = true
+		}
+	}
+
+	sinks := []string{}
+	for _, node := range g.Nodes {
+		if !hasDependents[node.Name] {
+			sinks = append(sinks, node.Name)
+		}
+	}
+
+	if len(sinks) != 1 {
+		return "", fmt.Errorf("graph has %d nodes without dependents, output should be set explicitly", len(sinks))
+	}
+
+	return sinks[0], nil
+}
+
+// StreamNode узел, результат которого без изменений становится результатом сценария: узел выхода или
+// единственный вход join узла выхода (так устроен последний шаг старого формата). Только такой узел
+// может передавать клиенту ответ ноды по мере его получения
+func (g Graph) StreamNode() (string, bool) {
+	name, err := g.OutputNode()
+	if err != nil {
+		return "", false
+	}
+
+	nodes := make(map[string]GraphNode, len(g.Nodes))
+	for _, node := range g.Nodes {
+		nodes[node.Name] = node
+	}
+
+	for {
+		node := nodes[name]
+		if node.Kind == "" || node.Kind == CallGraphNodeKind {
+			return name, true
+		}
+
+		if !node.IsJoin() || len(node.Inputs) != 1 || node.Inputs[0] == GraphInput {
+			return "", false
+		}
+		if node.Merge != nil && (node.Merge.Strategy == ArrayMergeStrategy || node.Merge.Strategy == ObjectMergeStrategy) {
+			return "", false
+		}
+
+		name = node.Inputs[0]
+	}
+}
+
+// withLayout проставляет координаты узлов графа: шаг - длина самого длинного пути от входа сценария,
+// цепочка - номер узла среди узлов того же шага в порядке объявления
+func (g Graph) withLayout(order []string) Graph {
+	index := make(map[string]int, len(g.Nodes))
+	for i, node := range g.Nodes {
+		index[node.Name] = i
+	}
+
+	depth := make(map[string]int, len(g.Nodes))
+	for _, name := range order {
+		for _, dep := range g.Nodes[index[name]].Dependencies() {
+			if depth[dep]+1 > depth[name] {
+				depth[name] = depth[dep] + 1
+			}
+		}
+	}
+
+	nodes := make([]GraphNode, len(g.Nodes))
+	chains := make(map[int]int)
+	for i, node := range g.Nodes {
+		chain := chains[depth[node.Name]]
+		chains[depth[node.Name]]++
+
+		node.Step = depth[node.Name]
+		node.Chain = &chain
+		node.Position = 0
+		nodes[i] = node
+	}
+
+	return Graph{Nodes: nodes, Output: g.Output, legacy: g.legacy}
+}
+
+// legacyMerge ключи цепочек для json_object - их имена, а без имени - номера
+func legacyMerge(stepOptions StepOptions, chains int) *MergeOptions {
+	if stepOptions.Merge == nil || stepOptions.Merge.Strategy != ObjectMergeStrategy || len(stepOptions.Merge.Keys) != 0 {
+		return stepOptions.Merge
+	}
+
+	merge := *stepOptions.Merge
+	merge.Keys = make([]string, chains)
+	for i := range merge.Keys {
+		merge.Keys[i] = strconv.Itoa(i)
+		if name := stepOptions.Chains[i].Name; name != "" {
+			merge.Keys[i] = name
+		}
+	}
+
+	return &merge
+}
+
+func legacyChainNodeName(step, chain, position int) string {
+	return fmt.Sprintf("step%d.chain%d.%d", step, chain, position)
+}
+
+func legacyStepNodeName(step int) string {
+	return fmt.Sprintf("step%d", step)
+}
+
+// LegacyGraph переводит workflow из шагов и цепочек в граф. Цепочки шага получают на вход результат
+// предыдущего шага, результаты цепочек объединяются в join шага. Если шаг пропущен целиком,
+// его join отдает дальше результат предыдущего шага
+func LegacyGraph(workflow map[int]map[int][]string, options ScriptOptions) (Graph, error) {
+	stepKeys := make([]int, 0, len(workflow))
+	for key := range workflow {
+		stepKeys = append(stepKeys, key)
+	}
+	sort.Ints(stepKeys)
+
+	// последняя нода каждой цепочки, на нее ссылаются условия по результату цепочки
+	chainEnds := make(map[int]map[int]string, len(workflow))
+	for _, stepKey := range stepKeys {
+		chainEnds[stepKey] = make(map[int]string)
+		for chainKey, chain := range workflow[stepKey] {
+			end := len(chain) - 1
+			if options.Step(stepKey).Chains[chainKey].Map != nil {
+				end = 0
+			}
+			chainEnds[stepKey][chainKey] = legacyChainNodeName(stepKey, chainKey, end)
+		}
+	}
+
+	convertCondition := func(cond *Condition) *Condition {
+		if cond == nil || cond.Source == nil {
+			return cond
+		}
+
+		converted := *cond
+		source := *cond.Source
+		if source.Chain != nil {
+			source.Node = chainEnds[source.Step][*source.Chain]
+		} else {
+			source.Node = legacyStepNodeName(source.Step)
+		}
+		converted.Source = &source
+
+		return &converted
+	}
+
+	nodes := []GraphNode{}
+	prev := GraphInput
+	for _, stepKey := range stepKeys {
+		step := workflow[stepKey]
+		stepOptions := options.Step(stepKey)
+
+		// условие шага проверяется отдельным узлом перед цепочками
+		stepInput := prev
+		if stepOptions.When != nil {
+			stepInput = legacyStepNodeName(stepKey) + ".when"
+			nodes = append(nodes, GraphNode{
+				Name:   stepInput,
+				Kind:   JoinGraphNodeKind,
+				Inputs: []string{prev},
+				When:   convertCondition(stepOptions.When),
+				Step:   stepKey,
+			})
+		}
+
+		ends := []string{}
+		for chainKey := 0; chainKey < len(step); chainKey++ {
+			chain, ok := step[chainKey]
+			if !ok {
+				return Graph{}, fmt.Errorf("step %d: chain %d not found", stepKey, chainKey)
+			}
+
+			// цепочка-map целиком становится одним узлом, ноды цепочки обрабатывают каждый элемент
+			if mapOptions := stepOptions.Chains[chainKey].Map; mapOptions != nil {
+				chainIdx := chainKey
+				node := GraphNode{
+					Name:    legacyChainNodeName(stepKey, chainKey, 0),
+					Kind:    MapGraphNodeKind,
+					Inputs:  []string{stepInput},
+					When:    convertCondition(stepOptions.Chains[chainKey].When),
+					Each:    chain,
+					Map:     mapOptions,
+					OnError: stepOptions.ChainErrorPolicy(chainKey),
+					Step:    stepKey,
+					Chain:   &chainIdx,
+				}
+
+				nodes = append(nodes, node)
+				ends = append(ends, node.Name)
+				continue
+			}
+
+			// политика относится к цепочке целиком: упавшая нода пропускается вместе с остальными нодами цепочки,
+			// а значение по умолчанию подставляет последняя нода
+			chainPolicy := stepOptions.ChainErrorPolicy(chainKey)
+			input := stepInput
+			for position, nodeId := range chain {
+				chainIdx := chainKey
+				node := GraphNode{
+					Name:      legacyChainNodeName(stepKey, chainKey, position),
+					Kind:      CallGraphNodeKind,
+					NodeId:    nodeId,
+					Fallbacks: stepOptions.Chains[chainKey].Fallbacks[position],
+					Inputs:    []string{input},
+					Step:      stepKey,
+					Chain:     &chainIdx,
+					Position:  position,
+				}
+				if scriptId, ok := strings.CutPrefix(nodeId, ScriptRefPrefix); ok {
+					node.Kind = ScriptGraphNodeKind
+					node.NodeId = ""
+					node.ScriptId = scriptId
+				}
+				if position == 0 {
+					node.When = convertCondition(stepOptions.Chains[chainKey].When)
+				}
+				node.OnError = chainPolicy
+				node.PassError = position != len(chain)-1
+
+				nodes = append(nodes, node)
+				input = node.Name
+			}
+
+			ends = append(ends, input)
+		}
+
+		nodes = append(nodes, GraphNode{
+			Name:      legacyStepNodeName(stepKey),
+			Kind:      JoinGraphNodeKind,
+			Inputs:    ends,
+			Otherwise: prev,
+			Merge:     legacyMerge(stepOptions, len(step)),
+			Step:      stepKey,
+		})
+		prev = legacyStepNodeName(stepKey)
+	}
+
+	return Graph{Nodes: nodes, Output: prev, legacy: true}, nil
+}
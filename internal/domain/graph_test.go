@@ -0,0 +1,413 @@
+package domain
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGraphValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		graph   Graph
+		order   []string
+		wantErr string
+	}{
+		{
+			name:    "empty graph",
+			graph:   Graph{},
+			wantErr: "at least one node",
+		},
+		{
+			name: "chain",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "c", NodeId: "3", Inputs: []string{"b"}},
+				{Name: "a", NodeId: "1"},
+				{Name: "b", NodeId: "2", Inputs: []string{"a"}},
+			}},
+			order: []string{"a", "b", "c"},
+		},
+		{
+			name: "diamond keeps declaration order on one level",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "c", NodeId: "3", Inputs: []string{"a"}},
+				{Name: "b", NodeId: "2", Inputs: []string{"a"}},
+				{Name: "d", Kind: JoinGraphNodeKind, Inputs: []string{"b", "c"}},
+			}},
+			order: []string{"a", "c", "b", "d"},
+		},
+		{
+			name: "otherwise is a dependency",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a"}, Otherwise: "b"},
+				{Name: "a", NodeId: "1", Inputs: []string{GraphInput}},
+				{Name: "b", NodeId: "2"},
+			}},
+			order: []string{"a", "b", "j"},
+		},
+		{
+			name: "cycle",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1", Inputs: []string{"b"}},
+				{Name: "b", NodeId: "2", Inputs: []string{"a"}},
+				{Name: "c", NodeId: "3"},
+			}},
+			wantErr: "cycle through nodes [a, b]",
+		},
+		{
+			name: "self loop",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1", Inputs: []string{"a"}},
+			}},
+			wantErr: "cycle through nodes [a]",
+		},
+		{
+			name: "duplicated name",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "a", NodeId: "2"},
+			}},
+			wantErr: "duplicated graph node name a",
+		},
+		{
+			name: "reserved name",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: GraphInput, NodeId: "1"},
+			}},
+			wantErr: "reserved",
+		},
+		{
+			name: "unknown input",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1", Inputs: []string{"b"}},
+			}},
+			wantErr: "unknown input b",
+		},
+		{
+			name: "duplicated input",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "b", NodeId: "2", Inputs: []string{"a", "a"}},
+			}},
+			wantErr: "duplicated input a",
+		},
+		{
+			name: "node without node_id",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a"},
+			}},
+			wantErr: "node_id is required",
+		},
+		{
+			name: "join without inputs",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: JoinGraphNodeKind},
+			}},
+			wantErr: "join should have inputs",
+		},
+		{
+			name: "join with on_error",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a"}, OnError: &ErrorPolicy{}},
+			}},
+			wantErr: "on_error is not allowed",
+		},
+		{
+			name: "map without each",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: MapGraphNodeKind},
+			}},
+			wantErr: "at least one node in each",
+		},
+		{
+			name: "map concurrency over limit",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: MapGraphNodeKind, Each: []string{"1"}, Map: &MapOptions{Concurrency: MaxMapConcurrency + 1}},
+			}},
+			wantErr: "map concurrency",
+		},
+		{
+			name: "map max items over limit",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: MapGraphNodeKind, Each: []string{"1"}, Map: &MapOptions{MaxItems: MaxMapMaxItems + 1}},
+			}},
+			wantErr: "map max_items",
+		},
+		{
+			name: "each outside of map",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1", Each: []string{"2"}},
+			}},
+			wantErr: "allowed only for map",
+		},
+		{
+			name: "script without script_id",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: ScriptGraphNodeKind},
+			}},
+			wantErr: "script_id is required",
+		},
+		{
+			name: "unknown kind",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", Kind: "loop", NodeId: "1"},
+			}},
+			wantErr: "unknown kind loop",
+		},
+		{
+			name: "fallback duplicates node",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1", Fallbacks: []string{"2", "1"}},
+			}},
+			wantErr: "fallback 1 duplicates",
+		},
+		{
+			name: "fallbacks on join",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a"}, Fallbacks: []string{"2"}},
+			}},
+			wantErr: "fallbacks are allowed only for node",
+		},
+		{
+			name: "condition source is not an ancestor",
+			graph: Graph{
+				Nodes: []GraphNode{
+					{Name: "a", NodeId: "1"},
+					{Name: "b", NodeId: "2", When: &Condition{Source: &OutputRef{Node: "a"}, Op: ExistsConditionOp}},
+					{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a", "b"}},
+				},
+			},
+			wantErr: "should be an ancestor",
+		},
+		{
+			name: "several sinks without output",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "b", NodeId: "2"},
+			}},
+			wantErr: "output should be set explicitly",
+		},
+		{
+			name: "several sinks with output",
+			graph: Graph{
+				Nodes: []GraphNode{
+					{Name: "a", NodeId: "1"},
+					{Name: "b", NodeId: "2"},
+				},
+				Output: "b",
+			},
+			order: []string{"a", "b"},
+		},
+		{
+			name: "unknown output",
+			graph: Graph{
+				Nodes:  []GraphNode{{Name: "a", NodeId: "1"}},
+				Output: "b",
+			},
+			wantErr: "unknown graph output node b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := tt.graph.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			if !slices.Equal(order, tt.order) {
+				t.Fatalf("Validate() order = %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
+
+func TestGraphIsAncestor(t *testing.T) {
+	graph := Graph{Nodes: []GraphNode{
+		{Name: "a", NodeId: "1"},
+		{Name: "b", NodeId: "2", Inputs: []string{"a"}},
+		{Name: "c", NodeId: "3"},
+		{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"b"}, Otherwise: "c"},
+	}}
+
+	tests := []struct {
+		ancestor string
+		name     string
+		want     bool
+	}{
+		{ancestor: "a", name: "b", want: true},
+		{ancestor: "a", name: "j", want: true},
+		{ancestor: "c", name: "j", want: true},
+		{ancestor: "b", name: "a", want: false},
+		{ancestor: "c", name: "b", want: false},
+		{ancestor: "a", name: "a", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ancestor+"->"+tt.name, func(t *testing.T) {
+			if got := graph.IsAncestor(tt.ancestor, tt.name); got != tt.want {
+				t.Fatalf("IsAncestor(%s, %s) = %v, want %v", tt.ancestor, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphStreamNode(t *testing.T) {
+	tests := []struct {
+		name   string
+		graph  Graph
+		want   string
+		wantOk bool
+	}{
+		{
+			name: "output node calls node",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "b", NodeId: "2", Inputs: []string{"a"}},
+			}},
+			want:   "b",
+			wantOk: true,
+		},
+		{
+			name: "join of one input passes through",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a"}},
+			}},
+			want:   "a",
+			wantOk: true,
+		},
+		{
+			name: "join of several inputs",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "b", NodeId: "2"},
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a", "b"}},
+			}},
+		},
+		{
+			name: "join wraps result into array",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "a", NodeId: "1"},
+				{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a"}, Merge: &MergeOptions{Strategy: ArrayMergeStrategy}},
+			}},
+		},
+		{
+			name: "map output",
+			graph: Graph{Nodes: []GraphNode{
+				{Name: "m", Kind: MapGraphNodeKind, Each: []string{"1"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.graph.StreamNode()
+			if got != tt.want || ok != tt.wantOk {
+				t.Fatalf("StreamNode() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGraphNodeIds(t *testing.T) {
+	graph := Graph{Nodes: []GraphNode{
+		{Name: "a", NodeId: "1", Fallbacks: []string{"2", "3"}},
+		{Name: "m", Kind: MapGraphNodeKind, Each: []string{"4", "5"}},
+		{Name: "s", Kind: ScriptGraphNodeKind, ScriptId: "s1"},
+		{Name: "j", Kind: JoinGraphNodeKind, Inputs: []string{"a", "m", "s"}},
+	}}
+
+	if got, want := graph.NodeIds(), []string{"1", "2", "3", "4", "5"}; !slices.Equal(got, want) {
+		t.Fatalf("NodeIds() = %v, want %v", got, want)
+	}
+	if got, want := graph.ScriptIds(), []string{"s1"}; !slices.Equal(got, want) {
+		t.Fatalf("ScriptIds() = %v, want %v", got, want)
+	}
+}
+
+func TestLegacyGraph(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name     string
+		workflow map[int]map[int][]string
+		options  ScriptOptions
+		order    []string
+		wantErr  string
+	}{
+		{
+			name: "steps and chains",
+			workflow: map[int]map[int][]string{
+				1: {0: {"n1", "n2"}},
+				2: {0: {"n3"}, 1: {"script:s1"}},
+			},
+			order: []string{
+				"step1.chain0.0", "step1.chain0.1", "step1",
+				"step2.chain0.0", "step2.chain1.0", "step2",
+			},
+		},
+		{
+			name: "step condition by chain result",
+			workflow: map[int]map[int][]string{
+				1: {0: {"n1", "n2"}},
+				2: {0: {"n3"}},
+			},
+			options: ScriptOptions{Steps: map[int]StepOptions{
+				2: {When: &Condition{Source: &OutputRef{Step: 1, Chain: &zero}, Op: ExistsConditionOp}},
+			}},
+			order: []string{
+				"step1.chain0.0", "step1.chain0.1", "step1",
+				"step2.when", "step2.chain0.0", "step2",
+			},
+		},
+		{
+			name: "map chain is one node",
+			workflow: map[int]map[int][]string{
+				1: {0: {"n1", "n2"}},
+			},
+			options: ScriptOptions{Steps: map[int]StepOptions{
+				1: {Chains: map[int]ChainOptions{0: {Map: &MapOptions{}}}},
+			}},
+			order: []string{"step1.chain0.0", "step1"},
+		},
+		{
+			name: "missing chain",
+			workflow: map[int]map[int][]string{
+				1: {1: {"n1"}},
+			},
+			wantErr: "chain 0 not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := LegacyGraph(tt.workflow, tt.options)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LegacyGraph() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LegacyGraph() unexpected error: %v", err)
+			}
+
+			order, err := graph.Validate()
+			if err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			if !slices.Equal(order, tt.order) {
+				t.Fatalf("order = %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
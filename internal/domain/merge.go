@@ -0,0 +1,149 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type MergeStrategy string
+
+const (
+	JoinMergeStrategy   MergeStrategy = "join"        // склейка через разделитель
+	ArrayMergeStrategy  MergeStrategy = "json_array"  // json массив результатов, json результаты - значениями
+	ObjectMergeStrategy MergeStrategy = "json_object" // json объект, ключ - имя входа, json результаты - значениями
+	FirstMergeStrategy  MergeStrategy = "first"       // первый успешный результат
+	VoteMergeStrategy   MergeStrategy = "vote"        // самый частый результат, при равенстве - первый из них
+)
+
+// DefaultMergeSeparator разделитель, которым результаты склеиваются по умолчанию
+const DefaultMergeSeparator = ". "
+
+type (
+	// MergeOptions как объединить результаты входов. Результаты всегда идут в порядке входов
+	// (для шагов - в порядке номеров цепочек), пропущенные входы не учитываются
+	MergeOptions struct {
+		Strategy  MergeStrategy `json:"strategy"`
+		Separator *string       `json:"separator,omitempty"` // для join, по умолчанию ". "
+		Keys      []string      `json:"keys,omitempty"`      // для json_object: ключи входов по порядку, по умолчанию имена входов
+	}
+
+	MergeInput struct {
+		Key    string
+		Output string
+		Mime   string
+	}
+)
+
+func (m *MergeOptions) Validate(inputs int) error {
+	if m == nil {
+		return nil
+	}
+
+	switch m.Strategy {
+	case JoinMergeStrategy, ArrayMergeStrategy, FirstMergeStrategy, VoteMergeStrategy:
+	case ObjectMergeStrategy:
+		if len(m.Keys) != 0 && len(m.Keys) != inputs {
+			return fmt.Errorf("merge: keys count should be equal to inputs count")
+		}
+
+		for i, key := range m.Keys {
+			if key == "" {
+				return fmt.Errorf("merge: key can't be empty")
+			}
+			if slices.Contains(m.Keys[:i], key) {
+				return fmt.Errorf("merge: duplicated key %s", key)
+			}
+		}
+	default:
+		return fmt.Errorf("merge: unknown strategy %s", m.Strategy)
+	}
+
+	if m.Separator != nil && m.Strategy != JoinMergeStrategy {
+		return fmt.Errorf("merge: separator is allowed only for join strategy")
+	}
+	if len(m.Keys) != 0 && m.Strategy != ObjectMergeStrategy {
+		return fmt.Errorf("merge: keys are allowed only for json_object strategy")
+	}
+
+	return nil
+}
+
+// Merge объединяет результаты входов. Без настроек результаты склеиваются через DefaultMergeSeparator
+func (m *MergeOptions) Merge(inputs []MergeInput) (string, string, error) {
+	if len(inputs) == 0 {
+		return "", "", fmt.Errorf("merge: nothing to merge")
+	}
+
+	strategy := JoinMergeStrategy
+	if m != nil {
+		strategy = m.Strategy
+	}
+
+	switch strategy {
+	case ArrayMergeStrategy:
+		outputs := make([]interface{}, len(inputs))
+		for i, input := range inputs {
+			outputs[i] = TypedValue(input.Output, input.Mime)
+		}
+
+		raw, err := json.Marshal(outputs)
+		return string(raw), JsonContentType, err
+	case ObjectMergeStrategy:
+		outputs := make(map[string]interface{}, len(inputs))
+		for _, input := range inputs {
+			outputs[input.Key] = TypedValue(input.Output, input.Mime)
+		}
+
+		raw, err := json.Marshal(outputs)
+		return string(raw), JsonContentType, err
+	case FirstMergeStrategy:
+		return inputs[0].Output, inputs[0].Mime, nil
+	case VoteMergeStrategy:
+		return vote(inputs)
+	}
+
+	separator := DefaultMergeSeparator
+	if m != nil && m.Separator != nil {
+		separator = *m.Separator
+	}
+
+	outputs := make([]string, len(inputs))
+	for i, input := range inputs {
+		outputs[i] = input.Output
+	}
+
+	// склейка нескольких json уже не json
+	mime := inputs[0].Mime
+	if len(inputs) > 1 {
+		mime = TextContentType
+	}
+
+	return strings.Join(outputs, separator), mime, nil
+}
+
+// vote ответы сравниваются без учета пробелов по краям, из равных по частоте побеждает раньше встреченный
+func vote(inputs []MergeInput) (string, string, error) {
+	counts := make(map[string]int, len(inputs))
+	first := make(map[string]int, len(inputs))
+	for i, input := range inputs {
+		answer := strings.TrimSpace(input.Output)
+		if _, ok := first[answer]; !ok {
+			first[answer] = i
+		}
+		counts[answer]++
+	}
+
+	// победитель выбирается только после подсчета всех ответов, иначе при равенстве побеждал бы
+	// ответ, который последним вырвался вперед
+	best := strings.TrimSpace(inputs[0].Output)
+	for answer, count := range counts {
+		if count > counts[best] || (count == counts[best] && first[answer] < first[best]) {
+			best = answer
+		}
+	}
+
+	winner := inputs[first[best]]
+	return winner.Output, winner.Mime, nil
+}
@@ -0,0 +1,177 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeOptionsMerge(t *testing.T) {
+	separator := " | "
+	text := func(outputs ...string) []MergeInput {
+		inputs := make([]MergeInput, len(outputs))
+		for i, output := range outputs {
+			inputs[i] = MergeInput{Key: string(rune('a' + i)), Output: output, Mime: TextContentType}
+		}
+		return inputs
+	}
+
+	tests := []struct {
+		name     string
+		options  *MergeOptions
+		inputs   []MergeInput
+		want     string
+		wantMime string
+		wantErr  bool
+	}{
+		{
+			name:    "nothing to merge",
+			inputs:  nil,
+			wantErr: true,
+		},
+		{
+			name:     "default join",
+			inputs:   text("x", "y"),
+			want:     "x. y",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "join of one json keeps mime",
+			inputs:   []MergeInput{{Output: `{"a":1}`, Mime: JsonContentType}},
+			want:     `{"a":1}`,
+			wantMime: JsonContentType,
+		},
+		{
+			name:     "join of several json is text",
+			inputs:   []MergeInput{{Output: `1`, Mime: JsonContentType}, {Output: `2`, Mime: JsonContentType}},
+			want:     "1. 2",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "join with separator",
+			options:  &MergeOptions{Strategy: JoinMergeStrategy, Separator: &separator},
+			inputs:   text("x", "y", "z"),
+			want:     "x | y | z",
+			wantMime: TextContentType,
+		},
+		{
+			name:    "json array keeps json values",
+			options: &MergeOptions{Strategy: ArrayMergeStrategy},
+			inputs: []MergeInput{
+				{Output: `{"a":1}`, Mime: JsonContentType},
+				{Output: "text", Mime: TextContentType},
+			},
+			want:     `[{"a":1},"text"]`,
+			wantMime: JsonContentType,
+		},
+		{
+			name:    "json object by keys",
+			options: &MergeOptions{Strategy: ObjectMergeStrategy},
+			inputs: []MergeInput{
+				{Key: "first", Output: `[1,2]`, Mime: JsonContentType},
+				{Key: "second", Output: "text", Mime: TextContentType},
+			},
+			want:     `{"first":[1,2],"second":"text"}`,
+			wantMime: JsonContentType,
+		},
+		{
+			name:     "first",
+			options:  &MergeOptions{Strategy: FirstMergeStrategy},
+			inputs:   text("x", "y"),
+			want:     "x",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "vote majority",
+			options:  &MergeOptions{Strategy: VoteMergeStrategy},
+			inputs:   text("A", "B", "B"),
+			want:     "B",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "vote tie goes to the first declared answer",
+			options:  &MergeOptions{Strategy: VoteMergeStrategy},
+			inputs:   text("A", "B", "B", "A"),
+			want:     "A",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "vote tie of later answers",
+			options:  &MergeOptions{Strategy: VoteMergeStrategy},
+			inputs:   text("C", "B", "A", "A", "B"),
+			want:     "B",
+			wantMime: TextContentType,
+		},
+		{
+			name:     "vote ignores surrounding spaces and returns the first occurrence",
+			options:  &MergeOptions{Strategy: VoteMergeStrategy},
+			inputs:   text("B", " A ", "A\n", "B", "A"),
+			want:     " A ",
+			wantMime: TextContentType,
+		},
+		{
+			name:    "vote keeps mime of the winner",
+			options: &MergeOptions{Strategy: VoteMergeStrategy},
+			inputs: []MergeInput{
+				{Output: "x", Mime: TextContentType},
+				{Output: "1", Mime: JsonContentType},
+				{Output: "1", Mime: TextContentType},
+			},
+			want:     "1",
+			wantMime: JsonContentType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mime, err := tt.options.Merge(tt.inputs)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Merge() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge() unexpected error: %v", err)
+			}
+			if got != tt.want || mime != tt.wantMime {
+				t.Fatalf("Merge() = %q, %q, want %q, %q", got, mime, tt.want, tt.wantMime)
+			}
+		})
+	}
+}
+
+func TestMergeOptionsValidate(t *testing.T) {
+	separator := ", "
+	tests := []struct {
+		name    string
+		options *MergeOptions
+		inputs  int
+		wantErr string
+	}{
+		{name: "no options", options: nil, inputs: 2},
+		{name: "join with separator", options: &MergeOptions{Strategy: JoinMergeStrategy, Separator: &separator}, inputs: 2},
+		{name: "object with keys", options: &MergeOptions{Strategy: ObjectMergeStrategy, Keys: []string{"a", "b"}}, inputs: 2},
+		{name: "vote", options: &MergeOptions{Strategy: VoteMergeStrategy}, inputs: 3},
+		{name: "unknown strategy", options: &MergeOptions{Strategy: "sum"}, inputs: 2, wantErr: "unknown strategy"},
+		{name: "keys count", options: &MergeOptions{Strategy: ObjectMergeStrategy, Keys: []string{"a"}}, inputs: 2, wantErr: "keys count"},
+		{name: "empty key", options: &MergeOptions{Strategy: ObjectMergeStrategy, Keys: []string{"a", ""}}, inputs: 2, wantErr: "key can't be empty"},
+		{name: "duplicated key", options: &MergeOptions{Strategy: ObjectMergeStrategy, Keys: []string{"a", "a"}}, inputs: 2, wantErr: "duplicated key a"},
+		{name: "separator outside join", options: &MergeOptions{Strategy: FirstMergeStrategy, Separator: &separator}, inputs: 2, wantErr: "separator"},
+		{name: "keys outside object", options: &MergeOptions{Strategy: ArrayMergeStrategy, Keys: []string{"a", "b"}}, inputs: 2, wantErr: "keys are allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate(tt.inputs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
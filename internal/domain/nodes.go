@@ -3,9 +3,11 @@ package domain
 import (
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/warehouse/ai-service/internal/repository/models"
 )
@@ -26,6 +28,7 @@ const (
 	ObjectFieldType BodyFieldType = "object"
 	PromptFieldType BodyFieldType = "prompt"
 	DataFieldType   BodyFieldType = "data"
+	FileFieldType   BodyFieldType = "file" // файл: загруженный в запуск или бинарный результат другой ноды
 )
 
 type HeaderType string
@@ -44,9 +47,25 @@ type Node struct {
 	Headers           map[string]Header
 	Body              map[string]BodyField
 	ResponseDirection string
+	ResponseExtractor ResponseExtractor // перекрывает ResponseDirection
 	RequestMime       string
 	ResponseMime      string
 	ApiKey            string
+	TimeoutMs         int64 // таймаут одной попытки запроса, 0 - таймаут по умолчанию из конфига
+	RetryPolicy       RetryPolicy
+	Stream            StreamOptions // нода отдает ответ потоком дельт
+	RateLimit         RateLimit     // общее для всех запусков ограничение частоты запросов
+	Cache             CacheOptions  // кеш ответов на одинаковые запросы
+	Usage             UsageOptions  // как посчитать токены и стоимость вызова
+}
+
+// NodeBreaker состояние брейкера запросов к ноде
+type NodeBreaker struct {
+	NodeId   string
+	NodeName string
+	State    string
+	Failures int
+	OpenedAt time.Time
 }
 
 type BodyField struct {
@@ -95,19 +114,9 @@ func (bd BodyField) CheckBodyField(value interface{}) error {
 			return fmt.Errorf("should be JSON object")
 		}
 
-		nestedObjectTypings := make(map[string]BodyField)
-		for key, value := range bd.Values[0].(map[string]interface{}) {
-			mapData, err := json.Marshal(value)
-			if err != nil {
-				return err
-			}
-
-			var field BodyField
-			if err := json.Unmarshal(mapData, &field); err != nil {
-				return fmt.Errorf("can't unmarshal is inconsistent to field typing standart")
-			}
-
-			nestedObjectTypings[key] = field
+		nestedObjectTypings, err := bd.NestedFields()
+		if err != nil {
+			return err
 		}
 
 		for key, value := range nestedObjectTypings {
@@ -117,9 +126,44 @@ func (bd BodyField) CheckBodyField(value interface{}) error {
 		}
 	}
 
+	if bd.Type == PromptFieldType || bd.Type == DataFieldType || bd.Type == FileFieldType {
+		if _, ok := value.(string); !ok && value != nil {
+			return fmt.Errorf("should contain only string values")
+		}
+	}
+
 	return nil
 }
 
+// NestedFields описание полей вложенного объекта для поля типа object
+func (bd BodyField) NestedFields() (map[string]BodyField, error) {
+	if len(bd.Values) == 0 {
+		return nil, fmt.Errorf("can't parse to nested structure")
+	}
+
+	nestedFields, ok := bd.Values[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("can't parse to nested structure")
+	}
+
+	nestedObjectTypings := make(map[string]BodyField, len(nestedFields))
+	for key, value := range nestedFields {
+		mapData, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		var field BodyField
+		if err := json.Unmarshal(mapData, &field); err != nil {
+			return nil, fmt.Errorf("can't unmarshal is inconsistent to field typing standart")
+		}
+
+		nestedObjectTypings[key] = field
+	}
+
+	return nestedObjectTypings, nil
+}
+
 func (h Header) CheckHeader(value string) error {
 	if h.Type == ConstHeaderType && h.Values[0] != value {
 		return fmt.Errorf("header value non equals to const value %s", h.Values[0])
@@ -132,6 +176,36 @@ func (h Header) CheckHeader(value string) error {
 	return nil
 }
 
+// RequestEncoding в каком формате отправляется тело запроса: json (по умолчанию), multipart или form
+func (n Node) RequestEncoding() (string, error) {
+	if n.RequestMime == "" {
+		return JsonContentType, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(n.RequestMime)
+	if err != nil {
+		return "", fmt.Errorf("request_mime: %s", err.Error())
+	}
+
+	switch {
+	case IsJsonMime(mediaType):
+		return JsonContentType, nil
+	case mediaType == MultipartContentType, mediaType == FormContentType:
+		return mediaType, nil
+	}
+
+	return "", fmt.Errorf("request_mime %s is not supported, use json, %s or %s", n.RequestMime, MultipartContentType, FormContentType)
+}
+
+// Timeout таймаут одной попытки запроса к ноде
+func (n Node) Timeout(defaultTimeout time.Duration) time.Duration {
+	if n.TimeoutMs <= 0 {
+		return defaultTimeout
+	}
+
+	return time.Duration(n.TimeoutMs) * time.Millisecond
+}
+
 func (n Node) ToModel() (models.Node, error) {
 	body := make(map[string]interface{})
 	for key, value := range n.Body {
@@ -161,16 +235,53 @@ func (n Node) ToModel() (models.Node, error) {
 		headers[key] = val
 	}
 
+	retryPolicy, err := toJSONMap(n.RetryPolicy)
+	if err != nil {
+		return models.Node{}, err
+	}
+
+	extractor, err := toJSONMap(n.ResponseExtractor)
+	if err != nil {
+		return models.Node{}, err
+	}
+
+	stream, err := toJSONMap(n.Stream)
+	if err != nil {
+		return models.Node{}, err
+	}
+
+	rateLimit, err := toJSONMap(n.RateLimit)
+	if err != nil {
+		return models.Node{}, err
+	}
+
+	cache, err := toJSONMap(n.Cache)
+	if err != nil {
+		return models.Node{}, err
+	}
+
+	usage, err := toJSONMap(n.Usage)
+	if err != nil {
+		return models.Node{}, err
+	}
+
 	return models.Node{
 		Name:              n.Name,
 		Url:               n.Url,
 		Method:            string(n.Method),
 		ResponseDirection: n.ResponseDirection,
+		ResponseExtractor: extractor,
 		RequestMime:       n.RequestMime,
-		ResponseMime:      n.RequestMime,
+		ResponseMime:      n.ResponseMime,
 		ApiKey:            n.ApiKey,
 		Headers:           headers,
 		Body:              body,
+		TimeoutMs:         n.TimeoutMs,
+		RetryPolicy:       retryPolicy,
+		Stream:            stream,
+		RateLimit:         rateLimit,
+		Cache:             cache,
+		Usage:             usage,
 	}, nil
 }
 
@@ -205,6 +316,36 @@ func (Node) FromModel(m models.Node) (Node, error) {
 		headers[key] = header
 	}
 
+	var retryPolicy RetryPolicy
+	if err := fromJSONMap(m.RetryPolicy, &retryPolicy); err != nil {
+		return Node{}, err
+	}
+
+	var extractor ResponseExtractor
+	if err := fromJSONMap(m.ResponseExtractor, &extractor); err != nil {
+		return Node{}, err
+	}
+
+	var stream StreamOptions
+	if err := fromJSONMap(m.Stream, &stream); err != nil {
+		return Node{}, err
+	}
+
+	var rateLimit RateLimit
+	if err := fromJSONMap(m.RateLimit, &rateLimit); err != nil {
+		return Node{}, err
+	}
+
+	var cache CacheOptions
+	if err := fromJSONMap(m.Cache, &cache); err != nil {
+		return Node{}, err
+	}
+
+	var usage UsageOptions
+	if err := fromJSONMap(m.Usage, &usage); err != nil {
+		return Node{}, err
+	}
+
 	return Node{
 		Id:                m.Id.String(),
 		Name:              m.Name,
@@ -213,8 +354,42 @@ func (Node) FromModel(m models.Node) (Node, error) {
 		Headers:           headers,
 		Body:              bodyFields,
 		ResponseDirection: m.ResponseDirection,
+		ResponseExtractor: extractor,
 		RequestMime:       m.RequestMime,
 		ResponseMime:      m.ResponseMime,
 		ApiKey:            m.ApiKey,
+		TimeoutMs:         m.TimeoutMs,
+		RetryPolicy:       retryPolicy,
+		Stream:            stream,
+		RateLimit:         rateLimit,
+		Cache:             cache,
+		Usage:             usage,
 	}, nil
 }
+
+func toJSONMap(value interface{}) (map[string]interface{}, error) {
+	jsonValue, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var res map[string]interface{}
+	if err := json.Unmarshal(jsonValue, &res); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func fromJSONMap(m map[string]interface{}, value interface{}) error {
+	if len(m) == 0 {
+		return nil
+	}
+
+	jsonValue, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(jsonValue, value)
+}
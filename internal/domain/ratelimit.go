@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// RateLimit ограничение частоты запросов к ноде (token bucket): Requests запросов за IntervalMs,
+// подряд можно отправить до Burst запросов. Ограничение общее для всех запусков
+type RateLimit struct {
+	Requests   int   `json:"requests,omitempty"` // 0 - без ограничения
+	IntervalMs int64 `json:"interval_ms,omitempty"`
+	Burst      int   `json:"burst,omitempty"` // 0 - равен Requests
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0
+}
+
+func (l RateLimit) Validate() error {
+	if l.Requests < 0 || l.IntervalMs < 0 || l.Burst < 0 {
+		return fmt.Errorf("rate_limit: values can't be negative")
+	}
+
+	if !l.Enabled() {
+		if l.IntervalMs != 0 || l.Burst != 0 {
+			return fmt.Errorf("rate_limit: requests is required")
+		}
+		return nil
+	}
+
+	if l.IntervalMs == 0 {
+		return fmt.Errorf("rate_limit: interval_ms is required")
+	}
+
+	return nil
+}
+
+func (l RateLimit) Interval() time.Duration {
+	return time.Duration(l.IntervalMs) * time.Millisecond
+}
+
+// Capacity сколько запросов можно отправить подряд
+func (l RateLimit) Capacity() float64 {
+	if l.Burst == 0 {
+		return float64(l.Requests)
+	}
+
+	return float64(l.Burst)
+}
+
+// Refill сколько запросов восстанавливается за d
+func (l RateLimit) Refill(d time.Duration) float64 {
+	return float64(l.Requests) * float64(d) / float64(l.Interval())
+}
+
+// Delay через сколько восстановится missing запросов
+func (l RateLimit) Delay(missing float64) time.Duration {
+	return time.Duration(missing * float64(l.Interval()) / float64(l.Requests))
+}
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+type RetryErrorKind string
+
+const (
+	RetryOnTimeout    RetryErrorKind = "timeout"    // таймаут соединения или ответа
+	RetryOnConnection RetryErrorKind = "connection" // соединение отклонено или оборвано
+	RetryOnDNS        RetryErrorKind = "dns"        // хост не удалось разрезолвить
+)
+
+var RetryErrorKinds = []RetryErrorKind{RetryOnTimeout, RetryOnConnection, RetryOnDNS}
+
+// RetryPolicy политика повторов запроса к ноде. Пустая политика означает одну попытку
+type RetryPolicy struct {
+	MaxAttempts   int              `json:"max_attempts"`
+	BackoffBaseMs int64            `json:"backoff_base_ms"`
+	BackoffCapMs  int64            `json:"backoff_cap_ms"`
+	Jitter        float64          `json:"jitter"` // доля задержки (0..1), на которую она может случайно уменьшиться
+	RetryStatuses []int            `json:"retry_statuses"`
+	RetryErrors   []RetryErrorKind `json:"retry_errors"`
+}
+
+func (p RetryPolicy) Attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) RetryableStatus(code int) bool {
+	return slices.Contains(p.RetryStatuses, code)
+}
+
+func (p RetryPolicy) RetryableError(kind RetryErrorKind) bool {
+	return slices.Contains(p.RetryErrors, kind)
+}
+
+// Backoff экспоненциальная задержка после неудачной попытки attempt (нумерация с 1), ограниченная сверху BackoffCapMs
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if p.BackoffBaseMs <= 0 {
+		return 0
+	}
+
+	delay := float64(p.BackoffBaseMs) * math.Pow(2, float64(attempt-1))
+	if p.BackoffCapMs > 0 && delay > float64(p.BackoffCapMs) {
+		delay = float64(p.BackoffCapMs)
+	}
+
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+
+	return time.Duration(delay) * time.Millisecond
+}
+
+// MaxBackoff наибольшая задержка между попытками, которую допускает политика: BackoffCapMs,
+// а без него задержка перед последней попыткой
+func (p RetryPolicy) MaxBackoff() time.Duration {
+	if p.BackoffCapMs > 0 {
+		return time.Duration(p.BackoffCapMs) * time.Millisecond
+	}
+
+	if p.BackoffBaseMs <= 0 || p.Attempts() < 2 {
+		return 0
+	}
+
+	return time.Duration(float64(p.BackoffBaseMs)*math.Pow(2, float64(p.Attempts()-2))) * time.Millisecond
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "no backoff", policy: RetryPolicy{}, attempt: 3, want: 0},
+		{name: "first retry", policy: RetryPolicy{BackoffBaseMs: 100}, attempt: 1, want: 100 * time.Millisecond},
+		{name: "doubles", policy: RetryPolicy{BackoffBaseMs: 100}, attempt: 4, want: 800 * time.Millisecond},
+		{name: "capped", policy: RetryPolicy{BackoffBaseMs: 100, BackoffCapMs: 300}, attempt: 4, want: 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Backoff(tt.attempt); got != tt.want {
+				t.Fatalf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{BackoffBaseMs: 1000, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if got := policy.Backoff(1); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("Backoff(1) = %s, want between 500ms and 1s", got)
+		}
+	}
+}
+
+func TestRetryPolicyMaxBackoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   time.Duration
+	}{
+		{name: "empty policy", policy: RetryPolicy{}, want: 0},
+		{name: "cap", policy: RetryPolicy{MaxAttempts: 5, BackoffBaseMs: 100, BackoffCapMs: 250}, want: 250 * time.Millisecond},
+		{name: "delay before the last attempt", policy: RetryPolicy{MaxAttempts: 4, BackoffBaseMs: 100}, want: 400 * time.Millisecond},
+		{name: "one attempt", policy: RetryPolicy{MaxAttempts: 1, BackoffBaseMs: 100}, want: 0},
+		{name: "no base", policy: RetryPolicy{MaxAttempts: 3}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MaxBackoff(); got != tt.want {
+				t.Fatalf("MaxBackoff() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
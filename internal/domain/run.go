@@ -0,0 +1,249 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	wh_converters "github.com/warehouse/ai-service/internal/pkg/utils/converters"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/types"
+)
+
+type RunStatus string
+
+const (
+	RunQueued    RunStatus = "queued"
+	RunRunning   RunStatus = "running"
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+type (
+	ScriptRun struct {
+		Id         string
+		ScriptId   string
+		AuthorId   string
+		Status     RunStatus
+		EnterData  string
+		Files      map[string]string // файлы, загруженные в запуск: имя - ссылка blob://
+		Result     string
+		ResultMime string // json результат отдается в ответе json значением
+		Error      string
+		Report     RunReport
+		Cassette   RunCassette // ответы нод записываются в кассету или воспроизводятся из нее
+		Usage      Usage       // сумма по всем вызовам нод запуска
+		CreatedAt  time.Time
+		UpdatedAt  time.Time
+	}
+
+	// RunReport подробности выполнения запуска, которые сохраняются вместе с результатом
+	RunReport struct {
+		Fallbacks []RunFallback `json:"fallbacks,omitempty"`
+		Failures  []RunFailure  `json:"failures,omitempty"`
+	}
+
+	// RunFailure узел, ошибка которого по политике on_error не завершила запуск.
+	// Для старого формата шаг и цепочка указывают на упавшую цепочку
+	RunFailure struct {
+		GraphNode string      `json:"graph_node"`
+		Step      int         `json:"step"`
+		Chain     *int        `json:"chain,omitempty"`
+		Action    ErrorAction `json:"action"`
+		Error     string      `json:"error"`
+	}
+
+	// RunFallback запасная нода, которая ответила вместо основной ноды узла
+	RunFallback struct {
+		GraphNode  string `json:"graph_node"`
+		NodeId     string `json:"node_id"`     // основная нода узла
+		FallbackId string `json:"fallback_id"` // нода, чей результат использован
+		Reason     string `json:"reason"`      // ошибка основной ноды
+	}
+
+	// RunStep запись об одном вызове ноды внутри запуска
+	RunStep struct {
+		Step           int
+		Chain          int
+		Position       int
+		NodeId         string
+		GraphNode      string
+		Attempt        int
+		RequestBody    string
+		RequestHeaders map[string]string
+		Response       string
+		Output         string
+		StatusCode     int
+		Latency        time.Duration
+		Error          string
+		CacheHit       bool // ответ взят из кеша ноды, запрос не отправлялся
+		Usage          Usage
+		StartedAt      time.Time
+	}
+
+	// RunMessage сообщение в очереди запусков, воркер по нему достает запуск из базы
+	RunMessage struct {
+		RunId string `json:"run_id"`
+	}
+)
+
+// Finished запуск уже выполнен и не должен обрабатываться повторно
+func (r ScriptRun) Finished() bool {
+	return r.Status == RunSucceeded || r.Status == RunFailed
+}
+
+func (r ScriptRun) ToModel() (models.ScriptRun, error) {
+	report, err := toJSONMap(r.Report)
+	if err != nil {
+		return models.ScriptRun{}, err
+	}
+
+	files := make(types.JSON, len(r.Files))
+	for name, ref := range r.Files {
+		files[name] = ref
+	}
+
+	cassette, err := toJSONMap(r.Cassette)
+	if err != nil {
+		return models.ScriptRun{}, err
+	}
+
+	return models.ScriptRun{
+		Id:               wh_converters.FastConvertToXid(r.Id),
+		ScriptId:         r.ScriptId,
+		AuthorId:         r.AuthorId,
+		Status:           string(r.Status),
+		EnterData:        r.EnterData,
+		Files:            files,
+		Result:           r.Result,
+		ResultMime:       r.ResultMime,
+		Error:            r.Error,
+		Report:           report,
+		Cassette:         cassette,
+		PromptTokens:     r.Usage.PromptTokens,
+		CompletionTokens: r.Usage.CompletionTokens,
+		Cost:             r.Usage.Cost,
+		CreatedAt:        r.CreatedAt,
+		UpdatedAt:        r.UpdatedAt,
+	}, nil
+}
+
+func (ScriptRun) FromModel(m models.ScriptRun) (ScriptRun, error) {
+	var report RunReport
+	if err := fromJSONMap(m.Report, &report); err != nil {
+		return ScriptRun{}, err
+	}
+
+	files := make(map[string]string, len(m.Files))
+	for name, ref := range m.Files {
+		files[name] = fmt.Sprint(ref)
+	}
+
+	var cassette RunCassette
+	if err := fromJSONMap(m.Cassette, &cassette); err != nil {
+		return ScriptRun{}, err
+	}
+
+	return ScriptRun{
+		Id:         m.Id.String(),
+		ScriptId:   m.ScriptId,
+		AuthorId:   m.AuthorId,
+		Status:     RunStatus(m.Status),
+		EnterData:  m.EnterData,
+		Files:      files,
+		Result:     m.Result,
+		ResultMime: m.ResultMime,
+		Error:      m.Error,
+		Report:     report,
+		Cassette:   cassette,
+		Usage: Usage{
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			Cost:             m.Cost,
+		},
+		CreatedAt: m.CreatedAt,
+		UpdatedAt: m.UpdatedAt,
+	}, nil
+}
+
+func (st RunStep) ToModel(runId string) models.RunStep {
+	headers := make(types.JSON, len(st.RequestHeaders))
+	for key, value := range st.RequestHeaders {
+		headers[key] = value
+	}
+
+	return models.RunStep{
+		RunId:            runId,
+		Step:             st.Step,
+		Chain:            st.Chain,
+		Position:         st.Position,
+		NodeId:           st.NodeId,
+		GraphNode:        st.GraphNode,
+		Attempt:          st.Attempt,
+		RequestBody:      st.RequestBody,
+		RequestHeaders:   headers,
+		Response:         st.Response,
+		Output:           st.Output,
+		StatusCode:       st.StatusCode,
+		LatencyMs:        st.Latency.Milliseconds(),
+		Error:            st.Error,
+		CacheHit:         st.CacheHit,
+		PromptTokens:     st.Usage.PromptTokens,
+		CompletionTokens: st.Usage.CompletionTokens,
+		Cost:             st.Usage.Cost,
+		StartedAt:        st.StartedAt,
+	}
+}
+
+func (RunStep) FromModel(m models.RunStep) RunStep {
+	headers := make(map[string]string, len(m.RequestHeaders))
+	for key, value := range m.RequestHeaders {
+		headers[key] = fmt.Sprint(value)
+	}
+
+	return RunStep{
+		Step:           m.Step,
+		Chain:          m.Chain,
+		Position:       m.Position,
+		NodeId:         m.NodeId,
+		GraphNode:      m.GraphNode,
+		Attempt:        m.Attempt,
+		RequestBody:    m.RequestBody,
+		RequestHeaders: headers,
+		Response:       m.Response,
+		Output:         m.Output,
+		StatusCode:     m.StatusCode,
+		Latency:        time.Duration(m.LatencyMs) * time.Millisecond,
+		Error:          m.Error,
+		CacheHit:       m.CacheHit,
+		Usage: Usage{
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			Cost:             m.Cost,
+		},
+		StartedAt: m.StartedAt,
+	}
+}
+
+type RunEventType string
+
+const (
+	NodeStartedEvent RunEventType = "node_started"
+	NodeSkippedEvent RunEventType = "node_skipped" // не выполнилось условие или пропущены все входы
+	NodeResultEvent  RunEventType = "node_result"
+	NodeDeltaEvent   RunEventType = "node_delta" // часть потокового ответа ноды, результат которой станет результатом запуска
+	RunFinishedEvent RunEventType = "run_finished"
+	RunFailedEvent   RunEventType = "run_failed"
+)
+
+// RunEvent событие хода выполнения запуска, Chain не заполняется для join узлов и событий запуска
+type RunEvent struct {
+	Type      RunEventType
+	RunId     string
+	Step      int
+	Chain     *int
+	GraphNode string
+	NodeName  string
+	Mime      string
+	Output    string
+	Error     string
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	wh_converters "github.com/warehouse/ai-service/internal/pkg/utils/converters"
 	"github.com/warehouse/ai-service/internal/repository/models"
 )
 
@@ -14,26 +15,74 @@ type Script struct {
 	Workflow        map[int]map[int][]string
 	BodyPresets     map[string]map[string]interface{}
 	HeaderPresets   map[string]map[string]string
+	Options         ScriptOptions
+	Graph           Graph // если задан, сценарий выполняется по графу, а Workflow пустой
 	AuthorId        string
 	WarehouseApiKey string
 }
 
-func parseStep(stepData []interface{}) map[int][]string {
-	stepChainMap := make(map[int][]string)
-
-	for i, v := range stepData {
-		switch value := v.(type) {
-		case []interface{}:
-			resMap := parseStep(value)
-			stepChainMap[i] = append(stepChainMap[i], resMap[0]...)
-		case string:
-			stepChainMap[i] = append(stepChainMap[i], value)
-		default:
-			fmt.Printf("Unexpected type %T\n", value)
+// ScriptOptions настройки выполнения сценария поверх workflow
+type ScriptOptions struct {
+	Steps map[int]StepOptions `json:"steps,omitempty"`
+	Vars  map[string]string   `json:"vars,omitempty"` // переменные для шаблонов {{vars.name}}
+	// Templates пресеты сценария - шаблоны. У сценариев, созданных до появления шаблонов, флага нет,
+	// и их пресеты отправляются как есть, даже если в тексте есть {{...}}
+	Templates bool `json:"templates,omitempty"`
+}
+
+// Step настройки шага, для шага без настроек возвращаются пустые
+func (o ScriptOptions) Step(step int) StepOptions {
+	return o.Steps[step]
+}
+
+// ParseWorkflow разбирает workflow из json: шаг - массив цепочек,
+// цепочка - айди одной ноды или массив айди нод, которые выполняются по очереди
+func ParseWorkflow(raw map[string][]interface{}) (map[int]map[int][]string, error) {
+	workflow := make(map[int]map[int][]string)
+	for key, value := range raw {
+		stepKey, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %s", key, err.Error())
+		}
+
+		step := make(map[int][]string)
+		for chainKey, chainValue := range value {
+			chain, err := parseChain(chainValue)
+			if err != nil {
+				return nil, fmt.Errorf("step %d, chain %d: %s", stepKey, chainKey, err.Error())
+			}
+
+			step[chainKey] = chain
 		}
+
+		workflow[stepKey] = step
 	}
 
-	return stepChainMap
+	return workflow, nil
+}
+
+func parseChain(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		chain := make([]string, len(v))
+		for i, nodeId := range v {
+			id, ok := nodeId.(string)
+			if !ok {
+				return nil, fmt.Errorf("node id should be string, got %T", nodeId)
+			}
+			chain[i] = id
+		}
+
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("chain can't be empty")
+		}
+
+		return chain, nil
+	default:
+		return nil, fmt.Errorf("chain should be node id or array of node ids, got %T", value)
+	}
 }
 
 func (Script) FromModel(m models.Script) (Script, error) {
@@ -42,15 +91,9 @@ func (Script) FromModel(m models.Script) (Script, error) {
 		return Script{}, err
 	}
 
-	workflowMap := make(map[int]map[int][]string)
-	for key, value := range workflow {
-		stepKey, err := strconv.Atoi(key)
-		if err != nil {
-			return Script{}, err
-		}
-
-		stepChainMap := parseStep(value)
-		workflowMap[stepKey] = stepChainMap
+	workflowMap, err := ParseWorkflow(workflow)
+	if err != nil {
+		return Script{}, err
 	}
 
 	bodyPresets := make(map[string]map[string]interface{})
@@ -65,12 +108,30 @@ func (Script) FromModel(m models.Script) (Script, error) {
 
 	headerPresets := make(map[string]map[string]string)
 	for key, value := range m.HeaderPresets {
-		headers, ok := value.(map[string]string)
+		headers, ok := value.(map[string]interface{})
 		if !ok {
 			return Script{}, fmt.Errorf("can't parse model headers presets for node %s", key)
 		}
 
-		headerPresets[key] = headers
+		nodeHeaders := make(map[string]string, len(headers))
+		for name, header := range headers {
+			nodeHeaders[name], ok = header.(string)
+			if !ok {
+				return Script{}, fmt.Errorf("can't parse model headers presets for node %s", key)
+			}
+		}
+
+		headerPresets[key] = nodeHeaders
+	}
+
+	var options ScriptOptions
+	if err := fromJSONMap(m.Options, &options); err != nil {
+		return Script{}, err
+	}
+
+	var graph Graph
+	if err := fromJSONMap(m.Graph, &graph); err != nil {
+		return Script{}, err
 	}
 
 	return Script{
@@ -79,36 +140,45 @@ func (Script) FromModel(m models.Script) (Script, error) {
 		Workflow:        workflowMap,
 		BodyPresets:     bodyPresets,
 		HeaderPresets:   headerPresets,
+		Options:         options,
+		Graph:           graph,
 		AuthorId:        m.AuthorId,
 		WarehouseApiKey: m.WarehouseApiKey,
 	}, nil
 }
 
 func (s Script) ToModel() (models.Script, error) {
-	flatBodyPresets := make(map[string]interface{})
-	for key, value := range s.BodyPresets {
-		fields, err := json.Marshal(value)
-		if err != nil {
-			return models.Script{}, err
-		}
-		flatBodyPresets[key] = fields
+	flatBodyPresets, err := toJSONMap(s.BodyPresets)
+	if err != nil {
+		return models.Script{}, err
 	}
 
-	flatHeaderPresets := make(map[string]interface{})
-	for key, value := range s.HeaderPresets {
-		headers, err := json.Marshal(value)
-		if err != nil {
+	flatHeaderPresets, err := toJSONMap(s.HeaderPresets)
+	if err != nil {
+		return models.Script{}, err
+	}
+
+	options, err := toJSONMap(s.Options)
+	if err != nil {
+		return models.Script{}, err
+	}
+
+	graph := make(map[string]interface{})
+	if !s.Graph.Empty() {
+		if graph, err = toJSONMap(s.Graph); err != nil {
 			return models.Script{}, err
 		}
-		flatHeaderPresets[key] = headers
 	}
 
+	// цепочки сохраняются по порядку номеров, номер цепочки - ее индекс в шаге
 	workflow := make(map[string][]interface{})
 	for stepNumber, stepValue := range s.Workflow {
 		step := make([]interface{}, len(stepValue))
-
-		for _, chainValue := range stepValue {
-			step = append(step, chainValue)
+		for chainNumber, chainValue := range stepValue {
+			if chainNumber < 0 || chainNumber >= len(stepValue) {
+				return models.Script{}, fmt.Errorf("step %d: chain %d out of range", stepNumber, chainNumber)
+			}
+			step[chainNumber] = chainValue
 		}
 
 		workflow[strconv.Itoa(stepNumber)] = step
@@ -116,15 +186,41 @@ func (s Script) ToModel() (models.Script, error) {
 
 	workflowRaw, err := json.Marshal(workflow)
 	if err != nil {
-		return models.Script{}, nil
+		return models.Script{}, err
 	}
 
 	return models.Script{
+		Id:              wh_converters.FastConvertToXid(s.Id),
 		Name:            s.Name,
 		Workflow:        workflowRaw,
 		BodyPresets:     flatBodyPresets,
 		HeaderPresets:   flatHeaderPresets,
+		Options:         options,
+		Graph:           graph,
 		AuthorId:        s.AuthorId,
 		WarehouseApiKey: s.WarehouseApiKey,
 	}, nil
 }
+
+// ExecutionGraph граф, по которому выполняется сценарий. Сценарий из шагов и цепочек переводится в граф
+func (s Script) ExecutionGraph() (Graph, error) {
+	if s.Graph.Empty() {
+		graph, err := LegacyGraph(s.Workflow, s.Options)
+		if err != nil {
+			return Graph{}, err
+		}
+
+		if _, err := graph.Validate(); err != nil {
+			return Graph{}, err
+		}
+
+		return graph, nil
+	}
+
+	order, err := s.Graph.Validate()
+	if err != nil {
+		return Graph{}, err
+	}
+
+	return s.Graph.withLayout(order), nil
+}
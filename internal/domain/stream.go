@@ -0,0 +1,64 @@
+package domain
+
+import "fmt"
+
+type StreamFormat string
+
+const (
+	SSEStreamFormat    StreamFormat = "sse"    // Server-Sent Events, поток заканчивается событием [DONE] или закрытием
+	NdjsonStreamFormat StreamFormat = "ndjson" // json объект на каждой строке
+)
+
+// StreamDoneMarker данные события, которым OpenAI-совместимые провайдеры закрывают поток
+const StreamDoneMarker = "[DONE]"
+
+// StreamOptions нода отдает ответ потоком. Результат ноды - склейка дельт из событий потока,
+// экстрактор ответа для такой ноды не используется
+type StreamOptions struct {
+	Format    StreamFormat `json:"format,omitempty"`     // пусто - нода не стримит
+	DeltaPath string       `json:"delta_path,omitempty"` // json_path до текста дельты в событии, для sse пусто - данные события целиком
+}
+
+func (o StreamOptions) Enabled() bool {
+	return o.Format != ""
+}
+
+func (o StreamOptions) Validate() error {
+	switch o.Format {
+	case "":
+		if o.DeltaPath != "" {
+			return fmt.Errorf("stream: format is required")
+		}
+		return nil
+	case SSEStreamFormat:
+	case NdjsonStreamFormat:
+		if o.DeltaPath == "" {
+			return fmt.Errorf("stream: delta_path is required for ndjson")
+		}
+	default:
+		return fmt.Errorf("stream: unknown format %s", o.Format)
+	}
+
+	if o.DeltaPath != "" {
+		if _, err := parseJsonPath(o.DeltaPath); err != nil {
+			return fmt.Errorf("stream: delta_path: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Delta текст дельты из данных одного события. События без дельты (служебные, с ролью и т.п.) пропускаются
+func (o StreamOptions) Delta(data string) (string, bool) {
+	if o.DeltaPath == "" {
+		return data, data != ""
+	}
+
+	extractor := ResponseExtractor{Kind: JsonPathExtractor, Expr: o.DeltaPath}
+	delta, _, err := extractor.Extract([]byte(data), JsonContentType)
+	if err != nil || delta == "" {
+		return "", false
+	}
+
+	return delta, true
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type TemplateSource string
+
+const (
+	InputTemplateSource  TemplateSource = "input"  // вход сценария
+	DataTemplateSource   TemplateSource = "data"   // вход узла, который вызывает ноду
+	VarTemplateSource    TemplateSource = "vars"   // переменная сценария
+	OutputTemplateSource TemplateSource = "output" // результат шага, цепочки или узла графа
+	FileTemplateSource   TemplateSource = "files"  // файл, загруженный в запуск
+)
+
+// templateRefRegexp подстановка {{...}}, с обратным слешем перед ней (\{{...}}) - обычный текст
+var templateRefRegexp = regexp.MustCompile(`(\\?)\{\{\s*([^{}]*?)\s*\}\}`)
+
+type (
+	// Template строка с подстановками вида {{input}}, {{data}}, {{vars.name}}, {{files.name}},
+	// {{steps.1.output}}, {{steps.1.chains.0.output.title}} и {{nodes.name.output.path}}.
+	// Экранированная подстановка \{{...}} остается в тексте как {{...}}
+	Template string
+
+	TemplateRef struct {
+		Expr   string
+		Source TemplateSource
+		Var    string
+		File   string
+		Step   *int   // ссылка на шаг старого формата
+		Chain  *int   // ссылка на цепочку шага старого формата
+		Node   string // узел графа, для ссылок на шаги и цепочки заполняется в Graph.ResolveRef
+		Path   string // путь gojsonq внутри результата
+	}
+)
+
+// Refs разбирает все подстановки шаблона
+func (t Template) Refs() ([]TemplateRef, error) {
+	refs := []TemplateRef{}
+	for _, match := range templateRefRegexp.FindAllStringSubmatch(string(t), -1) {
+		if match[1] != "" {
+			continue
+		}
+
+		ref, err := parseTemplateRef(match[2])
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %s", match[0], err.Error())
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+// Render подставляет значения ссылок. Шаблон должен быть заранее проверен через Refs,
+// неразобранные подстановки заменяются пустой строкой, у экранированных убирается обратный слеш
+func (t Template) Render(value func(ref TemplateRef) string) string {
+	return templateRefRegexp.ReplaceAllStringFunc(string(t), func(match string) string {
+		groups := templateRefRegexp.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return match[len(groups[1]):]
+		}
+
+		ref, err := parseTemplateRef(groups[2])
+		if err != nil {
+			return ""
+		}
+
+		return value(ref)
+	})
+}
+
+// SingleRef шаблон целиком состоит из одной подстановки, например "{{nodes.a.output}}"
+func (t Template) SingleRef() (TemplateRef, bool) {
+	match := templateRefRegexp.FindStringSubmatchIndex(string(t))
+	if match == nil || match[0] != 0 || match[1] != len(t) || match[3] != match[2] {
+		return TemplateRef{}, false
+	}
+
+	ref, err := parseTemplateRef(string(t)[match[4]:match[5]])
+	if err != nil {
+		return TemplateRef{}, false
+	}
+
+	return ref, true
+}
+
+func parseTemplateRef(expr string) (TemplateRef, error) {
+	ref := TemplateRef{Expr: expr}
+
+	switch {
+	case expr == string(InputTemplateSource):
+		ref.Source = InputTemplateSource
+		return ref, nil
+	case expr == string(DataTemplateSource):
+		ref.Source = DataTemplateSource
+		return ref, nil
+	case strings.HasPrefix(expr, "vars."):
+		ref.Source = VarTemplateSource
+		ref.Var = strings.TrimPrefix(expr, "vars.")
+		if ref.Var == "" {
+			return TemplateRef{}, fmt.Errorf("variable name is empty")
+		}
+		return ref, nil
+	case strings.HasPrefix(expr, "files."):
+		ref.Source = FileTemplateSource
+		ref.File = strings.TrimPrefix(expr, "files.")
+		if ref.File == "" {
+			return TemplateRef{}, fmt.Errorf("file name is empty")
+		}
+		return ref, nil
+	case strings.HasPrefix(expr, "nodes."):
+		ref.Source = OutputTemplateSource
+		name, path, ok := cutOutput(strings.TrimPrefix(expr, "nodes."))
+		if !ok || name == "" {
+			return TemplateRef{}, fmt.Errorf("expected nodes.<name>.output")
+		}
+		ref.Node = name
+		ref.Path = path
+		return ref, nil
+	case strings.HasPrefix(expr, "steps."):
+		ref.Source = OutputTemplateSource
+		rest, path, ok := cutOutput(strings.TrimPrefix(expr, "steps."))
+		if !ok {
+			return TemplateRef{}, fmt.Errorf("expected steps.<step>.output or steps.<step>.chains.<chain>.output")
+		}
+		ref.Path = path
+
+		stepPart, chainPart, hasChain := strings.Cut(rest, ".chains.")
+		step, err := strconv.Atoi(stepPart)
+		if err != nil {
+			return TemplateRef{}, fmt.Errorf("step should be number")
+		}
+		ref.Step = &step
+
+		if hasChain {
+			chain, err := strconv.Atoi(chainPart)
+			if err != nil {
+				return TemplateRef{}, fmt.Errorf("chain should be number")
+			}
+			ref.Chain = &chain
+		}
+		return ref, nil
+	}
+
+	return TemplateRef{}, fmt.Errorf("unknown reference %s", expr)
+}
+
+// cutOutput делит "<что-то>.output.<путь>" на части до и после output
+func cutOutput(expr string) (string, string, bool) {
+	if before, ok := strings.CutSuffix(expr, ".output"); ok {
+		return before, "", true
+	}
+
+	before, path, ok := strings.Cut(expr, ".output.")
+	return before, path, ok
+}
+
+// ResolveRef находит узел графа, на результат которого ссылается подстановка.
+// Ссылки на шаги и цепочки допустимы только для сценария из шагов и цепочек
+func (g Graph) ResolveRef(ref TemplateRef) (TemplateRef, error) {
+	if ref.Source != OutputTemplateSource {
+		return ref, nil
+	}
+
+	if ref.Step == nil {
+		for _, node := range g.Nodes {
+			if node.Name == ref.Node {
+				return ref, nil
+			}
+		}
+
+		return TemplateRef{}, fmt.Errorf("reference %s: unknown node %s", ref.Expr, ref.Node)
+	}
+
+	// результат шага - его join, результат цепочки - ее последний узел
+	found := false
+	position := -1
+	for _, node := range g.Nodes {
+		if node.Step != *ref.Step || !g.legacy {
+			continue
+		}
+
+		if ref.Chain == nil {
+			if node.Name == legacyStepNodeName(*ref.Step) {
+				ref.Node = node.Name
+				found = true
+			}
+			continue
+		}
+
+		if node.Chain != nil && *node.Chain == *ref.Chain && node.Position > position {
+			ref.Node = node.Name
+			position = node.Position
+			found = true
+		}
+	}
+
+	if !found {
+		return TemplateRef{}, fmt.Errorf("reference %s: step or chain not found in workflow", ref.Expr)
+	}
+
+	return ref, nil
+}
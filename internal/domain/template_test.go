@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTemplateRefs(t *testing.T) {
+	one, two := 1, 2
+
+	tests := []struct {
+		name     string
+		template Template
+		want     []TemplateRef
+		wantErr  string
+	}{
+		{name: "no refs", template: "plain text", want: []TemplateRef{}},
+		{
+			name:     "input and data with spaces",
+			template: "{{ input }} and {{data}}",
+			want: []TemplateRef{
+				{Expr: "input", Source: InputTemplateSource},
+				{Expr: "data", Source: DataTemplateSource},
+			},
+		},
+		{
+			name:     "var and file",
+			template: "{{vars.lang}} {{files.photo}}",
+			want: []TemplateRef{
+				{Expr: "vars.lang", Source: VarTemplateSource, Var: "lang"},
+				{Expr: "files.photo", Source: FileTemplateSource, File: "photo"},
+			},
+		},
+		{
+			name:     "node output with path",
+			template: "{{nodes.summary.output.choices.[0]}}",
+			want: []TemplateRef{
+				{Expr: "nodes.summary.output.choices.[0]", Source: OutputTemplateSource, Node: "summary", Path: "choices.[0]"},
+			},
+		},
+		{
+			name:     "step and chain outputs",
+			template: "{{steps.1.output}} {{steps.2.chains.1.output.title}}",
+			want: []TemplateRef{
+				{Expr: "steps.1.output", Source: OutputTemplateSource, Step: &one},
+				{Expr: "steps.2.chains.1.output.title", Source: OutputTemplateSource, Step: &two, Chain: &one, Path: "title"},
+			},
+		},
+		{name: "empty var", template: "{{vars.}}", wantErr: "variable name is empty"},
+		{name: "empty file", template: "{{files.}}", wantErr: "file name is empty"},
+		{name: "node without output", template: "{{nodes.a}}", wantErr: "expected nodes.<name>.output"},
+		{name: "step is not a number", template: "{{steps.x.output}}", wantErr: "step should be number"},
+		{name: "chain is not a number", template: "{{steps.1.chains.x.output}}", wantErr: "chain should be number"},
+		{name: "unknown reference", template: "{{secrets.key}}", wantErr: "unknown reference secrets.key"},
+		{name: "escaped ref is text", template: `\{{secrets.key}} and \{{input}}`, want: []TemplateRef{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.template.Refs()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Refs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Refs() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Refs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	values := func(ref TemplateRef) string {
+		switch ref.Source {
+		case InputTemplateSource:
+			return "IN"
+		case VarTemplateSource:
+			return strings.ToUpper(ref.Var)
+		case OutputTemplateSource:
+			return ref.Node + ":" + ref.Path
+		}
+		return "?"
+	}
+
+	tests := []struct {
+		name     string
+		template Template
+		want     string
+	}{
+		{name: "no refs", template: "plain", want: "plain"},
+		{name: "several refs", template: "{{input}} in {{ vars.lang }}", want: "IN in LANG"},
+		{name: "node path", template: "[{{nodes.a.output.x}}]", want: "[a:x]"},
+		{name: "unparsed ref is dropped", template: "a{{unknown}}b", want: "ab"},
+		{name: "single braces are kept", template: "{input}", want: "{input}"},
+		{name: "escaped ref is kept as text", template: `\{{input}} is {{input}}`, want: "{{input}} is IN"},
+		{name: "escaped unknown ref", template: `use \{{ name }}`, want: "use {{ name }}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.template.Render(values); got != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateSingleRef(t *testing.T) {
+	tests := []struct {
+		template Template
+		want     string
+		wantOk   bool
+	}{
+		{template: "{{nodes.a.output}}", want: "nodes.a.output", wantOk: true},
+		{template: "{{ data }}", want: "data", wantOk: true},
+		{template: " {{data}}"},
+		{template: "{{data}}{{input}}"},
+		{template: "{{unknown}}"},
+		{template: `\{{data}}`},
+		{template: "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.template), func(t *testing.T) {
+			ref, ok := tt.template.SingleRef()
+			if ok != tt.wantOk || ref.Expr != tt.want {
+				t.Fatalf("SingleRef() = %q, %v, want %q, %v", ref.Expr, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestGraphResolveRef(t *testing.T) {
+	legacy, err := LegacyGraph(map[int]map[int][]string{
+		1: {0: {"n1", "n2"}, 1: {"n3"}},
+	}, ScriptOptions{})
+	if err != nil {
+		t.Fatalf("LegacyGraph() unexpected error: %v", err)
+	}
+	graph := Graph{Nodes: []GraphNode{{Name: "a", NodeId: "1"}}}
+
+	tests := []struct {
+		name     string
+		graph    Graph
+		template Template
+		wantNode string
+		wantErr  string
+	}{
+		{name: "graph node", graph: graph, template: "{{nodes.a.output}}", wantNode: "a"},
+		{name: "unknown graph node", graph: graph, template: "{{nodes.b.output}}", wantErr: "unknown node b"},
+		{name: "step in graph", graph: graph, template: "{{steps.1.output}}", wantErr: "step or chain not found"},
+		{name: "legacy step", graph: legacy, template: "{{steps.1.output}}", wantNode: "step1"},
+		{name: "legacy chain ends with its last node", graph: legacy, template: "{{steps.1.chains.0.output}}", wantNode: "step1.chain0.1"},
+		{name: "legacy unknown chain", graph: legacy, template: "{{steps.1.chains.5.output}}", wantErr: "step or chain not found"},
+		{name: "not an output", graph: graph, template: "{{input}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, ok := tt.template.SingleRef()
+			if !ok {
+				t.Fatalf("SingleRef() of %s failed", tt.template)
+			}
+
+			resolved, err := tt.graph.ResolveRef(ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ResolveRef() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveRef() unexpected error: %v", err)
+			}
+			if resolved.Node != tt.wantNode {
+				t.Fatalf("ResolveRef() node = %q, want %q", resolved.Node, tt.wantNode)
+			}
+		})
+	}
+}
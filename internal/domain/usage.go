@@ -0,0 +1,179 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+)
+
+// defaultUsageUnit цена токенов по умолчанию указывается за миллион, как в прайсах провайдеров
+const defaultUsageUnit = 1_000_000
+
+type UsageGroup string
+
+const (
+	UsageByRun    UsageGroup = "run"
+	UsageByScript UsageGroup = "script"
+	UsageByAuthor UsageGroup = "author" // автор сценария, а не запуска
+	UsageByNode   UsageGroup = "node"
+)
+
+type (
+	// UsageOptions где в ответе ноды число токенов и сколько они стоят. Для потоковой ноды пути ищутся
+	// в событиях потока, используется последнее событие, в котором они есть
+	UsageOptions struct {
+		PromptTokens     string  `json:"prompt_tokens,omitempty"`     // json_path: usage.prompt_tokens
+		CompletionTokens string  `json:"completion_tokens,omitempty"` // json_path: usage.completion_tokens
+		PromptPrice      float64 `json:"prompt_price,omitempty"`      // цена unit токенов запроса
+		CompletionPrice  float64 `json:"completion_price,omitempty"`  // цена unit токенов ответа
+		Unit             int64   `json:"unit,omitempty"`              // токенов в единице цены, по умолчанию миллион
+	}
+
+	// Usage расход одного вызова ноды или сумма по вызовам
+	Usage struct {
+		PromptTokens     int64
+		CompletionTokens int64
+		Cost             float64
+	}
+
+	// UsageFilter период [From, To) по времени вызова нод. AuthorId - только сценарии автора, пусто - все
+	UsageFilter struct {
+		From     time.Time
+		To       time.Time
+		GroupBy  UsageGroup
+		AuthorId string
+	}
+
+	// UsageTotal расход по запуску, сценарию, автору или ноде за период
+	UsageTotal struct {
+		Key   string
+		Calls int64
+		Usage Usage
+	}
+)
+
+func (o UsageOptions) Enabled() bool {
+	return o.PromptTokens != "" || o.CompletionTokens != ""
+}
+
+func (o UsageOptions) Validate() error {
+	if o.PromptPrice < 0 || o.CompletionPrice < 0 || o.Unit < 0 {
+		return fmt.Errorf("usage: values can't be negative")
+	}
+
+	if !o.Enabled() {
+		if o.PromptPrice != 0 || o.CompletionPrice != 0 || o.Unit != 0 {
+			return fmt.Errorf("usage: prompt_tokens or completion_tokens is required")
+		}
+		return nil
+	}
+
+	for _, expr := range []string{o.PromptTokens, o.CompletionTokens} {
+		if expr == "" {
+			continue
+		}
+		if _, err := parseJsonPath(expr); err != nil {
+			return fmt.Errorf("usage: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Extract расход из json ответа. false - в ответе нет ни одного из путей
+func (o UsageOptions) Extract(body []byte) (Usage, bool) {
+	data, err := decodeJson(body)
+	if err != nil {
+		return Usage{}, false
+	}
+
+	prompt, promptOk := tokensAt(data, o.PromptTokens)
+	completion, completionOk := tokensAt(data, o.CompletionTokens)
+	if !promptOk && !completionOk {
+		return Usage{}, false
+	}
+
+	return o.usage(prompt, completion), true
+}
+
+func (o UsageOptions) usage(prompt, completion int64) Usage {
+	unit := o.Unit
+	if unit == 0 {
+		unit = defaultUsageUnit
+	}
+
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		Cost:             (float64(prompt)*o.PromptPrice + float64(completion)*o.CompletionPrice) / float64(unit),
+	}
+}
+
+// tokensAt число по пути: json число или строка с числом
+func tokensAt(data interface{}, expr string) (int64, bool) {
+	if expr == "" {
+		return 0, false
+	}
+
+	path, err := parseJsonPath(expr)
+	if err != nil {
+		return 0, false
+	}
+
+	value, ok := path.find(data)
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		tokens, err := v.Int64()
+		return tokens, err == nil
+	case string:
+		tokens, err := strconv.ParseInt(v, 10, 64)
+		return tokens, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		Cost:             u.Cost + other.Cost,
+	}
+}
+
+func (g UsageGroup) Validate() error {
+	switch g {
+	case UsageByRun, UsageByScript, UsageByAuthor, UsageByNode:
+		return nil
+	default:
+		return fmt.Errorf("unknown usage group %s", g)
+	}
+}
+
+func (f UsageFilter) ToModel() models.UsageFilter {
+	return models.UsageFilter{
+		From:     f.From,
+		To:       f.To,
+		GroupBy:  string(f.GroupBy),
+		AuthorId: f.AuthorId,
+	}
+}
+
+func (UsageTotal) FromModel(m models.UsageTotal) UsageTotal {
+	return UsageTotal{
+		Key:   m.Key,
+		Calls: m.Calls,
+		Usage: Usage{
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+			Cost:             m.Cost,
+		},
+	}
+}
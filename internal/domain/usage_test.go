@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUsageOptionsExtract(t *testing.T) {
+	openai := UsageOptions{
+		PromptTokens:     "usage.prompt_tokens",
+		CompletionTokens: "usage.completion_tokens",
+		PromptPrice:      2,
+		CompletionPrice:  8,
+	}
+
+	tests := []struct {
+		name    string
+		options UsageOptions
+		body    string
+		want    Usage
+		wantOk  bool
+	}{
+		{
+			name:    "both counters",
+			options: openai,
+			body:    `{"usage":{"prompt_tokens":1000,"completion_tokens":500}}`,
+			want:    Usage{PromptTokens: 1000, CompletionTokens: 500, Cost: 0.006},
+			wantOk:  true,
+		},
+		{
+			name:    "only prompt counter",
+			options: openai,
+			body:    `{"usage":{"prompt_tokens":250000}}`,
+			want:    Usage{PromptTokens: 250000, Cost: 0.5},
+			wantOk:  true,
+		},
+		{
+			name:    "counter as string",
+			options: openai,
+			body:    `{"usage":{"prompt_tokens":"10","completion_tokens":"20"}}`,
+			want:    Usage{PromptTokens: 10, CompletionTokens: 20, Cost: 0.00018},
+			wantOk:  true,
+		},
+		{
+			name:    "custom unit",
+			options: UsageOptions{PromptTokens: "in", CompletionTokens: "out", PromptPrice: 0.01, CompletionPrice: 0.03, Unit: 1000},
+			body:    `{"in":2000,"out":1000}`,
+			want:    Usage{PromptTokens: 2000, CompletionTokens: 1000, Cost: 0.05},
+			wantOk:  true,
+		},
+		{
+			name:    "path through array",
+			options: UsageOptions{CompletionTokens: "meta.[0].tokens"},
+			body:    `{"meta":[{"tokens":7}]}`,
+			want:    Usage{CompletionTokens: 7},
+			wantOk:  true,
+		},
+		{
+			name:    "no counters in response",
+			options: openai,
+			body:    `{"choices":[]}`,
+		},
+		{
+			name:    "fractional counter",
+			options: openai,
+			body:    `{"usage":{"prompt_tokens":1.5}}`,
+		},
+		{
+			name:    "not json",
+			options: openai,
+			body:    `data: {"usage":{}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.options.Extract([]byte(tt.body))
+			if ok != tt.wantOk {
+				t.Fatalf("Extract() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got.PromptTokens != tt.want.PromptTokens || got.CompletionTokens != tt.want.CompletionTokens ||
+				math.Abs(got.Cost-tt.want.Cost) > 1e-12 {
+				t.Fatalf("Extract() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsageOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options UsageOptions
+		wantErr string
+	}{
+		{name: "disabled", options: UsageOptions{}},
+		{name: "prompt only", options: UsageOptions{PromptTokens: "usage.input", PromptPrice: 1}},
+		{name: "price without counters", options: UsageOptions{PromptPrice: 1}, wantErr: "is required"},
+		{name: "negative price", options: UsageOptions{PromptTokens: "a", CompletionPrice: -1}, wantErr: "can't be negative"},
+		{name: "negative unit", options: UsageOptions{PromptTokens: "a", Unit: -1}, wantErr: "can't be negative"},
+		{name: "bad path", options: UsageOptions{CompletionTokens: "usage..out"}, wantErr: "empty segment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUsageAdd(t *testing.T) {
+	total := Usage{}
+	for _, usage := range []Usage{
+		{PromptTokens: 10, CompletionTokens: 5, Cost: 0.25},
+		{PromptTokens: 1, Cost: 0.5},
+		{CompletionTokens: 4},
+	} {
+		total = total.Add(usage)
+	}
+
+	if want := (Usage{PromptTokens: 11, CompletionTokens: 9, Cost: 0.75}); total != want {
+		t.Fatalf("Add() = %+v, want %+v", total, want)
+	}
+}
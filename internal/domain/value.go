@@ -0,0 +1,27 @@
+package domain
+
+import "encoding/json"
+
+// TypedValue результат узла с учетом его mime: json результат - json значение (объект, массив, число...),
+// остальное - строка. Так результаты попадают в тела запросов и ответ запуска без потери типа
+func TypedValue(output, mime string) interface{} {
+	if !IsJsonMime(mime) || !json.Valid([]byte(output)) {
+		return output
+	}
+
+	value, err := decodeJson([]byte(output))
+	if err != nil {
+		return output
+	}
+
+	return value
+}
+
+// LookupValue значение по пути gojsonq внутри результата с сохранением типа, пустой путь - результат целиком
+func LookupValue(output, mime, path string) (interface{}, bool) {
+	if path == "" {
+		return TypedValue(output, mime), true
+	}
+
+	return lookupPath(output, path)
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestTypedValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		mime   string
+		want   interface{}
+	}{
+		{name: "object", output: `{"a":[1,"b"]}`, mime: JsonContentType, want: map[string]interface{}{"a": []interface{}{json.Number("1"), "b"}}},
+		{name: "big number keeps precision", output: `12345678901234567890`, mime: JsonContentType, want: json.Number("12345678901234567890")},
+		{name: "json string", output: `"text"`, mime: "application/problem+json", want: "text"},
+		{name: "boolean", output: `true`, mime: JsonContentType, want: true},
+		{name: "null", output: `null`, mime: JsonContentType, want: nil},
+		{name: "invalid json stays text", output: `{"a":`, mime: JsonContentType, want: `{"a":`},
+		{name: "text mime stays text", output: `{"a":1}`, mime: TextContentType, want: `{"a":1}`},
+		{name: "empty mime stays text", output: `1`, want: `1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TypedValue(tt.output, tt.mime); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("TypedValue(%q, %q) = %#v, want %#v", tt.output, tt.mime, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupValue(t *testing.T) {
+	output := `{"items":[{"id":7,"tags":["a"]}],"title":"x"}`
+
+	tests := []struct {
+		name   string
+		mime   string
+		path   string
+		want   interface{}
+		wantOk bool
+	}{
+		{name: "whole result", mime: JsonContentType, want: TypedValue(output, JsonContentType), wantOk: true},
+		{name: "whole text result", mime: TextContentType, want: output, wantOk: true},
+		{name: "nested array", mime: JsonContentType, path: "items.[0].tags", want: []interface{}{"a"}, wantOk: true},
+		{name: "string", mime: JsonContentType, path: "title", want: "x", wantOk: true},
+		{name: "missing path", mime: JsonContentType, path: "nope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := LookupValue(output, tt.mime, tt.path)
+			if ok != tt.wantOk || (ok && !reflect.DeepEqual(got, tt.want)) {
+				t.Fatalf("LookupValue(%q) = %#v, %v, want %#v, %v", tt.path, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package converters
+
+import (
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+)
+
+func MakeNodeBreakerResponse(b domain.NodeBreaker) models.NodeBreakerResponse {
+	res := models.NodeBreakerResponse{
+		NodeId:   b.NodeId,
+		NodeName: b.NodeName,
+		State:    b.State,
+		Failures: b.Failures,
+	}
+
+	if !b.OpenedAt.IsZero() {
+		res.OpenedAt = b.OpenedAt.UnixMilli()
+	}
+
+	return res
+}
@@ -0,0 +1,122 @@
+package converters
+
+import (
+	"encoding/json"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+)
+
+func MakeRunScriptResponse(run domain.ScriptRun) models.RunScriptResponse {
+	res := models.RunScriptResponse{
+		RunId:      run.Id,
+		ScriptId:   run.ScriptId,
+		Status:     string(run.Status),
+		Result:     resultValue(run.Result, run.ResultMime),
+		ResultMime: run.ResultMime,
+		Error:      run.Error,
+		Usage:      MakeUsageResponse(run.Usage),
+		CreatedAt:  run.CreatedAt.UnixMilli(),
+		UpdatedAt:  run.UpdatedAt.UnixMilli(),
+	}
+
+	for _, fallback := range run.Report.Fallbacks {
+		res.Fallbacks = append(res.Fallbacks, models.RunFallbackResponse{
+			GraphNode:  fallback.GraphNode,
+			NodeId:     fallback.NodeId,
+			FallbackId: fallback.FallbackId,
+			Reason:     fallback.Reason,
+		})
+	}
+
+	for _, failure := range run.Report.Failures {
+		res.Failures = append(res.Failures, models.RunFailureResponse{
+			GraphNode: failure.GraphNode,
+			Step:      failure.Step,
+			Chain:     failure.Chain,
+			Action:    string(failure.Action),
+			Error:     failure.Error,
+		})
+	}
+
+	return res
+}
+
+func MakeRunHistoryResponse(run domain.ScriptRun, steps []domain.RunStep) models.RunHistoryResponse {
+	res := models.RunHistoryResponse{
+		RunScriptResponse: MakeRunScriptResponse(run),
+		Steps:             make([]models.RunStepResponse, len(steps)),
+	}
+
+	for i, step := range steps {
+		res.Steps[i] = models.RunStepResponse{
+			Step:           step.Step,
+			Chain:          step.Chain,
+			Position:       step.Position,
+			NodeId:         step.NodeId,
+			GraphNode:      step.GraphNode,
+			Attempt:        step.Attempt,
+			RequestBody:    step.RequestBody,
+			RequestHeaders: step.RequestHeaders,
+			Response:       step.Response,
+			Output:         step.Output,
+			StatusCode:     step.StatusCode,
+			LatencyMs:      step.Latency.Milliseconds(),
+			Error:          step.Error,
+			CacheHit:       step.CacheHit,
+			Usage:          MakeUsageResponse(step.Usage),
+			StartedAt:      step.StartedAt.UnixMilli(),
+		}
+	}
+
+	return res
+}
+
+func MakeDryRunResponse(requests []domain.DryRunRequest) models.DryRunResponse {
+	res := models.DryRunResponse{Requests: make([]models.DryRunRequestResponse, len(requests))}
+
+	for i, request := range requests {
+		res.Requests[i] = models.DryRunRequestResponse{
+			GraphNode:   request.GraphNode,
+			NodeId:      request.NodeId,
+			NodeName:    request.NodeName,
+			Step:        request.Step,
+			Chain:       request.Chain,
+			Position:    request.Position,
+			Url:         request.Url,
+			Method:      request.Method,
+			Headers:     request.Headers,
+			ContentType: request.ContentType,
+			Body:        request.Body,
+			Error:       request.Error,
+		}
+	}
+
+	return res
+}
+
+func MakeRunEventResponse(event domain.RunEvent) models.RunEventResponse {
+	return models.RunEventResponse{
+		RunId:     event.RunId,
+		Step:      event.Step,
+		Chain:     event.Chain,
+		GraphNode: event.GraphNode,
+		NodeName:  event.NodeName,
+		Mime:      event.Mime,
+		Output:    resultValue(event.Output, event.Mime),
+		Error:     event.Error,
+	}
+}
+
+// resultValue json результат отдается json значением без повторного разбора, остальное - строкой
+func resultValue(output, mime string) interface{} {
+	if output == "" {
+		return nil
+	}
+
+	if domain.IsJsonMime(mime) && json.Valid([]byte(output)) {
+		return json.RawMessage(output)
+	}
+
+	return output
+}
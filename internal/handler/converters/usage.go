@@ -0,0 +1,37 @@
+package converters
+
+import (
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+)
+
+func MakeUsageResponse(usage domain.Usage) models.UsageResponse {
+	return models.UsageResponse{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+	}
+}
+
+func MakeUsageTotalsResponse(filter domain.UsageFilter, totals []domain.UsageTotal) models.UsageTotalsResponse {
+	res := models.UsageTotalsResponse{
+		From:    filter.From.UnixMilli(),
+		To:      filter.To.UnixMilli(),
+		GroupBy: string(filter.GroupBy),
+		Totals:  make([]models.UsageTotalResponse, len(totals)),
+	}
+
+	var sum domain.Usage
+	for i, total := range totals {
+		res.Totals[i] = models.UsageTotalResponse{
+			Key:           total.Key,
+			Calls:         total.Calls,
+			UsageResponse: MakeUsageResponse(total.Usage),
+		}
+		res.Total.Calls += total.Calls
+		sum = sum.Add(total.Usage)
+	}
+	res.Total.UsageResponse = MakeUsageResponse(sum)
+
+	return res
+}
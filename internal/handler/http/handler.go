@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/warehouse/ai-service/internal/domain"
@@ -20,6 +21,9 @@ import (
 
 const (
 	IpHeader = "X-Forwarded-For"
+
+	defaultRunsLimit = 20
+	maxRunsLimit     = 100
 )
 
 type (
@@ -31,6 +35,7 @@ type (
 	WarehouseRequestHandler interface {
 		HandleJsonRequest(router *mux.Router, main, path, method string, handler jsonHandler)
 		HandleJsonRequestWithMiddleware(router *mux.Router, main, path, method string, handler jsonHandler, middleware func(http.Handler) http.Handler)
+		HandleStreamRequestWithMiddleware(router *mux.Router, main, path, method string, handler streamHandler, middleware func(http.Handler) http.Handler)
 	}
 
 	warehouseRequestHandler struct {
@@ -45,6 +50,9 @@ type (
 		Error   *errors.Error `json:"error,omitempty"`
 	}
 	jsonHandler func(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse
+
+	// streamHandler сам пишет события в поток, ошибка возвращается только если поток еще не начат
+	streamHandler func(ctx context.Context, acc *domain.Account, r *http.Request, events *writers.EventWriter) *errors.Error
 )
 
 func NewWarehouseJsonRequestHandler(
@@ -105,6 +113,51 @@ func (wh *warehouseRequestHandler) HandleJsonRequest(router *mux.Router, main, p
 	})).Methods(method)
 }
 
+func (wh *warehouseRequestHandler) HandleStreamRequestWithMiddleware(
+	router *mux.Router,
+	main, path, method string,
+	handler streamHandler,
+	middleware func(http.Handler) http.Handler,
+) {
+	router.Handle(path, middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wh.requestWithStreamResult(main, path, method, w, r, handler)
+	}))).Methods(method)
+}
+
+func (wh *warehouseRequestHandler) requestWithStreamResult(
+	main, path, method string,
+	w http.ResponseWriter,
+	r *http.Request,
+	handler streamHandler,
+) {
+	defer r.Body.Close()
+
+	var acc *domain.Account
+	if accPayload := r.Context().Value(domain.AccountCtxKey); accPayload != nil {
+		acc = accPayload.(*domain.Account)
+	}
+
+	events, err := writers.NewEventWriter(w)
+	if err != nil {
+		e := errors.WD(errors.InternalError, err)
+		errResp := converters.MakeJsonErrorResponseWithErrorsError(e)
+		wh.logRequest(main, path, method, r.Header.Get(IpHeader), false, &errResp, acc)
+
+		writers.SendJSON(w, int(e.Code), errResp)
+		return
+	}
+
+	if e := handler(r.Context(), acc, r, events); e != nil {
+		errResp := converters.MakeJsonErrorResponseWithErrorsError(e)
+		wh.logRequest(main, path, method, r.Header.Get(IpHeader), false, &errResp, acc)
+
+		writers.SendJSON(w, int(e.Code), errResp)
+		return
+	}
+
+	wh.logRequest(main, path, method, r.Header.Get(IpHeader), true, nil, acc)
+}
+
 func (wh *warehouseRequestHandler) requestWithJsonResult(
 	main, path, method string,
 	w http.ResponseWriter,
@@ -163,6 +216,20 @@ func (wh *warehouseRequestHandler) logRequest(main, path, method, ip string, suc
 	}
 }
 
+func queryInt(r *http.Request, name string, defaultValue int) (int, *errors.Error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, errors.WD(errors.ParseError, fmt.Errorf("query parameter %s: %w", name, err))
+	}
+
+	return value, nil
+}
+
 func rolesPermissionsInterceptor(currentRole domain.Role, roles ...domain.Role) bool {
 	for _, role := range roles {
 		if currentRole == role {
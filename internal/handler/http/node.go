@@ -8,9 +8,11 @@ import (
 	timeAdpt "github.com/warehouse/ai-service/internal/adapter/time"
 	"github.com/warehouse/ai-service/internal/config"
 	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/converters"
 	"github.com/warehouse/ai-service/internal/handler/middlewares"
 	"github.com/warehouse/ai-service/internal/handler/models"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
+	wh_converters "github.com/warehouse/ai-service/internal/pkg/utils/converters"
 	"github.com/warehouse/ai-service/internal/service/node"
 
 	"github.com/gorilla/mux"
@@ -60,7 +62,8 @@ func (h *nodeHandler) Shutdown() {
 func (h *nodeHandler) FillHandlers(router *mux.Router) {
 	base := "/node"
 	r := router.PathPrefix(base).Subrouter()
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/add", http.MethodDelete, h.addHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/add", http.MethodPost, h.addHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/breakers", http.MethodGet, h.breakersHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
 }
 
 func (h *nodeHandler) addHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
@@ -88,11 +91,45 @@ func (h *nodeHandler) addHandler(ctx context.Context, acc *domain.Account, r *ht
 
 	return whJsonSuccessResponse(
 		models.AddNodeResponse{
-			Id:     createdNode.Id,
-			Body:   createdNode.Body,
-			Header: createdNode.Headers,
+			Id:          createdNode.Id,
+			Body:        createdNode.Body,
+			Header:      createdNode.Headers,
+			RetryPolicy: createdNode.RetryPolicy,
+
+			ResponseExtractor: createdNode.Extractor(),
+			Stream:            createdNode.Stream,
+			RateLimit:         createdNode.RateLimit,
+			Cache:             createdNode.Cache,
+			Usage:             createdNode.Usage,
 		},
 		http.StatusCreated,
 		nil,
 	)
 }
+
+func (h *nodeHandler) breakersHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthFailed)
+	}
+
+	if ok := rolesPermissionsInterceptor(acc.Role, domain.RoleAdmin); !ok {
+		return whJsonErrorResponse(errors.PermissionDenied)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	breakers, err := h.nodeService.Breakers(ctx)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.NodeBreakersResponse{
+			Breakers: wh_converters.MapSlice(breakers, converters.MakeNodeBreakerResponse),
+		},
+		http.StatusOK,
+		nil,
+	)
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	timeAdpt "github.com/warehouse/ai-service/internal/adapter/time"
 	"github.com/warehouse/ai-service/internal/config"
 	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/converters"
 	"github.com/warehouse/ai-service/internal/handler/middlewares"
 	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/handler/writers"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
+	wh_converters "github.com/warehouse/ai-service/internal/pkg/utils/converters"
 	"github.com/warehouse/ai-service/internal/service/script"
 
 	"github.com/gorilla/mux"
@@ -60,8 +64,38 @@ func (h *scriptHandler) Shutdown() {
 func (h *scriptHandler) FillHandlers(router *mux.Router) {
 	base := "/script"
 	r := router.PathPrefix(base).Subrouter()
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/run", http.MethodDelete, h.runHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/create", http.MethodDelete, h.createHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/run", http.MethodPost, h.runHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleStreamRequestWithMiddleware(r, base, "/run/stream", http.MethodGet, h.streamRunHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/run/{id}", http.MethodGet, h.getRunHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/runs", http.MethodGet, h.listRunsHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/runs/{id}", http.MethodGet, h.runHistoryHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/create", http.MethodPost, h.createHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	// расход считается по всем сценариям пользователя, поэтому вне /script
+	h.reqHandler.HandleJsonRequestWithMiddleware(router, "", "/usage", http.MethodGet, h.usageHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
+	// ссылка на скачивание открывается без авторизации, чтобы ее можно было отдать браузеру, но она подписана
+	// сервисом и ограничена по сроку
+	r.HandleFunc("/blob/{key}", h.blobHandler).Methods(http.MethodGet)
+}
+
+func (h *scriptHandler) blobHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeouts.RequestTimeout)
+	defer cancel()
+
+	query := r.URL.Query()
+	data, mime, err := h.scriptService.DownloadBlob(ctx, mux.Vars(r)["key"], query.Get("expires"), query.Get("signature"))
+	if err != nil {
+		writers.SendJSON(w, int(err.Code), converters.MakeJsonErrorResponseWithErrorsError(err))
+		return
+	}
+
+	// mime выбирает апстрим, поэтому браузер не должен открывать файл на origin API (text/html, svg - это XSS):
+	// содержимое только скачивается и не угадывается по байтам
+	if mime != "" {
+		w.Header().Set(domain.HeaderContentType, mime)
+	}
+	w.Header().Set(domain.HeaderContentTypeOptions, "nosniff")
+	w.Header().Set(domain.HeaderContentDisposition, "attachment")
+	writers.SendBytes(w, http.StatusOK, data)
 }
 
 func (h *scriptHandler) runHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
@@ -78,20 +112,198 @@ func (h *scriptHandler) runHandler(ctx context.Context, acc *domain.Account, r *
 		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
 	}
 
-	result, err := h.scriptService.Run(ctx, req)
+	// dry_run только собирает запросы к нодам, запуск не создается
+	if r.URL.Query().Get("dry_run") == "true" {
+		requests, err := h.scriptService.DryRun(ctx, acc, req)
+		if err != nil {
+			return whJsonErrorResponse(err)
+		}
+
+		return whJsonSuccessResponse(converters.MakeDryRunResponse(requests), http.StatusOK, nil)
+	}
+
+	run, err := h.scriptService.Enqueue(ctx, acc, req)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		converters.MakeRunScriptResponse(run),
+		http.StatusAccepted,
+		nil,
+	)
+}
+
+func (h *scriptHandler) streamRunHandler(ctx context.Context, acc *domain.Account, r *http.Request, events *writers.EventWriter) *errors.Error {
+	if acc == nil {
+		return errors.AuthFailed
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RunTimeout)
+	defer cancel()
+
+	req := models.RunScriptRequest{
+		Id:        r.URL.Query().Get("id"),
+		EnterData: r.URL.Query().Get("enter_data"),
+	}
+	if name, mode := r.URL.Query().Get("cassette"), r.URL.Query().Get("cassette_mode"); name != "" || mode != "" {
+		req.Cassette = &models.RunCassetteRequest{Name: name, Mode: mode}
+	}
+
+	// Поток начинаем с первым событием, чтобы ошибки до старта выполнения ушли обычным JSON-ответом
+	started, disconnected := false, false
+	observe := func(event domain.RunEvent) {
+		if disconnected {
+			return
+		}
+
+		if !started {
+			events.Start()
+			started = true
+		}
+
+		if err := events.Send(string(event.Type), converters.MakeRunEventResponse(event)); err != nil {
+			// клиент отключился, отменяем запуск, его итог все равно сохранится в истории
+			disconnected = true
+			cancel()
+		}
+	}
+
+	_, err := h.scriptService.RunStream(ctx, acc, req, observe)
+	if err != nil && !started {
+		return err
+	}
+
+	if err != nil && !disconnected {
+		_ = events.Send(string(domain.RunFailedEvent), converters.MakeJsonErrorResponseWithErrorsError(err))
+	}
+
+	return nil
+}
+
+func (h *scriptHandler) getRunHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	run, err := h.scriptService.GetRun(ctx, acc, mux.Vars(r)["id"])
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		converters.MakeRunScriptResponse(run),
+		http.StatusOK,
+		nil,
+	)
+}
+
+func (h *scriptHandler) listRunsHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	limit, err := queryInt(r, "limit", defaultRunsLimit)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+	if limit <= 0 || limit > maxRunsLimit {
+		limit = maxRunsLimit
+	}
+
+	offset, err := queryInt(r, "offset", 0)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	runs, err := h.scriptService.ListRuns(ctx, acc, limit, offset)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
 
 	return whJsonSuccessResponse(
-		models.RunScriptResponse{
-			Result: result,
+		models.ListRunsResponse{
+			Runs: wh_converters.MapSlice(runs, converters.MakeRunScriptResponse),
 		},
 		http.StatusOK,
 		nil,
 	)
 }
 
+func (h *scriptHandler) runHistoryHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	run, steps, err := h.scriptService.GetRunHistory(ctx, acc, mux.Vars(r)["id"])
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		converters.MakeRunHistoryResponse(run, steps),
+		http.StatusOK,
+		nil,
+	)
+}
+
+// usageHandler расход за период [from, to) в unix мс, по умолчанию - с начала текущего месяца
+func (h *scriptHandler) usageHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	now := h.timeAdapter.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	from, err := queryInt(r, "from", int(monthStart.UnixMilli()))
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	to, err := queryInt(r, "to", int(now.UnixMilli()))
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	filter := domain.UsageFilter{
+		From:    h.timeAdapter.MillisecondsToTime(int64(from)),
+		To:      h.timeAdapter.MillisecondsToTime(int64(to)),
+		GroupBy: domain.UsageGroup(r.URL.Query().Get("group_by")),
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = domain.UsageByScript
+	}
+
+	totals, err := h.scriptService.Usage(ctx, acc, filter)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		converters.MakeUsageTotalsResponse(filter, totals),
+		http.StatusOK,
+		nil,
+	)
+}
+
 func (h *scriptHandler) createHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
 	if acc == nil {
 		return whJsonErrorResponse(errors.AuthFailed)
@@ -4,20 +4,46 @@ import "github.com/warehouse/ai-service/internal/domain"
 
 type (
 	AddNodeRequest struct {
-		Name              string                 `json:"name"`
-		Body              map[string]interface{} `json:"body"`
-		RequestMime       string                 `json:"request_mime"`
-		ResponseMime      string                 `json:"response_mime"`
-		Url               string                 `json:"url"`
-		Method            string                 `json:"method"`
-		Headers           map[string]interface{} `json:"headers"`
-		ResponseDirection string                 `json:"response_direction"`
-		ApiKey            string                 `json:"api_key"`
+		Name              string                   `json:"name"`
+		Body              map[string]interface{}   `json:"body"`
+		RequestMime       string                   `json:"request_mime"`
+		ResponseMime      string                   `json:"response_mime"`
+		Url               string                   `json:"url"`
+		Method            string                   `json:"method"`
+		Headers           map[string]interface{}   `json:"headers"`
+		ResponseDirection string                   `json:"response_direction"`
+		ResponseExtractor domain.ResponseExtractor `json:"response_extractor"`
+		ApiKey            string                   `json:"api_key"`
+		TimeoutMs         int64                    `json:"timeout_ms"`
+		RetryPolicy       *domain.RetryPolicy      `json:"retry_policy"`
+		Stream            domain.StreamOptions     `json:"stream"`
+		RateLimit         domain.RateLimit         `json:"rate_limit"`
+		Cache             domain.CacheOptions      `json:"cache"`
+		Usage             domain.UsageOptions      `json:"usage"`
+	}
+
+	NodeBreakerResponse struct {
+		NodeId   string `json:"node_id"`
+		NodeName string `json:"node_name"`
+		State    string `json:"state"`
+		Failures int    `json:"failures"`
+		OpenedAt int64  `json:"opened_at,omitempty"`
+	}
+
+	NodeBreakersResponse struct {
+		Breakers []NodeBreakerResponse `json:"breakers"`
 	}
 
 	AddNodeResponse struct {
-		Id     string                      `json:"id"`
-		Body   map[string]domain.BodyField `json:"body"`
-		Header map[string]domain.Header    `json:"header"`
+		Id          string                      `json:"id"`
+		Body        map[string]domain.BodyField `json:"body"`
+		Header      map[string]domain.Header    `json:"header"`
+		RetryPolicy domain.RetryPolicy          `json:"retry_policy"`
+
+		ResponseExtractor domain.ResponseExtractor `json:"response_extractor"`
+		Stream            domain.StreamOptions     `json:"stream"`
+		RateLimit         domain.RateLimit         `json:"rate_limit"`
+		Cache             domain.CacheOptions      `json:"cache"`
+		Usage             domain.UsageOptions      `json:"usage"`
 	}
 )
@@ -1,17 +1,121 @@
 package models
 
+import "github.com/warehouse/ai-service/internal/domain"
+
 type (
 	RunScriptRequest struct {
 		Id        string `json:"id"`
 		EnterData string `json:"enter_data"`
+
+		Files map[string]RunFileRequest `json:"files,omitempty"` // доступны шаблонам как {{files.<имя>}}
+		Mocks map[string]string         `json:"mocks,omitempty"` // результаты узлов для dry_run, ключ - имя узла графа
+
+		Cassette *RunCassetteRequest `json:"cassette,omitempty"` // по умолчанию - кассета из конфига
+	}
+
+	RunCassetteRequest struct {
+		Name string `json:"name"`
+		Mode string `json:"mode"` // record или replay
+	}
+
+	RunFileRequest struct {
+		Mime string `json:"mime,omitempty"`
+		Data string `json:"data"` // base64
 	}
 	RunScriptResponse struct {
-		Result string `json:"result"`
+		RunId      string        `json:"run_id"`
+		ScriptId   string        `json:"script_id"`
+		Status     string        `json:"status"`
+		Result     interface{}   `json:"result,omitempty"` // json результат - json значение, остальное - строка
+		ResultMime string        `json:"result_mime,omitempty"`
+		Error      string        `json:"error,omitempty"`
+		Usage      UsageResponse `json:"usage"`
+		CreatedAt  int64         `json:"created_at"`
+		UpdatedAt  int64         `json:"updated_at"`
+
+		Fallbacks []RunFallbackResponse `json:"fallbacks,omitempty"`
+		Failures  []RunFailureResponse  `json:"failures,omitempty"`
+	}
+
+	RunFallbackResponse struct {
+		GraphNode  string `json:"graph_node"`
+		NodeId     string `json:"node_id"`
+		FallbackId string `json:"fallback_id"`
+		Reason     string `json:"reason"`
+	}
+
+	RunFailureResponse struct {
+		GraphNode string `json:"graph_node"`
+		Step      int    `json:"step"`
+		Chain     *int   `json:"chain,omitempty"`
+		Action    string `json:"action"`
+		Error     string `json:"error"`
+	}
+
+	RunEventResponse struct {
+		RunId     string      `json:"run_id"`
+		Step      int         `json:"step"`
+		Chain     *int        `json:"chain"`
+		GraphNode string      `json:"graph_node,omitempty"`
+		NodeName  string      `json:"node_name,omitempty"`
+		Mime      string      `json:"mime,omitempty"`
+		Output    interface{} `json:"output,omitempty"` // как result запуска
+		Error     string      `json:"error,omitempty"`
+	}
+
+	ListRunsResponse struct {
+		Runs []RunScriptResponse `json:"runs"`
+	}
+
+	RunStepResponse struct {
+		Step           int               `json:"step"`
+		Chain          int               `json:"chain"`
+		Position       int               `json:"position"`
+		NodeId         string            `json:"node_id"`
+		GraphNode      string            `json:"graph_node"`
+		Attempt        int               `json:"attempt"`
+		RequestBody    string            `json:"request_body"`
+		RequestHeaders map[string]string `json:"request_headers"`
+		Response       string            `json:"response"`
+		Output         string            `json:"output"`
+		StatusCode     int               `json:"status_code"`
+		LatencyMs      int64             `json:"latency_ms"`
+		Error          string            `json:"error,omitempty"`
+		CacheHit       bool              `json:"cache_hit"`
+		Usage          UsageResponse     `json:"usage"`
+		StartedAt      int64             `json:"started_at"`
+	}
+
+	DryRunRequestResponse struct {
+		GraphNode   string            `json:"graph_node"`
+		NodeId      string            `json:"node_id"`
+		NodeName    string            `json:"node_name"`
+		Step        int               `json:"step"`
+		Chain       int               `json:"chain"`
+		Position    int               `json:"position"`
+		Url         string            `json:"url"`
+		Method      string            `json:"method"`
+		Headers     map[string]string `json:"headers"`
+		ContentType string            `json:"content_type,omitempty"`
+		Body        string            `json:"body"`
+		Error       string            `json:"error,omitempty"`
+	}
+
+	DryRunResponse struct {
+		Requests []DryRunRequestResponse `json:"requests"`
+	}
+
+	RunHistoryResponse struct {
+		RunScriptResponse
+		Steps []RunStepResponse `json:"steps"`
 	}
 
 	CreateScriptRequest struct {
 		Name          string                            `json:"name"`
 		Workflow      map[string][]interface{}          `json:"workflow"`
+		Steps         map[int]domain.StepOptions        `json:"steps"`
+		Graph         *domain.Graph                     `json:"graph"`
+		Vars          map[string]string                 `json:"vars"`
 		BodyPresets   map[string]map[string]interface{} `json:"body_presets"`
 		HeaderPresets map[string]map[string]string      `json:"header_presets"`
 	}
@@ -0,0 +1,23 @@
+package models
+
+type (
+	UsageResponse struct {
+		PromptTokens     int64   `json:"prompt_tokens"`
+		CompletionTokens int64   `json:"completion_tokens"`
+		Cost             float64 `json:"cost"`
+	}
+
+	UsageTotalResponse struct {
+		Key   string `json:"key"` // айди запуска, сценария, автора или ноды
+		Calls int64  `json:"calls"`
+		UsageResponse
+	}
+
+	UsageTotalsResponse struct {
+		From    int64                `json:"from"`
+		To      int64                `json:"to"`
+		GroupBy string               `json:"group_by"`
+		Totals  []UsageTotalResponse `json:"totals"`
+		Total   UsageTotalResponse   `json:"total"` // сумма по всем группам, key пустой
+	}
+)
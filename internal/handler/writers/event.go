@@ -0,0 +1,51 @@
+package writers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const EventStreamContentType = "text/event-stream"
+
+// EventWriter пишет ответ в формате Server-Sent Events, каждое событие сразу отправляется клиенту
+type EventWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func NewEventWriter(w http.ResponseWriter) (*EventWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("response writer does not support streaming")
+	}
+
+	return &EventWriter{
+		w:       w,
+		flusher: flusher,
+	}, nil
+}
+
+// Start отправляет заголовки потока, после этого ошибки можно передать только событием
+func (e *EventWriter) Start() {
+	e.w.Header().Set("Content-Type", EventStreamContentType)
+	e.w.Header().Set("Cache-Control", "no-cache")
+	e.w.Header().Set("Connection", "keep-alive")
+	e.w.Header().Set("X-Accel-Buffering", "no")
+	e.w.WriteHeader(http.StatusOK)
+	e.flusher.Flush()
+}
+
+func (e *EventWriter) Send(event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+
+	e.flusher.Flush()
+	return nil
+}
@@ -0,0 +1,47 @@
+package writers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	events, err := NewEventWriter(rec)
+	if err != nil {
+		t.Fatalf("NewEventWriter() unexpected error: %v", err)
+	}
+
+	events.Start()
+	if err := events.Send("node_result", map[string]string{"output": "a\nb"}); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	if err := events.Send("run_finished", map[string]int{"step": 1}); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != EventStreamContentType {
+		t.Fatalf("response = %d %q, want 200 %s", rec.Code, rec.Header().Get("Content-Type"), EventStreamContentType)
+	}
+	if !rec.Flushed {
+		t.Fatalf("events are not flushed")
+	}
+
+	// перевод строки в данных экранирован json, иначе он разорвал бы событие
+	want := "event: node_result\ndata: {\"output\":\"a\\nb\"}\n\n" +
+		"event: run_finished\ndata: {\"step\":1}\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestEventWriterWithoutFlusher(t *testing.T) {
+	if _, err := NewEventWriter(plainWriter{httptest.NewRecorder()}); err == nil {
+		t.Fatalf("NewEventWriter() error = nil, want error for writer without flush")
+	}
+}
@@ -0,0 +1,245 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	Closed   State = "closed"    // запросы проходят, считаем ошибки подряд
+	Open     State = "open"      // запросы сразу отклоняются до истечения OpenTimeout
+	HalfOpen State = "half_open" // пропускаем ограниченное количество пробных запросов
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+type Outcome int
+
+const (
+	Success Outcome = iota
+	Failure
+	Ignored // запрос прерван не по вине апстрима (например, отменен запуск), на состояние не влияет
+)
+
+type (
+	Settings struct {
+		FailureThreshold int           // ошибок подряд, после которых размыкаем, 0 - выключен
+		OpenTimeout      time.Duration // сколько держим разомкнутым до пробных запросов
+		HalfOpenMaxCalls int           // пробных запросов в полуоткрытом состоянии
+	}
+
+	Status struct {
+		Key      string
+		State    State
+		Failures int
+		OpenedAt time.Time
+	}
+
+	// Record состояние брейкера в хранилище
+	Record struct {
+		Key      string
+		State    State
+		Failures int
+		OpenedAt time.Time
+		InFlight int       // пробные запросы в полуоткрытом состоянии
+		Passed   int       // успешные пробные запросы
+		ProbedAt time.Time // когда начат последний пробный запрос
+	}
+
+	// Store хранилище состояний. Update изменяет состояние ключа под блокировкой, новый ключ - замкнутый брейкер
+	Store interface {
+		Update(ctx context.Context, key string, change func(record *Record)) error
+		List(ctx context.Context) ([]Record, error)
+	}
+
+	Registry interface {
+		// Acquire разрешает запрос по ключу, done нужно вызвать с результатом запроса
+		Acquire(ctx context.Context, key string) (done func(outcome Outcome), err error)
+		Statuses(ctx context.Context) ([]Status, error)
+	}
+
+	registry struct {
+		settings Settings
+		store    Store
+		now      func() time.Time
+	}
+
+	memoryStore struct {
+		mu      sync.Mutex
+		records map[string]Record
+	}
+)
+
+func IsOpen(err error) bool {
+	return errors.Is(err, ErrOpen)
+}
+
+// NewRegistry брейкеры с состоянием в store: в памяти процесса (NewMemoryStore) или общем для всех инстансов
+func NewRegistry(settings Settings, store Store) Registry {
+	if settings.HalfOpenMaxCalls <= 0 {
+		settings.HalfOpenMaxCalls = 1
+	}
+
+	return &registry{
+		settings: settings,
+		store:    store,
+		now:      time.Now,
+	}
+}
+
+func (r *registry) Acquire(ctx context.Context, key string) (func(outcome Outcome), error) {
+	if r.settings.FailureThreshold <= 0 {
+		return func(Outcome) {}, nil
+	}
+
+	var state State
+	allowed := false
+	err := r.store.Update(ctx, key, func(b *Record) {
+		now := r.now()
+		if b.State == Open {
+			if now.Sub(b.OpenedAt) < r.settings.OpenTimeout {
+				return
+			}
+
+			b.State = HalfOpen
+			b.InFlight = 0
+			b.Passed = 0
+		}
+
+		if b.State == HalfOpen {
+			// пробный запрос инстанса, который остановился, не ответив, считаем потерянным
+			if b.InFlight > 0 && now.Sub(b.ProbedAt) >= r.settings.OpenTimeout {
+				b.InFlight = 0
+			}
+			if b.InFlight+b.Passed >= r.settings.HalfOpenMaxCalls {
+				return
+			}
+			b.InFlight++
+			b.ProbedAt = now
+		}
+
+		state = b.State
+		allowed = true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("circuit breaker state: %w", err)
+	}
+	if !allowed {
+		return nil, ErrOpen
+	}
+
+	// результат пишется и после отмены запроса, иначе пробный запрос занимал бы место до OpenTimeout
+	reportCtx := context.WithoutCancel(ctx)
+	var once sync.Once
+	return func(outcome Outcome) {
+		once.Do(func() { _ = r.store.Update(reportCtx, key, r.report(state, outcome)) })
+	}, nil
+}
+
+func (r *registry) report(acquiredIn State, outcome Outcome) func(b *Record) {
+	return func(b *Record) {
+		if acquiredIn == HalfOpen && b.State == HalfOpen {
+			if b.InFlight > 0 {
+				b.InFlight--
+			}
+			switch outcome {
+			case Ignored:
+				return
+			case Failure:
+				r.open(b)
+				return
+			}
+
+			b.Passed++
+			if b.Passed >= r.settings.HalfOpenMaxCalls {
+				b.State = Closed
+				b.Failures = 0
+			}
+			return
+		}
+
+		// результат запроса, начатого до смены состояния, на полуоткрытый брейкер не влияет
+		if b.State != Closed || outcome == Ignored {
+			return
+		}
+
+		if outcome == Success {
+			b.Failures = 0
+			return
+		}
+
+		b.Failures++
+		if b.Failures >= r.settings.FailureThreshold {
+			r.open(b)
+		}
+	}
+}
+
+func (r *registry) open(b *Record) {
+	b.State = Open
+	b.OpenedAt = r.now()
+	b.InFlight = 0
+	b.Passed = 0
+}
+
+func (r *registry) Statuses(ctx context.Context) ([]Status, error) {
+	records, err := r.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(records))
+	for _, b := range records {
+		state := b.State
+		if state == Open && r.now().Sub(b.OpenedAt) >= r.settings.OpenTimeout {
+			state = HalfOpen
+		}
+
+		statuses = append(statuses, Status{
+			Key:      b.Key,
+			State:    state,
+			Failures: b.Failures,
+			OpenedAt: b.OpenedAt,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Key < statuses[j].Key })
+	return statuses, nil
+}
+
+// NewMemoryStore состояния в памяти процесса: каждый инстанс считает ошибки сам и видит только свои брейкеры
+func NewMemoryStore() Store {
+	return &memoryStore{records: make(map[string]Record)}
+}
+
+func (s *memoryStore) Update(_ context.Context, key string, change func(record *Record)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		record = Record{Key: key, State: Closed}
+	}
+
+	change(&record)
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryStore) List(context.Context) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, len(s.records))
+	for _, record := range s.records {
+		records = append(records, record)
+	}
+
+	return records, nil
+}
@@ -0,0 +1,200 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock ручное время брейкеров
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestRegistry(t *testing.T, settings Settings, store Store) (*registry, *testClock) {
+	t.Helper()
+
+	clock := &testClock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	r := NewRegistry(settings, store).(*registry)
+	r.now = func() time.Time { return clock.now }
+	return r, clock
+}
+
+func acquire(t *testing.T, r Registry, key string) func(Outcome) {
+	t.Helper()
+
+	done, err := r.Acquire(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Acquire(%s) unexpected error: %v", key, err)
+	}
+	return done
+}
+
+func requireOpen(t *testing.T, r Registry, key string) {
+	t.Helper()
+
+	if _, err := r.Acquire(context.Background(), key); !IsOpen(err) {
+		t.Fatalf("Acquire(%s) error = %v, want ErrOpen", key, err)
+	}
+}
+
+func requireState(t *testing.T, r Registry, key string, want State) {
+	t.Helper()
+
+	statuses, err := r.Statuses(context.Background())
+	if err != nil {
+		t.Fatalf("Statuses() unexpected error: %v", err)
+	}
+	for _, status := range statuses {
+		if status.Key == key {
+			if status.State != want {
+				t.Fatalf("state of %s = %s, want %s", key, status.State, want)
+			}
+			return
+		}
+	}
+	t.Fatalf("no status for %s, want %s", key, want)
+}
+
+func TestRegistryOpensAfterFailures(t *testing.T) {
+	r, _ := newTestRegistry(t, Settings{FailureThreshold: 3, OpenTimeout: time.Minute}, NewMemoryStore())
+
+	acquire(t, r, "a")(Failure)
+	acquire(t, r, "a")(Failure)
+	// успех обнуляет счетчик: размыкают только ошибки подряд
+	acquire(t, r, "a")(Success)
+	acquire(t, r, "a")(Failure)
+	acquire(t, r, "a")(Ignored)
+	acquire(t, r, "a")(Failure)
+	requireState(t, r, "a", Closed)
+
+	acquire(t, r, "a")(Failure)
+	requireState(t, r, "a", Open)
+	requireOpen(t, r, "a")
+
+	// брейкеры разных ключей независимы
+	acquire(t, r, "b")(Success)
+	requireState(t, r, "b", Closed)
+}
+
+func TestRegistryHalfOpen(t *testing.T) {
+	tests := []struct {
+		name      string
+		outcome   Outcome
+		wantState State
+	}{
+		{name: "probe success closes", outcome: Success, wantState: Closed},
+		{name: "probe failure opens again", outcome: Failure, wantState: Open},
+		{name: "ignored probe keeps half open", outcome: Ignored, wantState: HalfOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, clock := newTestRegistry(t, Settings{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenMaxCalls: 2}, NewMemoryStore())
+
+			acquire(t, r, "a")(Failure)
+			clock.advance(59 * time.Second)
+			requireOpen(t, r, "a")
+
+			clock.advance(time.Second)
+			requireState(t, r, "a", HalfOpen)
+			first := acquire(t, r, "a")
+			second := acquire(t, r, "a")
+			// пробных запросов не больше HalfOpenMaxCalls
+			requireOpen(t, r, "a")
+
+			first(tt.outcome)
+			if tt.outcome == Success {
+				// одного успешного пробного запроса из двух мало
+				requireState(t, r, "a", HalfOpen)
+				second(Success)
+			}
+			requireState(t, r, "a", tt.wantState)
+		})
+	}
+}
+
+func TestRegistryLateOutcome(t *testing.T) {
+	r, clock := newTestRegistry(t, Settings{FailureThreshold: 1, OpenTimeout: time.Minute}, NewMemoryStore())
+
+	late := acquire(t, r, "a")
+	acquire(t, r, "a")(Failure)
+	clock.advance(time.Minute)
+	probe := acquire(t, r, "a")
+
+	// запрос, начатый в замкнутом состоянии, не закрывает полуоткрытый брейкер вместо пробного
+	late(Success)
+	requireState(t, r, "a", HalfOpen)
+
+	probe(Success)
+	probe(Failure) // повторный вызов done игнорируется
+	requireState(t, r, "a", Closed)
+}
+
+func TestRegistryLostProbe(t *testing.T) {
+	r, clock := newTestRegistry(t, Settings{FailureThreshold: 1, OpenTimeout: time.Minute}, NewMemoryStore())
+
+	acquire(t, r, "a")(Failure)
+	clock.advance(time.Minute)
+	_ = acquire(t, r, "a") // инстанс остановился, не сообщив результат
+	requireOpen(t, r, "a")
+
+	clock.advance(time.Minute)
+	acquire(t, r, "a")(Success)
+	requireState(t, r, "a", Closed)
+}
+
+func TestRegistrySharedStore(t *testing.T) {
+	store := NewMemoryStore()
+	settings := Settings{FailureThreshold: 2, OpenTimeout: time.Minute}
+	first, _ := newTestRegistry(t, settings, store)
+	second, _ := newTestRegistry(t, settings, store)
+	api, _ := newTestRegistry(t, settings, store)
+
+	// ошибки разных воркеров складываются, а api видит то же состояние
+	acquire(t, first, "a")(Failure)
+	acquire(t, second, "a")(Failure)
+	requireOpen(t, first, "a")
+	requireOpen(t, second, "a")
+	requireState(t, api, "a", Open)
+}
+
+func TestRegistryDisabled(t *testing.T) {
+	r, _ := newTestRegistry(t, Settings{}, NewMemoryStore())
+
+	for i := 0; i < 10; i++ {
+		acquire(t, r, "a")(Failure)
+	}
+
+	statuses, err := r.Statuses(context.Background())
+	if err != nil || len(statuses) != 0 {
+		t.Fatalf("Statuses() = %v, %v, want no breakers", statuses, err)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Update(context.Context, string, func(*Record)) error {
+	return errors.New("connection refused")
+}
+
+func (failingStore) List(context.Context) ([]Record, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestRegistryStoreError(t *testing.T) {
+	r, _ := newTestRegistry(t, Settings{FailureThreshold: 1}, failingStore{})
+
+	// без состояния брейкера запрос не отправляется
+	if _, err := r.Acquire(context.Background(), "a"); err == nil || IsOpen(err) {
+		t.Fatalf("Acquire() error = %v, want store error", err)
+	}
+	if _, err := r.Statuses(context.Background()); err == nil {
+		t.Fatalf("Statuses() error = nil, want store error")
+	}
+}
@@ -0,0 +1,255 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrDenied адрес запрещен политикой исходящих запросов
+var ErrDenied = errors.New("egress denied")
+
+// blockedNetworks адреса внутри инфраструктуры: loopback, частные сети, link-local (в том числе
+// metadata облаков 169.254.169.254), CGNAT, multicast, документационные и зарезервированные диапазоны.
+// Teredo, 6to4, NAT64 (в том числе local-use 64:ff9b:1::/48) и IPv4-compatible ::/96 закрыты целиком:
+// в адрес зашит IPv4, который может указывать внутрь инфраструктуры
+var blockedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/96"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001::/32"),
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+type (
+	Settings struct {
+		AllowedSchemes []string // пусто - http и https
+		AllowedPorts   []int    // пусто - любой порт
+		AllowedHosts   []string // если задан, запросы только к этим хостам; *.example.com - поддомены
+		DeniedHosts    []string // запрещены всегда, даже если есть в AllowedHosts
+		AllowPrivate   bool     // разрешить внутренние адреса, только для локальной разработки
+		AllowedCIDRs   []string // внутренние сети, которые разрешены несмотря на AllowPrivate
+		MaxRedirects   int      // 0 - редиректы не выполняются
+	}
+
+	Policy struct {
+		settings     Settings
+		allowedCIDRs []netip.Prefix
+	}
+)
+
+func NewPolicy(settings Settings) (*Policy, error) {
+	p := &Policy{settings: settings}
+	if len(p.settings.AllowedSchemes) == 0 {
+		p.settings.AllowedSchemes = []string{"http", "https"}
+	}
+
+	for _, cidr := range settings.AllowedCIDRs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("egress allowed cidr %s: %w", cidr, err)
+		}
+		p.allowedCIDRs = append(p.allowedCIDRs, prefix.Masked())
+	}
+
+	return p, nil
+}
+
+// CheckURL проверяет схему, порт и хост адреса без обращения к DNS. Адрес, заданный IP, проверяется сразу
+func (p *Policy) CheckURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(p.settings.AllowedSchemes, scheme) {
+		return fmt.Errorf("scheme %q is not allowed: %w", u.Scheme, ErrDenied)
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+
+	port, err := urlPort(u)
+	if err != nil {
+		return err
+	}
+	if err := p.checkPort(port); err != nil {
+		return err
+	}
+
+	if matchHost(p.settings.DeniedHosts, host) {
+		return fmt.Errorf("host %s is denied: %w", host, ErrDenied)
+	}
+	if len(p.settings.AllowedHosts) != 0 && !matchHost(p.settings.AllowedHosts, host) {
+		return fmt.Errorf("host %s is not in allowed hosts: %w", host, ErrDenied)
+	}
+
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return p.CheckIP(ip)
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return p.CheckIP(netip.IPv6Loopback())
+	}
+
+	return nil
+}
+
+// CheckIP внутренние адреса запрещены, кроме разрешенных сетей
+func (p *Policy) CheckIP(ip netip.Addr) error {
+	ip = ip.Unmap()
+	if p.settings.AllowPrivate {
+		return nil
+	}
+
+	for _, prefix := range p.allowedCIDRs {
+		if prefix.Contains(ip) {
+			return nil
+		}
+	}
+
+	for _, prefix := range blockedNetworks {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("address %s is internal: %w", ip, ErrDenied)
+		}
+	}
+
+	return nil
+}
+
+// CheckHost проверяет все адреса, в которые сейчас резолвится хост адреса. При вызове адрес может
+// резолвиться иначе, поэтому адрес соединения еще раз проверяет Dialer
+func (p *Policy) CheckHost(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve host %s: %w", u.Hostname(), err)
+	}
+
+	for _, ip := range ips {
+		if err := p.CheckIP(ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Client http клиент, который соединяется только с разрешенными адресами: адрес проверяется после резолва,
+// прямо перед соединением, поэтому подмена DNS между проверкой и вызовом не помогает. Прокси из окружения
+// не используется, иначе проверялся бы адрес прокси, а не ноды
+func (p *Policy) Client() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   p.control,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport:     transport,
+		CheckRedirect: p.checkRedirect,
+	}
+}
+
+func (p *Policy) control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", address, err)
+	}
+
+	if err := p.checkPort(int(addrPort.Port())); err != nil {
+		return err
+	}
+
+	return p.CheckIP(addrPort.Addr())
+}
+
+func (p *Policy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.settings.MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects: %w", p.settings.MaxRedirects, ErrDenied)
+	}
+
+	return p.CheckURL(req.URL.String())
+}
+
+func (p *Policy) checkPort(port int) error {
+	if len(p.settings.AllowedPorts) != 0 && !slices.Contains(p.settings.AllowedPorts, port) {
+		return fmt.Errorf("port %d is not allowed: %w", port, ErrDenied)
+	}
+
+	return nil
+}
+
+func urlPort(u *url.URL) (int, error) {
+	if u.Port() == "" {
+		switch strings.ToLower(u.Scheme) {
+		case "https":
+			return 443, nil
+		default:
+			return 80, nil
+		}
+	}
+
+	port, err := strconv.Atoi(u.Port())
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %s", u.Port())
+	}
+
+	return port, nil
+}
+
+// matchHost точное совпадение или поддомен для шаблона *.example.com
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+
+		if host == pattern {
+			return true
+		}
+	}
+
+	return false
+}
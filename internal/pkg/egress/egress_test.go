@@ -0,0 +1,228 @@
+package egress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestCheckIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{ip: "8.8.8.8", allowed: true},
+		{ip: "1.1.1.1", allowed: true},
+		{ip: "2606:4700:4700::1111", allowed: true},
+		{ip: "0.0.0.0"},
+		{ip: "10.1.2.3"},
+		{ip: "100.64.0.1"},
+		{ip: "127.0.0.1"},
+		{ip: "169.254.169.254"},
+		{ip: "172.16.0.1"},
+		{ip: "172.31.255.255"},
+		{ip: "172.32.0.1", allowed: true},
+		{ip: "192.0.0.1"},
+		{ip: "192.0.2.10"},
+		{ip: "192.168.1.1"},
+		{ip: "198.18.0.1"},
+		{ip: "198.51.100.7"},
+		{ip: "203.0.113.200"},
+		{ip: "224.0.0.1"},
+		{ip: "255.255.255.255"},
+		{ip: "::"},
+		{ip: "::1"},
+		{ip: "::ffff:127.0.0.1"},
+		{ip: "::ffff:10.0.0.1"},
+		{ip: "64:ff9b::a00:1"},
+		{ip: "64:ff9b:1::a00:1"},
+		{ip: "::a00:1"},
+		{ip: "::7f00:1"},
+		{ip: "2001:0:4136:e378:8000:63bf:3fff:fdd2"},
+		{ip: "2002:a00:1::1"},
+		{ip: "fc00::1"},
+		{ip: "fd12:3456::1"},
+		{ip: "fe80::1"},
+		{ip: "ff02::1"},
+	}
+
+	policy, err := NewPolicy(Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := policy.CheckIP(netip.MustParseAddr(tt.ip))
+			if tt.allowed && err != nil {
+				t.Fatalf("CheckIP(%s) unexpected error: %v", tt.ip, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrDenied) {
+				t.Fatalf("CheckIP(%s) error = %v, want ErrDenied", tt.ip, err)
+			}
+		})
+	}
+}
+
+func TestCheckIPSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		ip       string
+		allowed  bool
+	}{
+		{name: "allow private", settings: Settings{AllowPrivate: true}, ip: "10.0.0.1", allowed: true},
+		{name: "allowed cidr", settings: Settings{AllowedCIDRs: []string{"10.20.0.0/16"}}, ip: "10.20.3.4", allowed: true},
+		{name: "outside allowed cidr", settings: Settings{AllowedCIDRs: []string{"10.20.0.0/16"}}, ip: "10.21.3.4"},
+		{name: "unmasked allowed cidr", settings: Settings{AllowedCIDRs: []string{"10.20.1.1/16"}}, ip: "10.20.3.4", allowed: true},
+		{name: "mapped address in allowed cidr", settings: Settings{AllowedCIDRs: []string{"10.20.0.0/16"}}, ip: "::ffff:10.20.3.4", allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = policy.CheckIP(netip.MustParseAddr(tt.ip))
+			if tt.allowed && err != nil {
+				t.Fatalf("CheckIP(%s) unexpected error: %v", tt.ip, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrDenied) {
+				t.Fatalf("CheckIP(%s) error = %v, want ErrDenied", tt.ip, err)
+			}
+		})
+	}
+}
+
+func TestNewPolicyBadCIDR(t *testing.T) {
+	if _, err := NewPolicy(Settings{AllowedCIDRs: []string{"10.0.0.0"}}); err == nil {
+		t.Fatal("NewPolicy() error = nil, want cidr error")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings Settings
+		url      string
+		wantErr  bool
+		denied   bool
+	}{
+		{name: "public host", url: "https://api.openai.com/v1/chat"},
+		{name: "public ip", url: "http://8.8.8.8/"},
+		{name: "scheme", url: "ftp://example.com/file", wantErr: true, denied: true},
+		{name: "custom scheme", settings: Settings{AllowedSchemes: []string{"https"}}, url: "http://example.com", wantErr: true, denied: true},
+		{name: "no host", url: "http:///path", wantErr: true},
+		{name: "bad port", url: "http://example.com:99999", wantErr: true},
+		{name: "port not allowed", settings: Settings{AllowedPorts: []int{443}}, url: "http://example.com", wantErr: true, denied: true},
+		{name: "default https port allowed", settings: Settings{AllowedPorts: []int{443}}, url: "https://example.com"},
+		{name: "loopback ip", url: "http://127.0.0.1:8080", wantErr: true, denied: true},
+		{name: "ipv6 loopback", url: "http://[::1]/", wantErr: true, denied: true},
+		{name: "metadata", url: "http://169.254.169.254/latest/meta-data", wantErr: true, denied: true},
+		{name: "6to4", url: "http://[2002:7f00:1::1]/", wantErr: true, denied: true},
+		{name: "documentation range", url: "http://203.0.113.5/", wantErr: true, denied: true},
+		{name: "localhost", url: "http://localhost:3000", wantErr: true, denied: true},
+		{name: "localhost subdomain", url: "http://api.localhost.", wantErr: true, denied: true},
+		{name: "denied host", settings: Settings{DeniedHosts: []string{"*.internal.example.com"}}, url: "https://db.internal.example.com", wantErr: true, denied: true},
+		{name: "denied wins over allowed", settings: Settings{AllowedHosts: []string{"*.example.com"}, DeniedHosts: []string{"admin.example.com"}}, url: "https://ADMIN.example.com", wantErr: true, denied: true},
+		{name: "allowed host", settings: Settings{AllowedHosts: []string{"*.example.com"}}, url: "https://api.example.com"},
+		{name: "wildcard does not match apex", settings: Settings{AllowedHosts: []string{"*.example.com"}}, url: "https://example.com", wantErr: true, denied: true},
+		{name: "host outside allowed", settings: Settings{AllowedHosts: []string{"api.example.com"}}, url: "https://evil.com", wantErr: true, denied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = policy.CheckURL(tt.url)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("CheckURL(%s) unexpected error: %v", tt.url, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("CheckURL(%s) error = nil, want error", tt.url)
+			}
+			if errors.Is(err, ErrDenied) != tt.denied {
+				t.Fatalf("CheckURL(%s) error = %v, denied = %v, want %v", tt.url, err, errors.Is(err, ErrDenied), tt.denied)
+			}
+		})
+	}
+}
+
+func TestClientRefusesInternalAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		settings Settings
+		denied   bool
+	}{
+		{name: "loopback is denied at dial", settings: Settings{}, denied: true},
+		{name: "allowed cidr", settings: Settings{AllowedCIDRs: []string{"127.0.0.0/8"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.settings)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := policy.Client().Get(srv.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if tt.denied != errors.Is(err, ErrDenied) {
+				t.Fatalf("Get() error = %v, want denied = %v", err, tt.denied)
+			}
+			if !tt.denied && err != nil {
+				t.Fatalf("Get() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestClientRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer redirect.Close()
+
+	tests := []struct {
+		name         string
+		maxRedirects int
+		denied       bool
+	}{
+		{name: "redirects disabled", maxRedirects: 0, denied: true},
+		{name: "redirect allowed", maxRedirects: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(Settings{AllowPrivate: true, MaxRedirects: tt.maxRedirects})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := policy.Client().Get(redirect.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if tt.denied != errors.Is(err, ErrDenied) || (!tt.denied && err != nil) {
+				t.Fatalf("Get() error = %v, want denied = %v", err, tt.denied)
+			}
+		})
+	}
+}
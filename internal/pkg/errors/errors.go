@@ -26,6 +26,8 @@ var (
 	ValidationFailed   = &Error{Code: 400, Reason: "validation failed"}
 	Timeout            = &Error{Code: 504, Reason: "timeout"}
 	InvalidVersion     = &Error{Code: 400, Reason: "invalid version"}
+	CircuitOpen        = &Error{Code: 503, Reason: "node circuit breaker is open"}
+	NotFound           = &Error{Code: 404, Reason: "not found"}
 
 	AuthFailed = &Error{Code: 401, Reason: "no authed data"}
 )
@@ -35,7 +35,7 @@ func getFunctionsCallTrace(skip int) []runtime.Frame {
 
 	var resultFrames []runtime.Frame
 	for frame, hasNext := frames.Next(); hasNext; frame, hasNext = frames.Next() {
-		if strings.HasPrefix(frame.Function, "github.com/custom-app/coffee") {
+		if strings.HasPrefix(frame.Function, "github.com/warehouse/ai-service") {
 			if strings.Contains(frame.File, "/http/handler.go") {
 				break
 			}
@@ -43,6 +43,11 @@ func getFunctionsCallTrace(skip int) []runtime.Frame {
 		}
 	}
 
+	// кадры самого логгера есть всегда, но вызывающего может не оказаться, если он вне модуля
+	if len(resultFrames) <= toSkip {
+		return resultFrames[len(resultFrames)-1:]
+	}
+
 	return resultFrames[toSkip:]
 }
 
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type (
+	NodeBreaker struct {
+		Key      string    `db:"key"`
+		State    string    `db:"state"`
+		Failures int       `db:"failures"`
+		OpenedAt time.Time `db:"opened_at"`
+		InFlight int       `db:"in_flight"` // пробные запросы полуоткрытого брейкера, которые еще не ответили
+		Passed   int       `db:"passed"`
+		ProbedAt time.Time `db:"probed_at"`
+	}
+)
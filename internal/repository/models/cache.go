@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/types"
+)
+
+type (
+	CachedResponse struct {
+		Key        string     `db:"key"` // хеш ноды, метода, адреса и тела запроса
+		NodeId     string     `db:"node_id"`
+		StatusCode int        `db:"status_code"`
+		Header     types.JSON `db:"headers"`
+		Body       []byte     `db:"body"`
+		Streamed   string     `db:"streamed"`
+		ExpiresAt  time.Time  `db:"expires_at"`
+		CreatedAt  time.Time  `db:"created_at"`
+	}
+)
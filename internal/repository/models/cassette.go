@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/types"
+)
+
+type (
+	CassetteEntry struct {
+		Owner       string     `db:"owner"` // автор запуска, который записал кассету
+		Cassette    string     `db:"cassette"`
+		NodeId      string     `db:"node_id"`
+		RequestHash string     `db:"request_hash"` // хеш нормализованного запроса к ноде
+		StatusCode  int        `db:"status_code"`
+		Header      types.JSON `db:"headers"`
+		Body        []byte     `db:"body"`
+		RecordedAt  time.Time  `db:"recorded_at"`
+	}
+)
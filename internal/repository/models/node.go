@@ -15,8 +15,15 @@ type (
 		Headers           types.JSON `db:"headers"`
 		Body              types.JSON `db:"body"`
 		ResponseDirection string     `db:"response_direction"` // какое поле будет передано следующей ноде как запрос или в финальный ответ, только для json'ов
+		ResponseExtractor types.JSON `db:"response_extractor"` // как достать результат из ответа, перекрывает response_direction
 		RequestMime       string     `db:"request_mime"`       // тип body, который принимает нода
 		ResponseMime      string     `db:"response_mime"`      // mime type ответа
 		ApiKey            string     `db:"api_key"`
+		TimeoutMs         int64      `db:"timeout_ms"`
+		RetryPolicy       types.JSON `db:"retry_policy"`
+		Stream            types.JSON `db:"stream"` // настройки потокового ответа, пусто - нода не стримит
+		RateLimit         types.JSON `db:"rate_limit"`
+		Cache             types.JSON `db:"cache"`
+		Usage             types.JSON `db:"usage"`
 	}
 )
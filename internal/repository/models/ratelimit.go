@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type (
+	RateLimitBucket struct {
+		Key       string    `db:"key"`
+		Tokens    float64   `db:"tokens"` // сколько запросов можно отправить сейчас, отрицательное - очередь ожидающих
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+)
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/types"
+
+	"github.com/rs/xid"
+)
+
+type (
+	ScriptRun struct {
+		Id         xid.ID     `db:"id"`
+		ScriptId   string     `db:"script_id"`
+		AuthorId   string     `db:"author"`
+		Status     string     `db:"status"`
+		EnterData  string     `db:"enter_data"`
+		Files      types.JSON `db:"files"`
+		Result     string     `db:"result"`
+		ResultMime string     `db:"result_mime"`
+		Error      string     `db:"error"`
+		Report     types.JSON `db:"report"`
+		Cassette   types.JSON `db:"cassette"`
+		// сумма по вызовам нод запуска
+		PromptTokens     int64     `db:"prompt_tokens"`
+		CompletionTokens int64     `db:"completion_tokens"`
+		Cost             float64   `db:"cost"`
+		CreatedAt        time.Time `db:"created_at"`
+		UpdatedAt        time.Time `db:"updated_at"`
+	}
+)
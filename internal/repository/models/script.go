@@ -12,9 +12,11 @@ type (
 	Script struct {
 		Id              xid.ID          `db:"id"`
 		Name            string          `db:"name"`
-		Workflow        json.RawMessage `db:"nodes"`
+		Workflow        json.RawMessage `db:"workflow"`
 		BodyPresets     types.JSON      `db:"body_presets"`
 		HeaderPresets   types.JSON      `db:"header_presets"`
+		Options         types.JSON      `db:"options"`
+		Graph           types.JSON      `db:"graph"`
 		AuthorId        string          `db:"author"`
 		WarehouseApiKey string          `db:"warehouse_api_key"`
 	}
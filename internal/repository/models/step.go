@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/types"
+)
+
+type (
+	RunStep struct {
+		Id               int64      `db:"id"`
+		RunId            string     `db:"run_id"`
+		Step             int        `db:"step"`
+		Chain            int        `db:"chain"`
+		Position         int        `db:"position"` // порядковый номер ноды внутри цепочки
+		NodeId           string     `db:"node_id"`
+		GraphNode        string     `db:"graph_node"` // имя узла графа сценария
+		Attempt          int        `db:"attempt"`
+		RequestBody      string     `db:"request_body"`
+		RequestHeaders   types.JSON `db:"request_headers"` // секреты замаскированы
+		Response         string     `db:"response"`
+		Output           string     `db:"output"` // значение, извлеченное по response_direction
+		StatusCode       int        `db:"status_code"`
+		LatencyMs        int64      `db:"latency_ms"`
+		Error            string     `db:"error"`
+		CacheHit         bool       `db:"cache_hit"`
+		PromptTokens     int64      `db:"prompt_tokens"`
+		CompletionTokens int64      `db:"completion_tokens"`
+		Cost             float64    `db:"cost"`
+		StartedAt        time.Time  `db:"started_at"`
+	}
+)
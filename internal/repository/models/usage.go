@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type (
+	UsageFilter struct {
+		From     time.Time
+		To       time.Time
+		GroupBy  string // run, script, author или node
+		AuthorId string // пусто - все авторы
+	}
+
+	UsageTotal struct {
+		Key              string  `db:"key"`
+		Calls            int64   `db:"calls"`
+		PromptTokens     int64   `db:"prompt_tokens"`
+		CompletionTokens int64   `db:"completion_tokens"`
+		Cost             float64 `db:"cost"`
+	}
+)
@@ -0,0 +1,32 @@
+package breakers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getBreakerByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.NodeBreaker, error) {
+	baseQuery := `
+    SELECT b.key, b.state, b.failures, b.opened_at, b.in_flight, b.passed, b.probed_at
+    FROM node_breakers as b
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.NodeBreaker
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
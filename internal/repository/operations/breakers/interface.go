@@ -0,0 +1,15 @@
+package breakers
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	// Lock блокирует брейкер до конца транзакции, новый брейкер создается замкнутым
+	Lock(ctx context.Context, tx transactions.Transaction, key string) (models.NodeBreaker, error)
+	Save(ctx context.Context, tx transactions.Transaction, breaker models.NodeBreaker) error
+	GetAll(ctx context.Context, tx transactions.Transaction) ([]models.NodeBreaker, error)
+}
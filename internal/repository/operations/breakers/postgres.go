@@ -0,0 +1,77 @@
+package breakers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_node_breakers"),
+	}
+}
+
+func (r *repositoryPG) Lock(ctx context.Context, tx transactions.Transaction, key string) (models.NodeBreaker, error) {
+	query := `
+    INSERT INTO node_breakers (key, state, failures, opened_at, in_flight, passed, probed_at)
+    VALUES($1, $2, 0, $3, 0, 0, $3)
+    ON CONFLICT (key) DO NOTHING
+  `
+	if _, err := tx.Txm().ExecContext(ctx, query, key, "closed", time.Time{}); err != nil {
+		return models.NodeBreaker{}, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	cond := `WHERE b.key = $1 FOR UPDATE`
+	list, err := r.getBreakerByCondition(ctx, tx.Txm(), cond, key)
+	if err != nil {
+		return models.NodeBreaker{}, err
+	}
+
+	if len(list) == 0 {
+		return models.NodeBreaker{}, fmt.Errorf("node breaker %s not found", key)
+	}
+
+	return list[0], nil
+}
+
+func (r *repositoryPG) Save(ctx context.Context, tx transactions.Transaction, breaker models.NodeBreaker) error {
+	query := `
+    UPDATE node_breakers
+    SET state = :state, failures = :failures, opened_at = :opened_at,
+        in_flight = :in_flight, passed = :passed, probed_at = :probed_at
+    WHERE key = :key
+  `
+
+	res, err := tx.Txm().NamedExecContext(ctx, query, breaker)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected != 1 {
+		return r.log.ErrorRepo(repository_errors.PostgresqlNoRowsWereAffected, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) GetAll(ctx context.Context, tx transactions.Transaction) ([]models.NodeBreaker, error) {
+	return r.getBreakerByCondition(ctx, tx.Txm(), `ORDER BY b.key`)
+}
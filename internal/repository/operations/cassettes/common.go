@@ -0,0 +1,32 @@
+package cassettes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getEntryByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.CassetteEntry, error) {
+	baseQuery := `
+    SELECT c.owner, c.cassette, c.node_id, c.request_hash, c.status_code, c.headers, c.body, c.recorded_at
+    FROM cassette_entries as c
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.CassetteEntry
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
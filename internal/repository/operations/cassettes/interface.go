@@ -0,0 +1,17 @@
+package cassettes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// ErrNotFound в кассете нет ответа на такой запрос
+var ErrNotFound = errors.New("cassette entry not found")
+
+type Repository interface {
+	Get(ctx context.Context, tx transactions.Transaction, owner, cassette, nodeId, requestHash string) (models.CassetteEntry, error)
+	Save(ctx context.Context, tx transactions.Transaction, entry models.CassetteEntry) error
+}
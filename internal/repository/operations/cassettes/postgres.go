@@ -0,0 +1,53 @@
+package cassettes
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_cassettes"),
+	}
+}
+
+func (r *repositoryPG) Get(ctx context.Context, tx transactions.Transaction, owner, cassette, nodeId, requestHash string) (models.CassetteEntry, error) {
+	cond := `WHERE c.owner = $1 AND c.cassette = $2 AND c.node_id = $3 AND c.request_hash = $4`
+	list, err := r.getEntryByCondition(ctx, tx.Txm(), cond, owner, cassette, nodeId, requestHash)
+	if err != nil {
+		return models.CassetteEntry{}, err
+	}
+
+	if len(list) == 0 {
+		return models.CassetteEntry{}, ErrNotFound
+	}
+
+	return list[0], nil
+}
+
+// Save перезаписывает ответ, если запрос уже был записан в кассету
+func (r *repositoryPG) Save(ctx context.Context, tx transactions.Transaction, entry models.CassetteEntry) error {
+	query := `
+    INSERT INTO cassette_entries (owner, cassette, node_id, request_hash, status_code, headers, body, recorded_at)
+    VALUES(:owner, :cassette, :node_id, :request_hash, :status_code, :headers, :body, :recorded_at)
+    ON CONFLICT (owner, cassette, node_id, request_hash)
+    DO UPDATE SET status_code = EXCLUDED.status_code, headers = EXCLUDED.headers, body = EXCLUDED.body, recorded_at = EXCLUDED.recorded_at
+  `
+
+	if _, err := tx.Txm().NamedExecContext(ctx, query, entry); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
@@ -17,7 +17,8 @@ func (r *repositoryPG) getNodeByCondition(
 	params ...interface{},
 ) ([]models.Node, error) {
 	baseQuery := `
-    SELECT n.id, n.name, n.url, n.method, n.headers, n.body, n.api_key
+    SELECT n.id, n.name, n.url, n.method, n.headers, n.body, n.request_mime, n.response_mime,
+      n.response_direction, n.response_extractor, n.api_key, n.timeout_ms, n.retry_policy, n.stream, n.rate_limit, n.cache, n.usage
     FROM nodes as n
   `
 	query := fmt.Sprintf("%s %s", baseQuery, condition)
@@ -25,8 +25,10 @@ func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
 
 func (r *repositoryPG) Create(ctx context.Context, tx transactions.Transaction, node models.Node) (models.Node, error) {
 	query := `
-    INSERT INTO nodes (name, url, api_key, method, headers)
-    VALUES(:name, :url, :api_key, :method, :headers)
+    INSERT INTO nodes (name, url, api_key, method, headers, body, request_mime, response_mime,
+      response_direction, response_extractor, timeout_ms, retry_policy, stream, rate_limit, cache, usage)
+    VALUES(:name, :url, :api_key, :method, :headers, :body, :request_mime, :response_mime,
+      :response_direction, :response_extractor, :timeout_ms, :retry_policy, :stream, :rate_limit, :cache, :usage)
   `
 
 	res, err := tx.Txm().NamedExecContext(ctx, query, node)
@@ -0,0 +1,32 @@
+package ratelimits
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getBucketByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.RateLimitBucket, error) {
+	baseQuery := `
+    SELECT b.key, b.tokens, b.updated_at
+    FROM rate_limit_buckets as b
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.RateLimitBucket
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
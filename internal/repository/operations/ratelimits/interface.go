@@ -0,0 +1,15 @@
+package ratelimits
+
+import (
+	"context"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	// Lock блокирует бакет до конца транзакции, новый бакет создается полным
+	Lock(ctx context.Context, tx transactions.Transaction, key string, capacity float64, now time.Time) (models.RateLimitBucket, error)
+	Save(ctx context.Context, tx transactions.Transaction, bucket models.RateLimitBucket) error
+}
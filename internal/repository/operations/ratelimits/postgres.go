@@ -0,0 +1,72 @@
+package ratelimits
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_rate_limits"),
+	}
+}
+
+func (r *repositoryPG) Lock(ctx context.Context, tx transactions.Transaction, key string, capacity float64, now time.Time) (models.RateLimitBucket, error) {
+	query := `
+    INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+    VALUES($1, $2, $3)
+    ON CONFLICT (key) DO NOTHING
+  `
+	if _, err := tx.Txm().ExecContext(ctx, query, key, capacity, now); err != nil {
+		return models.RateLimitBucket{}, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	cond := `WHERE b.key = $1 FOR UPDATE`
+	list, err := r.getBucketByCondition(ctx, tx.Txm(), cond, key)
+	if err != nil {
+		return models.RateLimitBucket{}, err
+	}
+
+	if len(list) == 0 {
+		return models.RateLimitBucket{}, fmt.Errorf("rate limit bucket %s not found", key)
+	}
+
+	return list[0], nil
+}
+
+func (r *repositoryPG) Save(ctx context.Context, tx transactions.Transaction, bucket models.RateLimitBucket) error {
+	query := `
+    UPDATE rate_limit_buckets
+    SET tokens = :tokens, updated_at = :updated_at
+    WHERE key = :key
+  `
+
+	res, err := tx.Txm().NamedExecContext(ctx, query, bucket)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected != 1 {
+		return r.log.ErrorRepo(repository_errors.PostgresqlNoRowsWereAffected, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return nil
+}
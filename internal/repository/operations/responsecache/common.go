@@ -0,0 +1,32 @@
+package responsecache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getResponseByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.CachedResponse, error) {
+	baseQuery := `
+    SELECT c.key, c.node_id, c.status_code, c.headers, c.body, c.streamed, c.expires_at, c.created_at
+    FROM node_response_cache as c
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.CachedResponse
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,18 @@
+package responsecache
+
+import (
+	"context"
+	"errors"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// ErrNotFound в кеше нет актуального ответа на такой запрос
+var ErrNotFound = errors.New("cached response not found")
+
+type Repository interface {
+	Get(ctx context.Context, tx transactions.Transaction, key string) (models.CachedResponse, error)
+	Save(ctx context.Context, tx transactions.Transaction, entry models.CachedResponse) error
+	Prune(ctx context.Context, tx transactions.Transaction, maxEntries int) error
+}
@@ -0,0 +1,76 @@
+package responsecache
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_response_cache"),
+	}
+}
+
+func (r *repositoryPG) Get(ctx context.Context, tx transactions.Transaction, key string) (models.CachedResponse, error) {
+	cond := `WHERE c.key = $1 AND c.expires_at > now()`
+	list, err := r.getResponseByCondition(ctx, tx.Txm(), cond, key)
+	if err != nil {
+		return models.CachedResponse{}, err
+	}
+
+	if len(list) == 0 {
+		return models.CachedResponse{}, ErrNotFound
+	}
+
+	return list[0], nil
+}
+
+// Save перезаписывает ответ, если запрос уже есть в кеше
+func (r *repositoryPG) Save(ctx context.Context, tx transactions.Transaction, entry models.CachedResponse) error {
+	query := `
+    INSERT INTO node_response_cache (key, node_id, status_code, headers, body, streamed, expires_at, created_at)
+    VALUES(:key, :node_id, :status_code, :headers, :body, :streamed, :expires_at, :created_at)
+    ON CONFLICT (key)
+    DO UPDATE SET status_code = EXCLUDED.status_code, headers = EXCLUDED.headers, body = EXCLUDED.body,
+      streamed = EXCLUDED.streamed, expires_at = EXCLUDED.expires_at, created_at = EXCLUDED.created_at
+  `
+
+	if _, err := tx.Txm().NamedExecContext(ctx, query, entry); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// Prune удаляет просроченные ответы и самые старые сверх maxEntries
+func (r *repositoryPG) Prune(ctx context.Context, tx transactions.Transaction, maxEntries int) error {
+	query := `DELETE FROM node_response_cache WHERE expires_at <= now()`
+	if _, err := tx.Txm().ExecContext(ctx, query); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	query = `
+    DELETE FROM node_response_cache
+    WHERE key IN (
+      SELECT key FROM node_response_cache
+      ORDER BY created_at DESC
+      OFFSET $1
+    )
+  `
+	if _, err := tx.Txm().ExecContext(ctx, query, maxEntries); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getRunByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.ScriptRun, error) {
+	baseQuery := `
+    SELECT r.id, r.script_id, r.author, r.status, r.enter_data, r.files, r.result, r.result_mime, r.error, r.report, r.cassette, r.prompt_tokens, r.completion_tokens, r.cost, r.created_at, r.updated_at
+    FROM script_runs as r
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.ScriptRun
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
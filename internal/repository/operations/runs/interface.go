@@ -0,0 +1,16 @@
+package runs
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ScriptRun, error)
+	GetByAuthor(ctx context.Context, tx transactions.Transaction, authorId string, limit, offset int) ([]models.ScriptRun, error)
+	Create(ctx context.Context, tx transactions.Transaction, run models.ScriptRun) (models.ScriptRun, error)
+	UpdateStatus(ctx context.Context, tx transactions.Transaction, run models.ScriptRun) error
+	UpdateStatusFrom(ctx context.Context, tx transactions.Transaction, run models.ScriptRun, from []string) (bool, error)
+}
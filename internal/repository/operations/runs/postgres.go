@@ -0,0 +1,113 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_runs"),
+	}
+}
+
+func (r *repositoryPG) GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ScriptRun, error) {
+	cond := `WHERE r.id = $1`
+	list, err := r.getRunByCondition(ctx, tx.Txm(), cond, id)
+	if err != nil {
+		return models.ScriptRun{}, err
+	}
+
+	if len(list) != 0 {
+		return list[0], nil
+	} else {
+		return models.ScriptRun{}, fmt.Errorf("run with provided id not found")
+	}
+}
+
+func (r *repositoryPG) GetByAuthor(ctx context.Context, tx transactions.Transaction, authorId string, limit, offset int) ([]models.ScriptRun, error) {
+	cond := `WHERE r.author = $1 ORDER BY r.created_at DESC LIMIT $2 OFFSET $3`
+	return r.getRunByCondition(ctx, tx.Txm(), cond, authorId, limit, offset)
+}
+
+func (r *repositoryPG) Create(ctx context.Context, tx transactions.Transaction, run models.ScriptRun) (models.ScriptRun, error) {
+	query := `
+    INSERT INTO script_runs (id, script_id, author, status, enter_data, files, cassette, created_at, updated_at)
+    VALUES(:id, :script_id, :author, :status, :enter_data, :files, :cassette, :created_at, :updated_at)
+  `
+
+	res, err := tx.Txm().NamedExecContext(ctx, query, run)
+	if err != nil {
+		return models.ScriptRun{}, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return models.ScriptRun{}, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected != 1 {
+		return models.ScriptRun{}, r.log.ErrorRepo(repository_errors.PostgresqlNoRowsWereAffected, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return run, nil
+}
+
+func (r *repositoryPG) UpdateStatus(ctx context.Context, tx transactions.Transaction, run models.ScriptRun) error {
+	query := `
+    UPDATE script_runs
+    SET status = :status, result = :result, result_mime = :result_mime, error = :error, report = :report,
+      prompt_tokens = :prompt_tokens, completion_tokens = :completion_tokens, cost = :cost, updated_at = :updated_at
+    WHERE id = :id
+  `
+
+	res, err := tx.Txm().NamedExecContext(ctx, query, run)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected != 1 {
+		return r.log.ErrorRepo(repository_errors.PostgresqlNoRowsWereAffected, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return nil
+}
+
+// UpdateStatusFrom меняет статус и ошибку запуска, только если сейчас он в одном из статусов from.
+// false - запуск уже в другом статусе, например его взял другой воркер
+func (r *repositoryPG) UpdateStatusFrom(ctx context.Context, tx transactions.Transaction, run models.ScriptRun, from []string) (bool, error) {
+	query := `
+    UPDATE script_runs
+    SET status = $2, error = $3, updated_at = $4
+    WHERE id = $1 AND status = ANY($5)
+  `
+
+	res, err := tx.Txm().ExecContext(ctx, query, run.Id, run.Status, run.Error, run.UpdatedAt, from)
+	if err != nil {
+		return false, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected == 1, nil
+}
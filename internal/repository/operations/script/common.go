@@ -18,7 +18,8 @@ func (r *repositoryPG) getScriptByCondition(
 	params ...interface{},
 ) ([]models.Script, error) {
 	baseQuery := `
-    SELECT n.id, n.name, n.workflow, n.author, n.warehouse_api_key
+    SELECT s.id, s.name, s.workflow, s.body_presets, s.header_presets, s.options, s.graph, s.author, s.warehouse_api_key
+    FROM script as s
   `
 	query := fmt.Sprintf("%s %s", baseQuery, condition)
 
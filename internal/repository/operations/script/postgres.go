@@ -39,8 +39,8 @@ func (r *repositoryPG) GetById(ctx context.Context, tx transactions.Transaction,
 
 func (r *repositoryPG) Create(ctx context.Context, tx transactions.Transaction, script models.Script) (models.Script, error) {
 	query := `
-    INSERT INTO script (name, workflow, body_presets, header_presets, author, warehouse_api_key)
-    VALUES(:name, :workflow, :body_presets, :header_presets, :author, :warehouse_api_key)
+    INSERT INTO script (id, name, workflow, body_presets, header_presets, options, graph, author, warehouse_api_key)
+    VALUES(:id, :name, :workflow, :body_presets, :header_presets, :options, :graph, :author, :warehouse_api_key)
   `
 
 	res, err := tx.Txm().NamedExecContext(ctx, query, script)
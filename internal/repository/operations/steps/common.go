@@ -0,0 +1,34 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func (r *repositoryPG) getStepByCondition(
+	ctx context.Context,
+	executor sqlx.ExtContext,
+	condition string,
+	params ...interface{},
+) ([]models.RunStep, error) {
+	baseQuery := `
+    SELECT st.id, st.run_id, st.step, st.chain, st.position, st.node_id, st.graph_node, st.attempt, st.request_body, st.request_headers,
+      st.response, st.output, st.status_code, st.latency_ms, st.error, st.cache_hit,
+      st.prompt_tokens, st.completion_tokens, st.cost, st.started_at
+    FROM run_steps as st
+  `
+	query := fmt.Sprintf("%s %s", baseQuery, condition)
+
+	var list []models.RunStep
+	err := sqlx.SelectContext(ctx, executor, &list, query, params...)
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
@@ -0,0 +1,14 @@
+package steps
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	GetByRunId(ctx context.Context, tx transactions.Transaction, runId string) ([]models.RunStep, error)
+	CreateBatch(ctx context.Context, tx transactions.Transaction, steps []models.RunStep) error
+	UsageTotals(ctx context.Context, tx transactions.Transaction, filter models.UsageFilter) ([]models.UsageTotal, error)
+}
@@ -0,0 +1,92 @@
+package steps
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/db"
+	"github.com/warehouse/ai-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_run_steps"),
+	}
+}
+
+func (r *repositoryPG) GetByRunId(ctx context.Context, tx transactions.Transaction, runId string) ([]models.RunStep, error) {
+	cond := `WHERE st.run_id = $1 ORDER BY st.step, st.chain, st.position, st.attempt, st.id`
+	return r.getStepByCondition(ctx, tx.Txm(), cond, runId)
+}
+
+func (r *repositoryPG) CreateBatch(ctx context.Context, tx transactions.Transaction, steps []models.RunStep) error {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	query := `
+    INSERT INTO run_steps (run_id, step, chain, position, node_id, graph_node, attempt, request_body, request_headers,
+      response, output, status_code, latency_ms, error, cache_hit, prompt_tokens, completion_tokens, cost, started_at)
+    VALUES(:run_id, :step, :chain, :position, :node_id, :graph_node, :attempt, :request_body, :request_headers,
+      :response, :output, :status_code, :latency_ms, :error, :cache_hit, :prompt_tokens, :completion_tokens, :cost, :started_at)
+  `
+
+	res, err := tx.Txm().NamedExecContext(ctx, query, steps)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected != int64(len(steps)) {
+		return r.log.ErrorRepo(repository_errors.PostgresqlNoRowsWereAffected, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return nil
+}
+
+// usageGroupColumns колонка, по которой суммируется расход, для каждой группировки
+var usageGroupColumns = map[string]string{
+	"run":    "st.run_id",
+	"script": "r.script_id",
+	"author": "s.author",
+	"node":   "st.node_id",
+}
+
+// UsageTotals суммирует расход вызовов нод за период, самые дорогие группы первыми
+func (r *repositoryPG) UsageTotals(ctx context.Context, tx transactions.Transaction, filter models.UsageFilter) ([]models.UsageTotal, error) {
+	column, ok := usageGroupColumns[filter.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unknown usage group %s", filter.GroupBy)
+	}
+
+	query := fmt.Sprintf(`
+    SELECT %s as key, COUNT(*) as calls, COALESCE(SUM(st.prompt_tokens), 0)::BIGINT as prompt_tokens,
+      COALESCE(SUM(st.completion_tokens), 0)::BIGINT as completion_tokens, COALESCE(SUM(st.cost), 0) as cost
+    FROM run_steps as st
+    JOIN script_runs as r ON r.id::TEXT = st.run_id
+    JOIN script as s ON s.id::TEXT = r.script_id
+    WHERE st.started_at >= $1 AND st.started_at < $2 AND ($3::TEXT = '' OR s.author = $3::TEXT)
+    GROUP BY %s
+    ORDER BY cost DESC, key
+  `, column, column)
+
+	var list []models.UsageTotal
+	if err := tx.Txm().SelectContext(ctx, &list, query, filter.From, filter.To, filter.AuthorId); err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return list, nil
+}
@@ -1,8 +1,10 @@
 package node
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/warehouse/ai-service/internal/domain"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
@@ -57,50 +59,144 @@ func (s *service) validateBody(body map[string]interface{}) (map[string]domain.B
 		bodyFields[key] = field
 	}
 
+	// каждый тип поля проверяется только своими правилами
 	for key, value := range bodyFields {
-		if value.Type == domain.ObjectFieldType && len(value.Values) == 1 {
-			switch v := value.Values[0].(type) {
-			case map[string]interface{}:
-				if _, e := s.validateBody(v); e != nil {
-					return nil, e
-				}
-			default:
-				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: value is not JSON-object", key))
+		switch value.Type {
+		case domain.ObjectFieldType:
+			if len(value.Values) != 1 {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: only one JSON-object should be provided in values", key))
 			}
-		} else {
-			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: only one JSON-object should be provided in values", key))
-		}
 
-		if value.Type == domain.ConstFieldType && len(value.Values) == 1 {
+			v, ok := value.Values[0].(map[string]interface{})
+			if !ok {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: value is not JSON-object", key))
+			}
+			if _, e := s.validateBody(v); e != nil {
+				return nil, e
+			}
+		case domain.ConstFieldType:
+			if len(value.Values) != 1 {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"consts\" type there is should be one element in values array", key))
+			}
 			if _, ok := value.Values[0].(string); !ok {
 				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: consts values should be string type", key))
 			}
-		} else {
-			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"consts\" type there is should be one element in values array", key))
-		}
-
-		if value.Type == domain.SelectFieldType && len(value.Values) >= 2 {
+		case domain.SelectFieldType:
+			if len(value.Values) < 2 {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: values length should be greater than 1, with type \"select\" or use \"const\" type", key))
+			}
 			for _, val := range value.Values {
 				if _, ok := val.(string); !ok {
 					return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: values in select type should be strings", key))
 				}
 			}
-		} else {
-			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: values length should be greater than 1, with type \"select\" or use \"const\" type", key))
-		}
-
-		if value.Type == domain.PromptFieldType && len(value.Values) == 1 {
+		case domain.PromptFieldType:
+			if len(value.Values) != 1 {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"propmt\" type there is should be one your custom value in values array", key))
+			}
 			if _, ok := value.Values[0].(string); !ok {
 				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: prompt values should be string type", key))
 			}
-		} else {
-			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"propmt\" type there is should be one your custom value in values array", key))
+		case domain.DataFieldType, domain.FileFieldType:
+			if len(value.Values) != 0 {
+				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"%s\" type there is no values in array", key, value.Type))
+			}
+		default:
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: unknown type %s", key, value.Type))
 		}
+	}
 
-		if value.Type == domain.DataFieldType && len(value.Values) != 0 {
-			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: with \"data\" type there is no values in array", key))
+	return bodyFields, nil
+}
+
+// validateRequestMime тело запроса кодируется по request_mime, файлы можно передать только в json (base64) и multipart
+func (s *service) validateRequestMime(node domain.Node) *errors.Error {
+	encoding, err := node.RequestEncoding()
+	if err != nil {
+		return errors.WD(errors.ValidationFailed, err)
+	}
+
+	if encoding != domain.FormContentType {
+		return nil
+	}
+
+	for key, field := range node.Body {
+		if field.Type == domain.FileFieldType {
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("field %s: files can't be sent as %s", key, domain.FormContentType))
 		}
 	}
 
-	return bodyFields, nil
+	return nil
+}
+
+const maxRetryAttempts = 10
+
+func (s *service) validateRetryPolicy(policy *domain.RetryPolicy) (domain.RetryPolicy, *errors.Error) {
+	if policy == nil {
+		return domain.RetryPolicy{}, nil
+	}
+
+	if policy.MaxAttempts < 0 || policy.MaxAttempts > maxRetryAttempts {
+		return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: max_attempts should be between 0 and %d, 0 means a single attempt", maxRetryAttempts))
+	}
+
+	if policy.BackoffBaseMs < 0 || policy.BackoffCapMs < 0 {
+		return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: backoff values can't be negative"))
+	}
+
+	if policy.BackoffCapMs != 0 && policy.BackoffCapMs < policy.BackoffBaseMs {
+		return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: backoff_cap_ms should be greater than backoff_base_ms"))
+	}
+
+	if policy.Jitter < 0 || policy.Jitter > 1 {
+		return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: jitter should be between 0 and 1"))
+	}
+
+	for _, status := range policy.RetryStatuses {
+		if status < 100 || status > 599 {
+			return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: %d is not http status code", status))
+		}
+	}
+
+	for _, kind := range policy.RetryErrors {
+		if !slices.Contains(domain.RetryErrorKinds, kind) {
+			return domain.RetryPolicy{}, errors.WD(errors.ValidationFailed, fmt.Errorf("retry policy: unknown network error kind %s", kind))
+		}
+	}
+
+	return *policy, nil
+}
+
+// validateStream результат стримящей ноды - текст из дельт, поэтому бинарный ответ и экстрактор с ней не используются
+func (s *service) validateStream(node domain.Node) *errors.Error {
+	if err := node.Stream.Validate(); err != nil {
+		return errors.WD(errors.ValidationFailed, err)
+	}
+
+	if !node.Stream.Enabled() {
+		return nil
+	}
+
+	if domain.IsBinaryMime(node.ResponseMime) {
+		return errors.WD(errors.ValidationFailed, fmt.Errorf("stream: binary response_mime %s can't be streamed", node.ResponseMime))
+	}
+	if node.ResponseExtractor.Kind != "" {
+		return errors.WD(errors.ValidationFailed, fmt.Errorf("stream: response_extractor can't be used with stream, use stream.delta_path"))
+	}
+
+	return nil
+}
+
+// validateUrl адрес ноды должен проходить политику egress, а хост - резолвиться только во внешние адреса.
+// При вызове ноды адрес соединения проверяется еще раз, поэтому смена DNS записи после создания не поможет
+func (s *service) validateUrl(ctx context.Context, url string) *errors.Error {
+	if err := s.egress.CheckURL(url); err != nil {
+		return errors.WD(errors.ValidationFailed, fmt.Errorf("url: %w", err))
+	}
+
+	if err := s.egress.CheckHost(ctx, url); err != nil {
+		return errors.WD(errors.ValidationFailed, fmt.Errorf("url: %w", err))
+	}
+
+	return nil
 }
@@ -2,10 +2,13 @@ package node
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/warehouse/ai-service/internal/config"
 	"github.com/warehouse/ai-service/internal/domain"
 	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
 	"github.com/warehouse/ai-service/internal/pkg/logger"
 	nodesRepo "github.com/warehouse/ai-service/internal/repository/operations/nodes"
@@ -15,6 +18,7 @@ import (
 type (
 	Service interface {
 		Add(ctx context.Context, request models.AddNodeRequest) (domain.Node, *errors.Error)
+		Breakers(ctx context.Context) ([]domain.NodeBreaker, *errors.Error)
 	}
 
 	service struct {
@@ -23,6 +27,9 @@ type (
 
 		txRepo    transactions.Repository
 		nodesRepo nodesRepo.Repository
+
+		breakers breaker.Registry
+		egress   *egress.Policy
 	}
 )
 
@@ -31,12 +38,16 @@ func NewService(
 	log logger.Logger,
 	txRepo transactions.Repository,
 	nodesRepo nodesRepo.Repository,
+	breakers breaker.Registry,
+	egressPolicy *egress.Policy,
 ) Service {
 	return &service{
 		cfg:       cfg,
 		log:       log,
 		txRepo:    txRepo,
 		nodesRepo: nodesRepo,
+		breakers:  breakers,
+		egress:    egressPolicy,
 	}
 }
 
@@ -57,16 +68,60 @@ func (s *service) Add(ctx context.Context, request models.AddNodeRequest) (domai
 		return domain.Node{}, e
 	}
 
+	retryPolicy, e := s.validateRetryPolicy(request.RetryPolicy)
+	if e != nil {
+		return domain.Node{}, e
+	}
+
+	if err := request.ResponseExtractor.Validate(); err != nil {
+		return domain.Node{}, errors.WD(errors.ValidationFailed, err)
+	}
+
+	if err := request.RateLimit.Validate(); err != nil {
+		return domain.Node{}, errors.WD(errors.ValidationFailed, err)
+	}
+
+	if err := request.Cache.Validate(); err != nil {
+		return domain.Node{}, errors.WD(errors.ValidationFailed, err)
+	}
+
+	if err := request.Usage.Validate(); err != nil {
+		return domain.Node{}, errors.WD(errors.ValidationFailed, err)
+	}
+
+	if request.TimeoutMs < 0 {
+		return domain.Node{}, errors.WD(errors.ValidationFailed, fmt.Errorf("timeout_ms can't be negative"))
+	}
+
 	node := domain.Node{
 		Name:              request.Name,
 		Url:               request.Url,
 		Method:            domain.HttpMethod(request.Method),
 		ResponseDirection: request.ResponseDirection,
+		ResponseExtractor: request.ResponseExtractor,
 		ApiKey:            request.ApiKey,
 		RequestMime:       request.RequestMime,
 		ResponseMime:      request.ResponseMime,
 		Headers:           headers,
 		Body:              fields,
+		TimeoutMs:         request.TimeoutMs,
+		RetryPolicy:       retryPolicy,
+		Stream:            request.Stream,
+		RateLimit:         request.RateLimit,
+		Cache:             request.Cache,
+		Usage:             request.Usage,
+	}
+
+	if e := s.validateUrl(ctx, node.Url); e != nil {
+		return domain.Node{}, e
+	}
+
+	if e := s.validateRequestMime(node); e != nil {
+		return domain.Node{}, e
+	}
+
+	if e := s.validateStream(node); e != nil {
+		return domain.Node{}, e
 	}
 
 	modelNode, err := node.ToModel()
@@ -86,3 +141,48 @@ func (s *service) Add(ctx context.Context, request models.AddNodeRequest) (domai
 
 	return node, nil
 }
+
+// Breakers состояния брейкеров нод, к которым были запросы
+func (s *service) Breakers(ctx context.Context) ([]domain.NodeBreaker, *errors.Error) {
+	statuses, err := s.breakers.Statuses(ctx)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+	if len(statuses) == 0 {
+		return []domain.NodeBreaker{}, nil
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, len(statuses))
+	for i, status := range statuses {
+		ids[i] = status.Key
+	}
+
+	nodes, err := s.nodesRepo.GetByIds(ctx, tx, ids)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+
+	names := make(map[string]string, len(nodes))
+	for _, node := range nodes {
+		names[node.Id.String()] = node.Name
+	}
+
+	breakers := make([]domain.NodeBreaker, len(statuses))
+	for i, status := range statuses {
+		breakers[i] = domain.NodeBreaker{
+			NodeId:   status.Key,
+			NodeName: names[status.Key],
+			State:    string(status.State),
+			Failures: status.Failures,
+			OpenedAt: status.OpenedAt,
+		}
+	}
+
+	return breakers, nil
+}
@@ -0,0 +1,131 @@
+package script
+
+import (
+	"context"
+	"encoding/base64"
+	stdErrors "errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	blobAdpt "github.com/warehouse/ai-service/internal/adapter/blob"
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+)
+
+// nodeOutput результат ноды и его mime. Результат стримящей ноды - склейка дельт, текстовый результат достается
+// из ответа экстрактором ноды, а бинарный (по response_mime ноды) сохраняется в хранилище целиком или из base64
+// значения текстового ответа и передается дальше ссылкой
+func (s *service) nodeOutput(ctx context.Context, node domain.Node, res nodeResponse) (string, string, error) {
+	if node.Stream.Enabled() {
+		if res.Streamed == "" {
+			return "", "", fmt.Errorf("stream: no deltas in response")
+		}
+		return res.Streamed, domain.TextContentType, nil
+	}
+
+	extractor := node.Extractor()
+	contentType := res.Header.Get(domain.HeaderContentType)
+	if !domain.IsBinaryMime(node.ResponseMime) {
+		return extractor.Extract(res.Body, contentType)
+	}
+
+	data := res.Body
+	if extractor.Kind != domain.BodyExtractor && !domain.IsBinaryMime(contentType) {
+		encoded, _, err := extractor.Extract(res.Body, contentType)
+		if err != nil {
+			return "", "", err
+		}
+
+		// значение может прийти как data url: data:image/png;base64,...
+		if _, payload, ok := strings.Cut(encoded, ";base64,"); ok && strings.HasPrefix(encoded, "data:") {
+			encoded = payload
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", "", fmt.Errorf("%s %s: %s", extractor.Kind, extractor.Expr, err.Error())
+		}
+		data = decoded
+	}
+
+	key, err := s.blobAdapter.Put(ctx, data, node.ResponseMime)
+	if err != nil {
+		return "", "", fmt.Errorf("store binary response: %w", err)
+	}
+
+	return domain.BlobRef(key), node.ResponseMime, nil
+}
+
+// resultURL бинарный результат сценария отдается ссылкой на скачивание
+func (s *service) resultURL(ctx context.Context, output string) (string, error) {
+	key, ok := domain.BlobKey(output)
+	if !ok {
+		return output, nil
+	}
+
+	url, err := s.blobAdapter.DownloadURL(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("result download url: %w", err)
+	}
+
+	return url, nil
+}
+
+// historyResponse бинарный ответ не сохраняется в историю, вместо него пишется его размер
+func historyResponse(res nodeResponse) string {
+	if utf8.Valid(res.Body) {
+		return string(res.Body)
+	}
+
+	return fmt.Sprintf("<binary %s, %d bytes>", res.Header.Get(domain.HeaderContentType), len(res.Body))
+}
+
+// storeRunFiles сохраняет загруженные в запуск файлы в хранилище, ноды получают их по ссылкам
+func (s *service) storeRunFiles(ctx context.Context, files map[string]models.RunFileRequest) (map[string]string, *errors.Error) {
+	refs := make(map[string]string, len(files))
+	for name, file := range files {
+		if name == "" {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("file name is empty"))
+		}
+
+		data, err := base64.StdEncoding.DecodeString(file.Data)
+		if err != nil {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("file %s: %s", name, err.Error()))
+		}
+
+		mimeType := file.Mime
+		if mimeType == "" {
+			mimeType = domain.OctetStreamContentType
+		}
+
+		key, err := s.blobAdapter.Put(ctx, data, mimeType)
+		if err != nil {
+			return nil, errors.WD(errors.InternalError, fmt.Errorf("store file %s: %w", name, err))
+		}
+		refs[name] = domain.BlobRef(key)
+	}
+
+	return refs, nil
+}
+
+// DownloadBlob отдает сохраненный бинарный результат по подписанной ссылке на скачивание
+func (s *service) DownloadBlob(ctx context.Context, key, expires, signature string) ([]byte, string, *errors.Error) {
+	if err := s.blobAdapter.CheckLink(key, expires, signature); err != nil {
+		if stdErrors.Is(err, blobAdpt.ErrNotFound) {
+			return nil, "", errors.WD(errors.NotFound, err)
+		}
+		return nil, "", errors.WD(errors.PermissionDenied, err)
+	}
+
+	data, mime, err := s.blobAdapter.Get(ctx, key)
+	if stdErrors.Is(err, blobAdpt.ErrNotFound) {
+		return nil, "", errors.WD(errors.NotFound, err)
+	}
+	if err != nil {
+		return nil, "", errors.WD(errors.InternalError, err)
+	}
+
+	return data, mime, nil
+}
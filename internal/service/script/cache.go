@@ -0,0 +1,109 @@
+package script
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+type cacheScopeCtxKey struct{}
+
+// withCacheScope ответы из кеша получает только тот же автор запуска, чужие ответы ему не видны
+func withCacheScope(ctx context.Context, authorId string) context.Context {
+	return context.WithValue(ctx, cacheScopeCtxKey{}, authorId)
+}
+
+func cacheScopeFrom(ctx context.Context) (string, bool) {
+	authorId, ok := ctx.Value(cacheScopeCtxKey{}).(string)
+	return authorId, ok && authorId != ""
+}
+
+// cacheKey ключ кеша: автор запуска, нода, метод, адрес, итоговые заголовки и тело запроса. Заголовки входят
+// в ключ вместе с ключами доступа, иначе сценарии с разными ключами или пресетами получали бы чужой ответ.
+// Пустой ключ - ответ не кешируется: кеш у ноды выключен, у запуска нет автора или запуск пишет или
+// воспроизводит кассету, где каждый запрос должен дойти до кассеты
+func (h *nodeHandler) cacheKey(ctx context.Context, node domain.Node, headers map[string]string, request nodeRequest) string {
+	if !node.Cache.Enabled() {
+		return ""
+	}
+	if _, ok := cassetteFrom(ctx); ok {
+		return ""
+	}
+
+	authorId, ok := cacheScopeFrom(ctx)
+	if !ok {
+		return ""
+	}
+
+	body, err := requestBody(request)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s %s\n", authorId, node.Id, node.Method, node.Url)
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s: %s\n", strings.ToLower(name), headers[name])
+	}
+	fmt.Fprintf(hash, "%s\n\n", request.contentType)
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cached ответ из кеша. Потоковый ответ отдается клиенту одной дельтой.
+// Кеш - только оптимизация, поэтому его ошибки считаются промахом
+func (h *nodeHandler) cached(ctx context.Context, node domain.Node, key string) (nodeResponse, bool) {
+	if key == "" {
+		return nodeResponse{}, false
+	}
+
+	entry, ok, err := h.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nodeResponse{}, false
+	}
+
+	if node.Stream.Enabled() && entry.Streamed != "" {
+		streamDeltas(ctx)(entry.Streamed)
+	}
+
+	return nodeResponse{
+		Body:       entry.Body,
+		Streamed:   entry.Streamed,
+		Header:     entry.Header,
+		StatusCode: entry.StatusCode,
+		StartedAt:  time.Now(),
+		CacheHit:   true,
+	}, true
+}
+
+// store сохраняет успешный ответ ноды, ошибка сохранения на запуск не влияет
+func (h *nodeHandler) store(ctx context.Context, node domain.Node, key string, res nodeResponse) {
+	if key == "" {
+		return
+	}
+
+	now := time.Now()
+	_ = h.cache.Put(context.WithoutCancel(ctx), domain.CachedResponse{
+		Key:        key,
+		NodeId:     node.Id,
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       res.Body,
+		Streamed:   res.Streamed,
+		ExpiresAt:  now.Add(node.Cache.Ttl()),
+		CreatedAt:  now,
+	})
+}
@@ -0,0 +1,118 @@
+package script
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestCacheKey(t *testing.T) {
+	h := newTestNodeHandler(t)
+	node := domain.Node{Id: "node", Method: http.MethodPost, Url: "http://node/v1", Cache: domain.CacheOptions{TtlMs: 1000}}
+	headers := map[string]string{"Authorization": "Bearer first"}
+	request := nodeRequest{body: []byte(`{"input":"x"}`), contentType: domain.JsonContentType}
+	ctx := withCacheScope(context.Background(), "author")
+
+	base := h.cacheKey(ctx, node, headers, request)
+	if base == "" {
+		t.Fatalf("cacheKey() is empty for a node with cache")
+	}
+	if got := h.cacheKey(ctx, node, map[string]string{"Authorization": "Bearer first"}, request); got != base {
+		t.Fatalf("cacheKey() differs for the same request")
+	}
+
+	otherUrl := node
+	otherUrl.Url = "http://node/v2"
+	withoutCache := node
+	withoutCache.Cache = domain.CacheOptions{}
+
+	tests := []struct {
+		name      string
+		key       string
+		wantEmpty bool
+	}{
+		{name: "another author", key: h.cacheKey(withCacheScope(context.Background(), "other"), node, headers, request)},
+		{name: "another api key", key: h.cacheKey(ctx, node, map[string]string{"Authorization": "Bearer second"}, request)},
+		{name: "another body", key: h.cacheKey(ctx, node, headers, nodeRequest{body: []byte(`{"input":"y"}`), contentType: domain.JsonContentType})},
+		{name: "another url", key: h.cacheKey(ctx, otherUrl, headers, request)},
+		{name: "cache is disabled", key: h.cacheKey(ctx, withoutCache, headers, request), wantEmpty: true},
+		{name: "run without author", key: h.cacheKey(context.Background(), node, headers, request), wantEmpty: true},
+		{name: "run with cassette", key: h.cacheKey(withCassette(ctx, domain.RunCassette{Name: "demo", Mode: domain.CassetteRecord}), node, headers, request), wantEmpty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantEmpty {
+				if tt.key != "" {
+					t.Fatalf("cacheKey() = %q, want no caching", tt.key)
+				}
+				return
+			}
+			if tt.key == "" || tt.key == base {
+				t.Fatalf("cacheKey() = %q, want a key other than %q", tt.key, base)
+			}
+		})
+	}
+}
+
+func TestRunGraphCache(t *testing.T) {
+	var calls atomic.Int32
+	upstream := newTestUpstream(t)
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		upstream.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(counting.Close)
+
+	nodes, presets := testGraphNodes(counting, "first")
+	first := nodes["first"]
+	first.Cache = domain.CacheOptions{TtlMs: 60000}
+	nodes["first"] = first
+	script := domain.Script{BodyPresets: presets, Graph: domain.Graph{Nodes: []domain.GraphNode{{Name: "first", NodeId: "first"}}}}
+	s := &service{nodeHandler: newTestNodeHandler(t)}
+
+	tests := []struct {
+		name      string
+		author    string
+		input     string
+		wantHit   bool
+		wantCalls int32
+	}{
+		{name: "first request goes to the node", author: "author", input: "x", wantCalls: 1},
+		{name: "same request of the same author", author: "author", input: "x", wantHit: true, wantCalls: 1},
+		{name: "same request of another author", author: "other", input: "x", wantCalls: 2},
+		{name: "another request", author: "author", input: "y", wantCalls: 3},
+		// ошибки не кешируются
+		{name: "failed request", author: "author", input: "fail", wantCalls: 4},
+		{name: "failed request again", author: "author", input: "fail", wantCalls: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome, _, err := runTestGraph(t, withCacheScope(context.Background(), tt.author), s, script, nodes, tt.input)
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("upstream calls = %d, want %d", got, tt.wantCalls)
+			}
+			if tt.input == "fail" {
+				if err == nil {
+					t.Fatalf("runGraph() error = nil, want node error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runGraph() unexpected error: %v", err)
+			}
+
+			if outcome.output != "first("+tt.input+")" {
+				t.Fatalf("output = %q, want first(%s)", outcome.output, tt.input)
+			}
+			if len(outcome.steps) != 1 || outcome.steps[0].CacheHit != tt.wantHit {
+				t.Fatalf("steps = %+v, want one step with cache hit %v", outcome.steps, tt.wantHit)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stdErrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	cassetteAdpt "github.com/warehouse/ai-service/internal/adapter/cassette"
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+)
+
+type cassetteCtxKey struct{}
+
+// errCassetteMiss в кассете нет ответа на запрос, в режиме воспроизведения это ошибка ноды без повторов
+var errCassetteMiss = stdErrors.New("no recorded response in cassette")
+
+// withCassette запросы к нодам с этим ctx записываются в кассету или воспроизводятся из нее
+func withCassette(ctx context.Context, cassette domain.RunCassette) context.Context {
+	if !cassette.Enabled() {
+		return ctx
+	}
+
+	return context.WithValue(ctx, cassetteCtxKey{}, cassette)
+}
+
+func cassetteFrom(ctx context.Context) (domain.RunCassette, bool) {
+	cassette, ok := ctx.Value(cassetteCtxKey{}).(domain.RunCassette)
+	return cassette, ok
+}
+
+// runCassette кассета запуска из запроса, а если она не указана - из конфига. Кассету в запросе может выбрать
+// админ или любой пользователь, если это разрешено конфигом
+func (s *service) runCassette(acc *domain.Account, request *models.RunCassetteRequest) (domain.RunCassette, *errors.Error) {
+	cassette := domain.RunCassette{Name: s.cfg.Cassette.Name, Mode: domain.CassetteMode(s.cfg.Cassette.Mode)}
+	if request != nil {
+		if !s.cfg.Cassette.AllowPerRun && acc.Role != domain.RoleAdmin {
+			return domain.RunCassette{}, errors.PermissionDenied
+		}
+		cassette = domain.RunCassette{Name: request.Name, Mode: domain.CassetteMode(request.Mode)}
+	}
+
+	if err := cassette.Validate(); err != nil {
+		return domain.RunCassette{}, errors.WD(errors.ValidationFailed, err)
+	}
+
+	return cassette, nil
+}
+
+// send отправляет запрос к ноде. В режиме воспроизведения ответ берется из кассеты, а в режиме записи
+// возвращается хеш запроса, по которому ответ нужно сохранить после чтения тела
+func (h *nodeHandler) send(ctx context.Context, node domain.Node, req *http.Request, body []byte) (*http.Response, string, error) {
+	cassette, ok := cassetteFrom(ctx)
+	if !ok {
+		res, err := h.client.Do(req)
+		return res, "", err
+	}
+
+	hash := requestHash(req, body)
+	if cassette.Mode == domain.CassetteRecord {
+		res, err := h.client.Do(req)
+		return res, hash, err
+	}
+
+	entry, err := h.cassettes.Get(ctx, cassette.Owner, cassette.Name, node.Id, hash)
+	if stdErrors.Is(err, cassetteAdpt.ErrNotFound) {
+		return nil, "", fmt.Errorf("cassette %s, request %s: %w", cassette.Name, hash, errCassetteMiss)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("cassette %s: %w", cassette.Name, err)
+	}
+
+	return &http.Response{
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header,
+		Body:       io.NopCloser(bytes.NewReader(entry.Body)),
+	}, "", nil
+}
+
+// record сохраняет ответ в кассету, если запрос был отправлен в режиме записи
+func (h *nodeHandler) record(ctx context.Context, node domain.Node, hash string, res nodeResponse) error {
+	cassette, ok := cassetteFrom(ctx)
+	if !ok || hash == "" {
+		return nil
+	}
+
+	err := h.cassettes.Save(context.WithoutCancel(ctx), domain.CassetteEntry{
+		Owner:       cassette.Owner,
+		Cassette:    cassette.Name,
+		NodeId:      node.Id,
+		RequestHash: hash,
+		StatusCode:  res.StatusCode,
+		Header:      res.Header,
+		Body:        res.Body,
+		RecordedAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("record to cassette %s: %w", cassette.Name, err)
+	}
+
+	return nil
+}
+
+// requestHash хеш нормализованного запроса: метод, адрес, заголовки без секретов и тело.
+// Json тело сравнивается без учета порядка ключей, граница multipart заменяется постоянной,
+// секреты не входят в хеш, чтобы кассету можно было воспроизвести с другими ключами
+func requestHash(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", req.Method, req.URL.String())
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		if !sensitiveHeader(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get(domain.HeaderContentType))
+	for _, name := range names {
+		value := strings.Join(req.Header.Values(name), ",")
+		if name == domain.HeaderContentType {
+			value = mediaType
+		}
+		fmt.Fprintf(hash, "%s: %s\n", strings.ToLower(name), value)
+	}
+
+	switch {
+	case domain.IsJsonMime(mediaType):
+		var data interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&data); err == nil {
+			body, _ = json.Marshal(data)
+		}
+	case mediaType == domain.MultipartContentType && params["boundary"] != "":
+		body = bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+	}
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
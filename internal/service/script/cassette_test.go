@@ -0,0 +1,166 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	cassetteAdpt "github.com/warehouse/ai-service/internal/adapter/cassette"
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestRequestHash(t *testing.T) {
+	newRequest := func(method, url, contentType string, headers map[string]string) *http.Request {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatalf("NewRequest() unexpected error: %v", err)
+		}
+		req.Header.Set(domain.HeaderContentType, contentType)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+
+	base := requestHash(newRequest(http.MethodPost, "http://node/v1", domain.JsonContentType, map[string]string{
+		"Authorization": "Bearer first",
+		"X-Model":       "small",
+	}), []byte(`{"a":1,"b":{"c":"d"}}`))
+
+	tests := []struct {
+		name     string
+		req      *http.Request
+		body     string
+		wantSame bool
+	}{
+		{
+			name: "json key order and spaces",
+			req: newRequest(http.MethodPost, "http://node/v1", domain.JsonContentType, map[string]string{
+				"Authorization": "Bearer first",
+				"X-Model":       "small",
+			}),
+			body:     "{\"b\": {\"c\": \"d\"},\n \"a\": 1}",
+			wantSame: true,
+		},
+		{
+			name: "other secrets and content type params",
+			req: newRequest(http.MethodPost, "http://node/v1", "application/json; charset=utf-8", map[string]string{
+				"Authorization": "Bearer second",
+				"X-Api-Key":     "key",
+				"X-Model":       "small",
+			}),
+			body:     `{"a":1,"b":{"c":"d"}}`,
+			wantSame: true,
+		},
+		{
+			name: "other body",
+			req: newRequest(http.MethodPost, "http://node/v1", domain.JsonContentType, map[string]string{
+				"Authorization": "Bearer first",
+				"X-Model":       "small",
+			}),
+			body: `{"a":2,"b":{"c":"d"}}`,
+		},
+		{
+			name: "other header",
+			req: newRequest(http.MethodPost, "http://node/v1", domain.JsonContentType, map[string]string{
+				"Authorization": "Bearer first",
+				"X-Model":       "large",
+			}),
+			body: `{"a":1,"b":{"c":"d"}}`,
+		},
+		{
+			name: "other url",
+			req: newRequest(http.MethodPost, "http://node/v2", domain.JsonContentType, map[string]string{
+				"Authorization": "Bearer first",
+				"X-Model":       "small",
+			}),
+			body: `{"a":1,"b":{"c":"d"}}`,
+		},
+		{
+			name: "other method",
+			req: newRequest(http.MethodPut, "http://node/v1", domain.JsonContentType, map[string]string{
+				"Authorization": "Bearer first",
+				"X-Model":       "small",
+			}),
+			body: `{"a":1,"b":{"c":"d"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if same := requestHash(tt.req, []byte(tt.body)) == base; same != tt.wantSame {
+				t.Fatalf("same hash = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+
+	t.Run("multipart boundary", func(t *testing.T) {
+		multipart := func(boundary string) string {
+			req := newRequest(http.MethodPost, "http://node/v1", domain.MultipartContentType+"; boundary="+boundary, nil)
+			body := "--" + boundary + "\r\nContent-Disposition: form-data; name=\"prompt\"\r\n\r\nhi\r\n--" + boundary + "--\r\n"
+			return requestHash(req, []byte(body))
+		}
+		if multipart("first0boundary") != multipart("second1boundary") {
+			t.Fatalf("hash depends on the random multipart boundary")
+		}
+	})
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	var calls atomic.Int32
+	upstream := newTestUpstream(t)
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		upstream.Config.Handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(counting.Close)
+
+	nodes, presets := testGraphNodes(counting, "first")
+	script := domain.Script{BodyPresets: presets, Graph: domain.Graph{Nodes: []domain.GraphNode{{Name: "first", NodeId: "first"}}}}
+
+	cassettes, err := cassetteAdpt.NewAdapter(config.Cassette{Dir: t.TempDir()}, nil, nil)
+	if err != nil {
+		t.Fatalf("cassette.NewAdapter() unexpected error: %v", err)
+	}
+	s := &service{nodeHandler: newTestNodeHandler(t)}
+	s.nodeHandler.cassettes = cassettes
+
+	run := func(mode domain.CassetteMode, owner, input string) (string, error) {
+		ctx := withCassette(context.Background(), domain.RunCassette{Name: "demo", Mode: mode, Owner: owner})
+		outcome, _, err := runTestGraph(t, ctx, s, script, nodes, input)
+		return outcome.output, err
+	}
+
+	if _, err := run(domain.CassetteRecord, "author", "x"); err != nil {
+		t.Fatalf("record run unexpected error: %v", err)
+	}
+	// ошибки ноды тоже записываются
+	if _, err := run(domain.CassetteRecord, "author", "fail"); err == nil {
+		t.Fatalf("record run error = nil, want node error")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("upstream calls while recording = %d, want 2", got)
+	}
+
+	output, err := run(domain.CassetteReplay, "author", "x")
+	if err != nil || output != "first(x)" {
+		t.Fatalf("replay = %q, %v, want recorded output", output, err)
+	}
+	if _, err := run(domain.CassetteReplay, "author", "fail"); err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Fatalf("replay error = %v, want recorded node error", err)
+	}
+	if _, err := run(domain.CassetteReplay, "author", "y"); !errors.Is(err, errCassetteMiss) {
+		t.Fatalf("replay of another request error = %v, want cassette miss", err)
+	}
+	if _, err := run(domain.CassetteReplay, "other", "x"); !errors.Is(err, errCassetteMiss) {
+		t.Fatalf("replay of another author error = %v, want cassette miss", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Fatalf("upstream calls while replaying = %d, want none", got-2)
+	}
+}
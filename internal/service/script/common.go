@@ -4,76 +4,92 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/warehouse/ai-service/internal/domain"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
 	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
-
-	"github.com/thedevsaddam/gojsonq/v2"
 )
 
-func (s *service) chainHandler(
-	stepWg *sync.WaitGroup,
-	stepCh chan domain.ChainResult,
+// execNode вызывает ноду и возвращает ее результат с mime, данными для ноды служит scope.data. step - заготовка
+// записи истории с координатами вызова, вызовы возвращаются и при ошибке, чтобы сохранить их в историю
+func (s *service) execNode(
+	ctx context.Context,
+	step domain.RunStep,
+	node domain.Node,
 	bodyPresets map[string]map[string]interface{},
 	headerPresets map[string]map[string]string,
-	chain []domain.Node,
-	prompt string,
-) {
-	defer stepWg.Done()
-	nodeHandler := newNodeHandler(s.cfg.Timeouts.RequestTimeout)
-	jsonq := gojsonq.New()
-
-	finalMime := domain.JsonContentType
-	for _, node := range chain {
-		requestBody, err := s.generateNodeFilledObject(node.Body, prompt, bodyPresets[node.Id])
-		if err != nil {
-			stepCh <- domain.ChainResult{
-				Response: "",
-				Mime:     "",
-				Error:    err,
-			}
-			return
-		}
-		marshaledBody, err := json.Marshal(requestBody)
-		if err != nil {
-			stepCh <- domain.ChainResult{
-				Response: "",
-				Mime:     "",
-				Error:    err,
-			}
-			return
-		}
+	scope templateScope,
+) (string, string, []domain.RunStep, error) {
+	// в пробном запуске нода не вызывается, запрос только записывается
+	if dry, ok := dryRunFrom(ctx); ok {
+		output, mime := s.dryRunNode(dry, step, node, bodyPresets, headerPresets, scope)
+		return output, mime, []domain.RunStep{}, nil
+	}
 
-		r, err := nodeHandler.makeHTTPRequest(node, headerPresets[node.Id], marshaledBody)
+	step.NodeId = node.Id
+	step.Attempt = 1
+	step.RequestHeaders = redactHeaders(node, headerPresets[node.Id])
+	step.StartedAt = time.Now()
+
+	requestBody, err := s.generateNodeFilledObject(node.Body, scope, bodyPresets[node.Id])
+	if err != nil {
+		step.Error = err.Error()
+		return "", "", []domain.RunStep{step}, err
+	}
+	marshaledBody, err := json.Marshal(requestBody)
+	if err != nil {
+		step.Error = err.Error()
+		return "", "", []domain.RunStep{step}, err
+	}
+	step.RequestBody = string(marshaledBody)
+
+	// в историю пишется заполненное тело со ссылками на файлы, а нода получает его в своем формате
+	request, err := s.encodeNodeBody(ctx, node, node.Body, requestBody)
+	if err != nil {
+		step.Error = err.Error()
+		return "", "", []domain.RunStep{step}, err
+	}
+
+	// каждая попытка запроса сохраняется в историю отдельной записью
+	steps := []domain.RunStep{}
+	r, err := s.nodeHandler.callNode(ctx, node, headerPresets[node.Id], request, func(attempt int, res nodeResponse, err error) {
+		attemptStep := step
+		attemptStep.Attempt = attempt
+		attemptStep.Response = historyResponse(res)
+		attemptStep.StatusCode = res.StatusCode
+		attemptStep.Latency = res.Latency
+		attemptStep.StartedAt = res.StartedAt
+		attemptStep.CacheHit = res.CacheHit
+		attemptStep.Usage = callUsage(node, res)
 		if err != nil {
-			stepCh <- domain.ChainResult{
-				Response: "",
-				Mime:     "",
-				Error:    err,
-			}
-			return
+			attemptStep.Error = err.Error()
 		}
-
-		prompt = jsonq.FromString(string(r)).Find(node.ResponseDirection).(string)
-		finalMime = node.ResponseMime
+		steps = append(steps, attemptStep)
+	})
+	if err != nil {
+		return "", "", steps, err
 	}
 
-	stepCh <- domain.ChainResult{
-		Response: prompt,
-		Mime:     finalMime,
-		Error:    nil,
+	// ответ без результата считаем ошибкой ноды, на нее срабатывают запасные ноды
+	output, mime, err := s.nodeOutput(ctx, node, r)
+	if err != nil {
+		steps[len(steps)-1].Error = err.Error()
+		return "", "", steps, err
 	}
+	steps[len(steps)-1].Output = output
+
+	return output, mime, steps, nil
 }
 
-func (s *service) fillScriptMap(ctx context.Context, tx transactions.Transaction, workflow map[int]map[int][]string) (map[int]map[int][]domain.Node, *errors.Error) {
-	nodesIds := []string{}
-	for _, step := range workflow {
-		for _, id := range step {
-			nodesIds = append(nodesIds, id...)
-		}
+// loadGraphNodes достает ноды, которые вызывает граф, ключ - айди ноды
+func (s *service) loadGraphNodes(ctx context.Context, tx transactions.Transaction, graph domain.Graph) (map[string]domain.Node, *errors.Error) {
+	nodesIds := graph.NodeIds()
+	// граф может состоять только из вложенных сценариев и join узлов, а репозиторий на пустой список отвечает ошибкой
+	if len(nodesIds) == 0 {
+		return map[string]domain.Node{}, nil
 	}
 
 	nodes, err := s.nodesRepo.GetByIds(ctx, tx, nodesIds)
@@ -89,29 +105,14 @@ func (s *service) fillScriptMap(ctx context.Context, tx transactions.Transaction
 		}
 	}
 
-	scriptFilledMap := make(map[int]map[int][]domain.Node)
-	for i, step := range workflow {
-		filledStep := make(map[int][]domain.Node)
-		for k, chain := range step {
-			filledChain := make([]domain.Node, len(chain))
-
-			// Проверяем, что такая нода существует в скрипте, если нет -> возвращаем ошибку сразу
-			for j, nodeId := range chain {
-				node, ok := nodesMap[nodeId]
-				if !ok {
-					return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("node with id %s not found", nodeId))
-				}
-
-				filledChain[j] = node
-			}
-
-			filledStep[k] = filledChain
+	// Проверяем, что такая нода существует, если нет -> возвращаем ошибку сразу
+	for _, nodeId := range nodesIds {
+		if _, ok := nodesMap[nodeId]; !ok {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("node with id %s not found", nodeId))
 		}
-
-		scriptFilledMap[i] = filledStep
 	}
 
-	return scriptFilledMap, nil
+	return nodesMap, nil
 }
 
 // Мапа мапы потому что сначала айдишник ноды потом ключ значение параметра в боди
@@ -128,9 +129,8 @@ func (s *service) validateBodyPresets(usedNodes map[string]domain.Node, bodyPres
 		requiredNodeField[id] = requiredFields
 	}
 
-	// проходимся по пресетам и проверяем что:
-	// 1. все обязательные поля/пресеты объявлены
-	// 2. данные в пресете совпадают с доступными данными, в соответствии с типом поля
+	// проходимся по пресетам и проверяем, что данные в пресете совпадают с доступными данными,
+	// в соответствии с типом поля
 	for nodeId, nodePresets := range bodyPresets {
 		node := usedNodes[nodeId]
 		requiredFields := requiredNodeField[node.Id]
@@ -147,14 +147,19 @@ func (s *service) validateBodyPresets(usedNodes map[string]domain.Node, bodyPres
 
 			delete(requiredFields, fieldName)
 		}
+	}
 
+	// все обязательные поля объявлены у каждой используемой ноды, в том числе у запасных нод без пресетов:
+	// при вызове запасная нода получает только свои пресеты
+	for nodeId, requiredFields := range requiredNodeField {
 		if len(requiredFields) != 0 {
 			missedFields := []string{}
 			for key := range requiredFields {
 				missedFields = append(missedFields, key)
 			}
+			sort.Strings(missedFields)
 
-			return errors.WD(errors.ValidationFailed, fmt.Errorf("required fields [%s] have not been declared", strings.Join(missedFields, ", ")))
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("node %s: required fields [%s] have not been declared", nodeId, strings.Join(missedFields, ", ")))
 		}
 	}
 
@@ -189,74 +194,67 @@ func (s *service) validateHeaderPresets(usedNodes map[string]domain.Node, header
 
 			delete(requiredHeaders, headerName)
 		}
+	}
 
+	// обязательные заголовки проверяются у всех используемых нод, в том числе у запасных нод без пресетов
+	for nodeId, requiredHeaders := range requiredNodeHeaders {
 		if len(requiredHeaders) != 0 {
 			missedHeaders := []string{}
 			for key := range requiredHeaders {
 				missedHeaders = append(missedHeaders, key)
 			}
+			sort.Strings(missedHeaders)
 
-			return errors.WD(errors.ValidationFailed, fmt.Errorf("required headers [%s] have not been provided", strings.Join(missedHeaders, ", ")))
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("node %s: required headers [%s] have not been provided", nodeId, strings.Join(missedHeaders, ", ")))
 		}
 	}
 
 	return nil
 }
 
-func (s *service) validateWorkflow(ctx context.Context, tx transactions.Transaction, workflow map[string][]interface{}) (map[string]domain.Node, *errors.Error) {
-	usedNodes := []string{}
-
-	for _, values := range workflow {
-		for _, val := range values {
-			switch v := val.(type) {
-			case []string:
-				usedNodes = append(usedNodes, v...)
+// validateWorkflow проверяет сценарий в одном из форматов: шаги и цепочки или граф.
+// Возвращает используемые ноды, ключ - айди ноды
+func (s *service) validateWorkflow(ctx context.Context, tx transactions.Transaction, script domain.Script) (map[string]domain.Node, *errors.Error) {
+	if script.Graph.Empty() {
+		if len(script.Workflow) == 0 {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("workflow should have at least one step"))
+		}
 
-			case string:
-				usedNodes = append(usedNodes, v)
-			default:
-				return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("workflow should by map of only strings, where string is node id"))
-			}
+		if err := validateStepOptions(script.Workflow, script.Options); err != nil {
+			return nil, errors.WD(errors.ValidationFailed, err)
 		}
+	} else if len(script.Workflow) != 0 || len(script.Options.Steps) != 0 {
+		return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("workflow and steps can't be used together with graph"))
 	}
 
-	nodes, err := s.nodesRepo.GetByIds(ctx, tx, usedNodes)
+	graph, err := script.ExecutionGraph()
 	if err != nil {
-		return nil, errors.DatabaseError(err)
+		return nil, errors.WD(errors.ValidationFailed, err)
 	}
 
-	usedNodesMap := make(map[string]domain.Node)
-	for _, node := range nodes {
-		usedNodesMap[node.Id.String()], err = domain.Node{}.FromModel(node)
-		if err != nil {
-			return nil, errors.WD(errors.ParseError, err)
-		}
-	}
-
-	return usedNodesMap, nil
+	return s.loadGraphNodes(ctx, tx, graph)
 }
 
-func (s *service) parseStep(stepData []interface{}) map[int][]string {
-	stepChainMap := make(map[int][]string)
-
-	for i, v := range stepData {
-		switch value := v.(type) {
-		case []interface{}:
-			resMap := s.parseStep(value)
-			stepChainMap[i] = append(stepChainMap[i], resMap[0]...)
-		case string:
-			stepChainMap[i] = append(stepChainMap[i], value)
-		default:
-			fmt.Printf("Unexpected type %T\n", value)
-		}
+// validatePresetTemplates проверяется после пресетов, когда известно, что поля пресетов есть в нодах
+func (s *service) validatePresetTemplates(script domain.Script, usedNodes map[string]domain.Node) *errors.Error {
+	graph, err := script.ExecutionGraph()
+	if err != nil {
+		return errors.WD(errors.ValidationFailed, err)
+	}
+
+	if err := validateTemplates(script, graph, usedNodes); err != nil {
+		return errors.WD(errors.ValidationFailed, err)
 	}
 
-	return stepChainMap
+	return nil
 }
 
+// generateNodeFilledObject заполняет тело запроса по пресетам. Поля data и file получают вход узла,
+// а пресеты полей prompt, data и file могут быть шаблонами со ссылками на результаты запуска и файлы.
+// Поле data получает json значение с его типом, если это json результат целиком или шаблон из одной ссылки
 func (s *service) generateNodeFilledObject(
 	fields map[string]domain.BodyField,
-	data string,
+	scope templateScope,
 	bodyPresets map[string]interface{},
 ) (map[string]interface{}, error) {
 	generatedJson := make(map[string]interface{})
@@ -265,44 +263,37 @@ func (s *service) generateNodeFilledObject(
 		if _, ok := bodyPresets[name]; ok {
 			switch value.Type {
 			case domain.PromptFieldType:
-				generatedJson[name] = bodyPresets[name].(string)
+				generatedJson[name] = scope.render(bodyPresets[name].(string))
 			case domain.ConstFieldType:
 				generatedJson[name] = bodyPresets[name].(string)
 			case domain.SelectFieldType:
 				generatedJson[name] = bodyPresets[name].(string)
 			case domain.ObjectFieldType:
-				nestedFieldsTyped := make(map[string]domain.BodyField)
-				nestedFields, ok := value.Values[0].(map[string]interface{})
-				if !ok {
-					return nil, fmt.Errorf("can't parse to nested structure")
-				}
-
-				for nestedName, nestedValue := range nestedFields {
-					obj, err := json.Marshal(nestedValue)
-					if err != nil {
-						return nil, err
-					}
-
-					var nestedValueTyped domain.BodyField
-					if err := json.Unmarshal(obj, &nestedValueTyped); err != nil {
-						return nil, err
-					}
-
-					nestedFieldsTyped[nestedName] = nestedValueTyped
+				nestedFieldsTyped, err := value.NestedFields()
+				if err != nil {
+					return nil, err
 				}
 
 				nestedBodyPresets, ok := bodyPresets[name].(map[string]interface{})
 				if !ok {
 					return nil, fmt.Errorf("can't parse to nested structure")
 				}
-				filledNestedFields, err := s.generateNodeFilledObject(nestedFieldsTyped, data, nestedBodyPresets)
+				filledNestedFields, err := s.generateNodeFilledObject(nestedFieldsTyped, scope, nestedBodyPresets)
 				if err != nil {
 					return nil, err
 				}
 
 				generatedJson[name] = filledNestedFields
 			case domain.DataFieldType:
-				generatedJson[name] = data
+				// пустой пресет - просто вход узла
+				template, _ := bodyPresets[name].(string)
+				generatedJson[name] = scope.typed(template)
+			case domain.FileFieldType:
+				if template, _ := bodyPresets[name].(string); template != "" {
+					generatedJson[name] = scope.render(template)
+				} else {
+					generatedJson[name] = scope.data
+				}
 			}
 		}
 	}
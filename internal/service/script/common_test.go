@@ -0,0 +1,132 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestValidateBodyPresets(t *testing.T) {
+	usedNodes := map[string]domain.Node{
+		"primary": {Id: "primary", Body: map[string]domain.BodyField{
+			"prompt": {Type: domain.PromptFieldType, Values: []interface{}{""}, Required: true},
+			"model":  {Type: domain.SelectFieldType, Values: []interface{}{"a", "b"}},
+		}},
+		"fallback": {Id: "fallback", Body: map[string]domain.BodyField{
+			"input": {Type: domain.DataFieldType, Required: true},
+		}},
+		"optional": {Id: "optional", Body: map[string]domain.BodyField{
+			"model": {Type: domain.SelectFieldType, Values: []interface{}{"a", "b"}},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		presets map[string]map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "all required fields declared",
+			presets: map[string]map[string]interface{}{
+				"primary":  {"prompt": "{{input}}", "model": "a"},
+				"fallback": {"input": ""},
+			},
+		},
+		{
+			name: "fallback without presets",
+			presets: map[string]map[string]interface{}{
+				"primary": {"prompt": "{{input}}"},
+			},
+			wantErr: "node fallback: required fields [input]",
+		},
+		{
+			name: "primary misses required field",
+			presets: map[string]map[string]interface{}{
+				"primary":  {"model": "a"},
+				"fallback": {"input": ""},
+			},
+			wantErr: "node primary: required fields [prompt]",
+		},
+		{
+			name: "value outside select",
+			presets: map[string]map[string]interface{}{
+				"primary":  {"prompt": "x", "model": "c"},
+				"fallback": {"input": ""},
+			},
+			wantErr: "field model",
+		},
+		{
+			name: "unknown field",
+			presets: map[string]map[string]interface{}{
+				"primary":  {"prompt": "x", "temperature": "1"},
+				"fallback": {"input": ""},
+			},
+			wantErr: "field temperature: not found",
+		},
+	}
+
+	s := &service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.validateBodyPresets(usedNodes, tt.presets)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateBodyPresets() unexpected error: %v", err.Details)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Details.Error(), tt.wantErr) {
+				t.Fatalf("validateBodyPresets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHeaderPresets(t *testing.T) {
+	usedNodes := map[string]domain.Node{
+		"primary": {Id: "primary", Headers: map[string]domain.Header{
+			"X-Model": {Type: domain.SelectHeaderType, Values: []string{"a", "b"}},
+		}},
+		"fallback": {Id: "fallback", Headers: map[string]domain.Header{
+			"X-Tenant": {Type: domain.PromptHeaderType, Required: true},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		presets map[string]map[string]string
+		wantErr string
+	}{
+		{
+			name:    "required header declared",
+			presets: map[string]map[string]string{"fallback": {"X-Tenant": "t1"}},
+		},
+		{
+			name:    "fallback without presets",
+			presets: map[string]map[string]string{"primary": {"X-Model": "a"}},
+			wantErr: "node fallback: required headers [X-Tenant]",
+		},
+		{
+			name:    "value outside select",
+			presets: map[string]map[string]string{"primary": {"X-Model": "c"}, "fallback": {"X-Tenant": "t1"}},
+			wantErr: "header X-Model",
+		},
+	}
+
+	s := &service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.validateHeaderPresets(usedNodes, tt.presets)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateHeaderPresets() unexpected error: %v", err.Details)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Details.Error(), tt.wantErr) {
+				t.Fatalf("validateHeaderPresets() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
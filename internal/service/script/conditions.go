@@ -0,0 +1,83 @@
+package script
+
+import (
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// validateStepOptions проверяет, что настройки относятся к существующим шагам, цепочкам и нодам цепочек,
+// а условия ссылаются только на результаты предыдущих шагов
+func validateStepOptions(workflow map[int]map[int][]string, options domain.ScriptOptions) error {
+	for stepKey, stepOptions := range options.Steps {
+		step, ok := workflow[stepKey]
+		if !ok {
+			return fmt.Errorf("options for unknown step %d", stepKey)
+		}
+
+		if stepOptions.When != nil {
+			if err := validateCondition(workflow, stepKey, *stepOptions.When); err != nil {
+				return fmt.Errorf("step %d: %s", stepKey, err.Error())
+			}
+		}
+
+		if err := stepOptions.OnError.Validate(); err != nil {
+			return fmt.Errorf("step %d: %s", stepKey, err.Error())
+		}
+
+		for chainKey, chainOptions := range stepOptions.Chains {
+			chain, ok := step[chainKey]
+			if !ok {
+				return fmt.Errorf("step %d: options for unknown chain %d", stepKey, chainKey)
+			}
+
+			if len(chainOptions.Fallbacks) != 0 && chainOptions.Map != nil {
+				return fmt.Errorf("step %d, chain %d: fallbacks are not supported for map chains", stepKey, chainKey)
+			}
+			if err := chainOptions.OnError.Validate(); err != nil {
+				return fmt.Errorf("step %d, chain %d: %s", stepKey, chainKey, err.Error())
+			}
+
+			for position := range chainOptions.Fallbacks {
+				if position < 0 || position >= len(chain) {
+					return fmt.Errorf("step %d, chain %d: fallbacks for unknown position %d", stepKey, chainKey, position)
+				}
+			}
+
+			if chainOptions.When != nil {
+				if err := validateCondition(workflow, stepKey, *chainOptions.When); err != nil {
+					return fmt.Errorf("step %d, chain %d: %s", stepKey, chainKey, err.Error())
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateCondition(workflow map[int]map[int][]string, stepKey int, cond domain.Condition) error {
+	if err := cond.Validate(); err != nil {
+		return err
+	}
+
+	if cond.Source == nil {
+		return nil
+	}
+
+	source, ok := workflow[cond.Source.Step]
+	if !ok {
+		return fmt.Errorf("condition references unknown step %d", cond.Source.Step)
+	}
+
+	if cond.Source.Step >= stepKey {
+		return fmt.Errorf("condition references step %d, which is not executed before step %d", cond.Source.Step, stepKey)
+	}
+
+	if cond.Source.Chain != nil {
+		if _, ok := source[*cond.Source.Chain]; !ok {
+			return fmt.Errorf("condition references unknown chain %d of step %d", *cond.Source.Chain, cond.Source.Step)
+		}
+	}
+
+	return nil
+}
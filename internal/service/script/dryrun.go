@@ -0,0 +1,191 @@
+package script
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+)
+
+type (
+	dryRunCtxKey struct{}
+
+	// dryRunLog запросы пробного запуска, общие для всех узлов и вложенных сценариев
+	dryRunLog struct {
+		mu       sync.Mutex
+		mocks    map[string]string
+		requests []domain.DryRunRequest
+	}
+
+	// dryRun пробный запуск: ноды не вызываются, их запросы собираются в лог, а результат - значение из mocks
+	// или заглушка. prefix - путь вложенного сценария, как у вызовов в истории
+	dryRun struct {
+		log    *dryRunLog
+		prefix string
+	}
+)
+
+func withDryRun(ctx context.Context, dry dryRun) context.Context {
+	return context.WithValue(ctx, dryRunCtxKey{}, dry)
+}
+
+func dryRunFrom(ctx context.Context) (dryRun, bool) {
+	dry, ok := ctx.Value(dryRunCtxKey{}).(dryRun)
+	return dry, ok
+}
+
+func (d dryRun) nested(graphNode string) dryRun {
+	d.prefix += graphNode + "/"
+	return d
+}
+
+func (d dryRun) record(request domain.DryRunRequest) {
+	d.log.mu.Lock()
+	defer d.log.mu.Unlock()
+
+	d.log.requests = append(d.log.requests, request)
+}
+
+// output результат узла: значение из mocks (json, если это валидный json) или заглушка с именем узла
+func (d dryRun) output(graphNode string) (string, string) {
+	mock, ok := d.log.mocks[graphNode]
+	if !ok {
+		return domain.DryRunPlaceholder(graphNode), domain.TextContentType
+	}
+
+	if json.Valid([]byte(mock)) {
+		return mock, domain.JsonContentType
+	}
+
+	return mock, domain.TextContentType
+}
+
+// DryRun выполняет сценарий без вызова нод и без сохранения запуска: возвращает запросы, которые ушли бы в ноды.
+// Условия when не проверяются, чтобы в план попали все ветки графа
+func (s *service) DryRun(ctx context.Context, acc *domain.Account, request models.RunScriptRequest) ([]domain.DryRunRequest, *errors.Error) {
+	script, graph, nodes, e := s.loadScript(ctx, request.Id)
+	if e != nil {
+		return nil, e
+	}
+	if !canRunScript(acc, script.AuthorId) {
+		return nil, errors.WD(errors.PermissionDenied, fmt.Errorf("script %s", request.Id))
+	}
+
+	files, e := dryRunFiles(request.Files)
+	if e != nil {
+		return nil, e
+	}
+
+	run := domain.ScriptRun{
+		ScriptId:  script.Id,
+		AuthorId:  acc.Id,
+		EnterData: request.EnterData,
+		Files:     files,
+	}
+
+	dry := dryRun{log: &dryRunLog{mocks: request.Mocks}}
+	if _, err := s.runGraph(withDryRun(ctx, dry), run, script, graph, nodes, noopObserver); err != nil {
+		return nil, execError(err)
+	}
+
+	requests := dry.log.requests
+	sort.SliceStable(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.Step != b.Step {
+			return a.Step < b.Step
+		}
+		if a.Chain != b.Chain {
+			return a.Chain < b.Chain
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.GraphNode < b.GraphNode
+	})
+
+	return requests, nil
+}
+
+// dryRunFiles файлы пробного запуска не сохраняются, в шаблоны подставляется их описание
+func dryRunFiles(files map[string]models.RunFileRequest) (map[string]string, *errors.Error) {
+	placeholders := make(map[string]string, len(files))
+	for name, file := range files {
+		if name == "" {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("file name is empty"))
+		}
+
+		data, err := base64.StdEncoding.DecodeString(file.Data)
+		if err != nil {
+			return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("file %s: %s", name, err.Error()))
+		}
+
+		mimeType := file.Mime
+		if mimeType == "" {
+			mimeType = domain.OctetStreamContentType
+		}
+		placeholders[name] = fmt.Sprintf("<file %s (%s, %d bytes)>", name, mimeType, len(data))
+	}
+
+	return placeholders, nil
+}
+
+// dryRunNode заполняет запрос к ноде так же, как execNode, но вместо вызова записывает его в лог пробного запуска
+func (s *service) dryRunNode(
+	dry dryRun,
+	step domain.RunStep,
+	node domain.Node,
+	bodyPresets map[string]map[string]interface{},
+	headerPresets map[string]map[string]string,
+	scope templateScope,
+) (string, string) {
+	request := domain.DryRunRequest{
+		GraphNode: dry.prefix + step.GraphNode,
+		NodeId:    node.Id,
+		NodeName:  node.Name,
+		Step:      step.Step,
+		Chain:     step.Chain,
+		Position:  step.Position,
+		Url:       node.Url,
+		Method:    string(node.Method),
+	}
+
+	// заголовки в том же порядке приоритета, что и в makeHTTPRequest
+	encoding, err := node.RequestEncoding()
+	headers := map[string]string{}
+	if err == nil && encoding == domain.JsonContentType {
+		headers[domain.HeaderContentType] = encoding
+	}
+	for name, value := range headerPresets[node.Id] {
+		headers[name] = value
+	}
+	if err == nil && encoding != domain.JsonContentType {
+		headers[domain.HeaderContentType] = encoding
+	}
+	request.Headers = redactHeaders(node, headers)
+	request.ContentType = encoding
+
+	if err != nil {
+		request.Error = err.Error()
+		dry.record(request)
+		return dry.output(request.GraphNode)
+	}
+
+	body, err := s.generateNodeFilledObject(node.Body, scope, bodyPresets[node.Id])
+	if err == nil {
+		var marshaled []byte
+		marshaled, err = json.Marshal(body)
+		request.Body = string(marshaled)
+	}
+	if err != nil {
+		request.Error = err.Error()
+	}
+
+	dry.record(request)
+	return dry.output(request.GraphNode)
+}
@@ -0,0 +1,110 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestRunGraphDryRun(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	t.Cleanup(upstream.Close)
+
+	nodes, presets := testGraphNodes(upstream, "first", "second")
+	first := nodes["first"]
+	first.ApiKey = "sk-node-key"
+	nodes["first"] = first
+
+	script := domain.Script{
+		BodyPresets: presets,
+		HeaderPresets: map[string]map[string]string{"first": {
+			"Authorization":   "Bearer sk-node-key",
+			"X-Upstream-Auth": "sk-node-key",
+			"X-Api-Token":     "other secret",
+			"X-Request-Tag":   "dry",
+		}},
+		Graph: domain.Graph{Nodes: []domain.GraphNode{
+			{Name: "first", NodeId: "first"},
+			// условия не проверяются, иначе ветка не попала бы в план
+			{Name: "second", NodeId: "second", Inputs: []string{"first"}, When: &domain.Condition{Op: domain.EqConditionOp, Value: "never"}},
+		}},
+	}
+
+	tests := []struct {
+		name       string
+		mocks      map[string]string
+		wantSecond string
+		wantOutput string
+	}{
+		{
+			name:       "placeholders for results of nodes",
+			wantSecond: "<output of first>",
+			wantOutput: "<output of second>",
+		},
+		{
+			name:       "mocked results",
+			mocks:      map[string]string{"first": "mocked", "second": `{"out":1}`},
+			wantSecond: "mocked",
+			wantOutput: `{"out":1}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{nodeHandler: newTestNodeHandler(t)}
+			dry := dryRun{log: &dryRunLog{mocks: tt.mocks}}
+
+			outcome, _, err := runTestGraph(t, withDryRun(context.Background(), dry), s, script, nodes, "x")
+			if err != nil {
+				t.Fatalf("runGraph() unexpected error: %v", err)
+			}
+			if outcome.output != tt.wantOutput {
+				t.Fatalf("output = %q, want %q", outcome.output, tt.wantOutput)
+			}
+			if got := calls.Load(); got != 0 {
+				t.Fatalf("upstream calls = %d, want none", got)
+			}
+
+			requests := map[string]domain.DryRunRequest{}
+			for _, request := range dry.log.requests {
+				requests[request.GraphNode] = request
+			}
+			if len(requests) != 2 {
+				t.Fatalf("requests = %+v, want both nodes", dry.log.requests)
+			}
+
+			request := requests["first"]
+			if request.Url != upstream.URL+"/first" || request.Method != http.MethodPost || request.Body != `{"input":"x"}` {
+				t.Fatalf("first request = %+v, want rendered request to the node", request)
+			}
+			wantHeaders := map[string]string{
+				domain.HeaderContentType: domain.JsonContentType,
+				"Authorization":          redactedValue,
+				"X-Upstream-Auth":        redactedValue,
+				"X-Api-Token":            redactedValue,
+				"X-Request-Tag":          "dry",
+			}
+			if len(request.Headers) != len(wantHeaders) {
+				t.Fatalf("headers = %v, want %v", request.Headers, wantHeaders)
+			}
+			for name, want := range wantHeaders {
+				if request.Headers[name] != want {
+					t.Fatalf("header %s = %q, want %q", name, request.Headers[name], want)
+				}
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal([]byte(requests["second"].Body), &body); err != nil || body["input"] != tt.wantSecond {
+				t.Fatalf("second body = %s, want input %q", requests["second"].Body, tt.wantSecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// nodeRequest тело запроса к ноде в формате request_mime и его Content-Type
+type nodeRequest struct {
+	body        []byte
+	contentType string
+}
+
+// encodeNodeBody кодирует заполненное тело запроса по request_mime ноды. Поля file содержат ссылку на blob:
+// в json они передаются base64 строкой, в multipart - отдельной частью с файлом
+func (s *service) encodeNodeBody(
+	ctx context.Context,
+	node domain.Node,
+	fields map[string]domain.BodyField,
+	filled map[string]interface{},
+) (nodeRequest, error) {
+	encoding, err := node.RequestEncoding()
+	if err != nil {
+		return nodeRequest{}, err
+	}
+
+	switch encoding {
+	case domain.MultipartContentType:
+		return s.encodeMultipart(ctx, fields, filled)
+	case domain.FormContentType:
+		return encodeForm(fields, filled)
+	}
+
+	inlined, err := s.inlineFiles(ctx, fields, filled)
+	if err != nil {
+		return nodeRequest{}, err
+	}
+
+	body, err := json.Marshal(inlined)
+	if err != nil {
+		return nodeRequest{}, err
+	}
+
+	return nodeRequest{body: body, contentType: domain.JsonContentType}, nil
+}
+
+// inlineFiles заменяет ссылки на blob в полях file содержимым файла в base64, в том числе во вложенных объектах
+func (s *service) inlineFiles(ctx context.Context, fields map[string]domain.BodyField, filled map[string]interface{}) (map[string]interface{}, error) {
+	inlined := make(map[string]interface{}, len(filled))
+	for name, value := range filled {
+		inlined[name] = value
+
+		switch fields[name].Type {
+		case domain.FileFieldType:
+			ref, _ := value.(string)
+			key, ok := domain.BlobKey(ref)
+			if !ok {
+				continue
+			}
+
+			data, _, err := s.blobAdapter.Get(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			inlined[name] = base64.StdEncoding.EncodeToString(data)
+		case domain.ObjectFieldType:
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			nestedFields, err := fields[name].NestedFields()
+			if err != nil {
+				return nil, err
+			}
+
+			inlined[name], err = s.inlineFiles(ctx, nestedFields, nested)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return inlined, nil
+}
+
+// encodeMultipart каждое поле - отдельная часть, поля file - файл с именем поля, объекты - json текстом.
+// Поля пишутся в порядке имен, чтобы запросы с одинаковыми данными совпадали до границы частей
+func (s *service) encodeMultipart(ctx context.Context, fields map[string]domain.BodyField, filled map[string]interface{}) (nodeRequest, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	for _, name := range sortedKeys(filled) {
+		value := filled[name]
+
+		if fields[name].Type == domain.FileFieldType {
+			data, mimeType, err := s.fileContent(ctx, value)
+			if err != nil {
+				return nodeRequest{}, fmt.Errorf("field %s: %w", name, err)
+			}
+
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, name, name))
+			header.Set(domain.HeaderContentType, mimeType)
+			part, err := writer.CreatePart(header)
+			if err != nil {
+				return nodeRequest{}, err
+			}
+			if _, err := part.Write(data); err != nil {
+				return nodeRequest{}, err
+			}
+			continue
+		}
+
+		if fields[name].Type == domain.ObjectFieldType {
+			nestedFields, err := fields[name].NestedFields()
+			if err != nil {
+				return nodeRequest{}, err
+			}
+			nested, _ := value.(map[string]interface{})
+			if value, err = s.inlineFiles(ctx, nestedFields, nested); err != nil {
+				return nodeRequest{}, err
+			}
+		}
+
+		text, err := formValue(value)
+		if err != nil {
+			return nodeRequest{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		if err := writer.WriteField(name, text); err != nil {
+			return nodeRequest{}, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nodeRequest{}, err
+	}
+
+	return nodeRequest{body: buffer.Bytes(), contentType: writer.FormDataContentType()}, nil
+}
+
+// fileContent содержимое поля file: файл из хранилища по ссылке или текст, если ссылки нет
+func (s *service) fileContent(ctx context.Context, value interface{}) ([]byte, string, error) {
+	text, _ := value.(string)
+	key, ok := domain.BlobKey(text)
+	if !ok {
+		return []byte(text), "text/plain; charset=utf-8", nil
+	}
+
+	data, mimeType, err := s.blobAdapter.Get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	if mimeType == "" {
+		mimeType = domain.OctetStreamContentType
+	}
+
+	return data, mimeType, nil
+}
+
+// encodeForm application/x-www-form-urlencoded, файлы в этом формате не передаются
+func encodeForm(fields map[string]domain.BodyField, filled map[string]interface{}) (nodeRequest, error) {
+	values := url.Values{}
+	for name, value := range filled {
+		if fields[name].Type == domain.FileFieldType {
+			return nodeRequest{}, fmt.Errorf("field %s: files can't be sent as %s", name, domain.FormContentType)
+		}
+
+		text, err := formValue(value)
+		if err != nil {
+			return nodeRequest{}, fmt.Errorf("field %s: %w", name, err)
+		}
+		values.Set(name, text)
+	}
+
+	return nodeRequest{body: []byte(values.Encode()), contentType: domain.FormContentType}, nil
+}
+
+// formValue строки передаются как есть, остальные значения - json текстом
+func formValue(value interface{}) (string, error) {
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	return string(raw), nil
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,171 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+	"testing"
+
+	blobAdpt "github.com/warehouse/ai-service/internal/adapter/blob"
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func newTestBlobAdapter(t *testing.T) blobAdpt.Adapter {
+	t.Helper()
+
+	adapter, err := blobAdpt.NewAdapter(config.Blob{Dir: t.TempDir(), SignKey: "secret"})
+	if err != nil {
+		t.Fatalf("blob.NewAdapter() unexpected error: %v", err)
+	}
+
+	return adapter
+}
+
+type testPart struct {
+	filename    string
+	contentType string
+	data        string
+}
+
+func readMultipart(t *testing.T, req nodeRequest) ([]string, map[string]testPart) {
+	t.Helper()
+
+	mediaType, params, err := mime.ParseMediaType(req.contentType)
+	if err != nil || mediaType != domain.MultipartContentType {
+		t.Fatalf("content type = %q, want %s", req.contentType, domain.MultipartContentType)
+	}
+
+	names := []string{}
+	parts := map[string]testPart{}
+	reader := multipart.NewReader(bytes.NewReader(req.body), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() unexpected error: %v", err)
+		}
+
+		data, _ := io.ReadAll(part)
+		names = append(names, part.FormName())
+		parts[part.FormName()] = testPart{filename: part.FileName(), contentType: part.Header.Get(domain.HeaderContentType), data: string(data)}
+	}
+
+	return names, parts
+}
+
+func TestEncodeNodeBody(t *testing.T) {
+	blobs := newTestBlobAdapter(t)
+	key, err := blobs.Put(context.Background(), []byte{0x89, 'P', 'N', 'G'}, "image/png")
+	if err != nil {
+		t.Fatalf("Put() unexpected error: %v", err)
+	}
+	s := &service{blobAdapter: blobs}
+
+	fields := map[string]domain.BodyField{
+		"prompt": {Type: domain.PromptFieldType},
+		"count":  {Type: domain.ConstFieldType},
+		"image":  {Type: domain.FileFieldType},
+		"note":   {Type: domain.FileFieldType},
+		"meta": {Type: domain.ObjectFieldType, Values: []interface{}{map[string]interface{}{
+			"thumb": map[string]interface{}{"type": "file"},
+			"tag":   map[string]interface{}{"type": "data"},
+		}}},
+	}
+	filled := map[string]interface{}{
+		"prompt": "describe",
+		"count":  json.Number("2"),
+		"image":  domain.BlobRef(key),
+		"note":   "plain text",
+		"meta":   map[string]interface{}{"thumb": domain.BlobRef(key), "tag": "x"},
+	}
+	png := base64.StdEncoding.EncodeToString([]byte{0x89, 'P', 'N', 'G'})
+
+	t.Run("json inlines files as base64", func(t *testing.T) {
+		req, err := s.encodeNodeBody(context.Background(), domain.Node{}, fields, filled)
+		if err != nil {
+			t.Fatalf("encodeNodeBody() unexpected error: %v", err)
+		}
+		if req.contentType != domain.JsonContentType {
+			t.Fatalf("content type = %q, want json", req.contentType)
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(req.body, &body); err != nil {
+			t.Fatalf("body is not json: %s", req.body)
+		}
+		meta, _ := body["meta"].(map[string]interface{})
+		if body["image"] != png || meta["thumb"] != png || body["note"] != "plain text" || body["count"] != 2.0 {
+			t.Fatalf("body = %s, want files inlined as base64", req.body)
+		}
+	})
+
+	t.Run("multipart sends files as parts", func(t *testing.T) {
+		node := domain.Node{RequestMime: "multipart/form-data"}
+		req, err := s.encodeNodeBody(context.Background(), node, fields, filled)
+		if err != nil {
+			t.Fatalf("encodeNodeBody() unexpected error: %v", err)
+		}
+
+		names, parts := readMultipart(t, req)
+		if strings.Join(names, ",") != "count,image,meta,note,prompt" {
+			t.Fatalf("parts = %v, want sorted by name", names)
+		}
+		if got := parts["image"]; got.filename != "image" || got.contentType != "image/png" || got.data != "\x89PNG" {
+			t.Fatalf("image part = %+v, want png file", got)
+		}
+		if got := parts["note"]; got.filename != "note" || !strings.HasPrefix(got.contentType, "text/plain") || got.data != "plain text" {
+			t.Fatalf("note part = %+v, want text file", got)
+		}
+		if got := parts["meta"]; got.filename != "" || got.data != `{"tag":"x","thumb":"`+png+`"}` {
+			t.Fatalf("meta part = %+v, want json text with inlined file", got)
+		}
+		if parts["count"].data != "2" || parts["prompt"].data != "describe" {
+			t.Fatalf("parts = %+v, want plain values", parts)
+		}
+	})
+
+	t.Run("form encodes values", func(t *testing.T) {
+		node := domain.Node{RequestMime: "application/x-www-form-urlencoded; charset=utf-8"}
+		formFields := map[string]domain.BodyField{"prompt": fields["prompt"], "count": fields["count"]}
+		req, err := s.encodeNodeBody(context.Background(), node, formFields, map[string]interface{}{"prompt": "a&b=c", "count": json.Number("2")})
+		if err != nil {
+			t.Fatalf("encodeNodeBody() unexpected error: %v", err)
+		}
+
+		values, err := url.ParseQuery(string(req.body))
+		if err != nil || req.contentType != domain.FormContentType || values.Get("prompt") != "a&b=c" || values.Get("count") != "2" {
+			t.Fatalf("body = %q (%s), want form values", req.body, req.contentType)
+		}
+	})
+
+	t.Run("form rejects files", func(t *testing.T) {
+		node := domain.Node{RequestMime: domain.FormContentType}
+		if _, err := s.encodeNodeBody(context.Background(), node, fields, filled); err == nil || !strings.Contains(err.Error(), "files can't be sent") {
+			t.Fatalf("encodeNodeBody() error = %v, want files error", err)
+		}
+	})
+
+	t.Run("missing blob", func(t *testing.T) {
+		node := domain.Node{RequestMime: domain.MultipartContentType}
+		missing := map[string]interface{}{"image": domain.BlobRef("cu0000000000000000ag-0000000000000000.png")}
+		if _, err := s.encodeNodeBody(context.Background(), node, fields, missing); err == nil || !strings.Contains(err.Error(), "field image") {
+			t.Fatalf("encodeNodeBody() error = %v, want field image error", err)
+		}
+	})
+
+	t.Run("unsupported request mime", func(t *testing.T) {
+		node := domain.Node{RequestMime: "text/xml"}
+		if _, err := s.encodeNodeBody(context.Background(), node, fields, filled); err == nil {
+			t.Fatalf("encodeNodeBody() error = nil, want unsupported request_mime")
+		}
+	})
+}
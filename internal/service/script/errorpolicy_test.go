@@ -0,0 +1,130 @@
+package script
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestRunGraphErrorPolicy(t *testing.T) {
+	upstream := newTestUpstream(t)
+	nodes, presets := testGraphNodes(upstream, "first", "second", "third")
+
+	skip := &domain.ErrorPolicy{Action: domain.SkipErrorAction}
+	substitute := &domain.ErrorPolicy{Action: domain.DefaultErrorAction, Default: "dflt"}
+
+	tests := []struct {
+		name         string
+		script       domain.Script
+		wantErr      string
+		wantOutput   string
+		wantEvents   []string
+		wantFailures []string
+	}{
+		{
+			name: "without policy the error fails the run",
+			script: domain.Script{Graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first"},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+			}}},
+			wantErr: "status 400",
+		},
+		{
+			name: "fail action fails the run",
+			script: domain.Script{Graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first", OnError: &domain.ErrorPolicy{Action: domain.FailErrorAction}},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+			}}},
+			wantErr: "status 400",
+		},
+		{
+			name: "default value goes to dependents",
+			script: domain.Script{Graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first", OnError: substitute},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+			}}},
+			wantOutput: "second(dflt)",
+			wantEvents: []string{
+				"node_started first",
+				"node_result first dfltnode responded with status 400",
+				"node_started second",
+				"node_result second second(dflt)",
+			},
+			wantFailures: []string{"first default"},
+		},
+		{
+			name: "skipped node skips dependents without calls",
+			script: domain.Script{Graph: domain.Graph{Output: "join", Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first", OnError: skip},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+				{Name: "third", NodeId: "third", Inputs: []string{domain.GraphInput}, When: &domain.Condition{Op: domain.EqConditionOp, Value: "never"}},
+				{Name: "join", Kind: domain.JoinGraphNodeKind, Inputs: []string{"second", "third"}, Otherwise: "first"},
+			}}},
+			wantOutput: "",
+			wantEvents: []string{
+				"node_started first",
+				"node_skipped third",
+				"node_skipped first node responded with status 400",
+				"node_skipped second",
+				"node_skipped join",
+			},
+			wantFailures: []string{"first skip"},
+		},
+		{
+			name: "dependent with default substitutes its own value for a failed input",
+			script: domain.Script{Graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first", OnError: skip},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}, OnError: substitute},
+			}}},
+			wantOutput:   "dflt",
+			wantFailures: []string{"first skip"},
+		},
+		{
+			name: "step policy of the old format substitutes the chain result",
+			script: domain.Script{
+				Workflow: map[int]map[int][]string{1: {0: {"first", "second"}}},
+				Options:  domain.ScriptOptions{Steps: map[int]domain.StepOptions{1: {OnError: substitute}}},
+			},
+			wantOutput:   "dflt",
+			wantFailures: []string{"step1.chain0.0 default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{nodeHandler: newTestNodeHandler(t)}
+			tt.script.BodyPresets = presets
+
+			outcome, events, err := runTestGraph(t, context.Background(), s, tt.script, nodes, "fail")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runGraph() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runGraph() unexpected error: %v", err)
+			}
+			if outcome.output != tt.wantOutput {
+				t.Fatalf("output = %q, want %q", outcome.output, tt.wantOutput)
+			}
+
+			if tt.wantEvents != nil {
+				got := eventTrace(events)
+				if strings.Join(got, "\n") != strings.Join(tt.wantEvents, "\n") {
+					t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.wantEvents, "\n"))
+				}
+			}
+
+			failures := make([]string, len(outcome.report.Failures))
+			for i, failure := range outcome.report.Failures {
+				failures[i] = failure.GraphNode + " " + string(failure.Action)
+			}
+			if strings.Join(failures, ",") != strings.Join(tt.wantFailures, ",") {
+				t.Fatalf("failures = %v, want %v", failures, tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package script
+
+import "github.com/warehouse/ai-service/internal/domain"
+
+// runObserver получает события хода выполнения запуска
+type runObserver func(event domain.RunEvent)
+
+func noopObserver(domain.RunEvent) {}
+
+func runResultEvent(run domain.ScriptRun) domain.RunEvent {
+	if run.Status == domain.RunFailed {
+		return domain.RunEvent{Type: domain.RunFailedEvent, RunId: run.Id, Error: run.Error}
+	}
+
+	return domain.RunEvent{Type: domain.RunFinishedEvent, RunId: run.Id, Mime: run.ResultMime, Output: run.Result}
+}
@@ -0,0 +1,174 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// newTestUpstream нода, которая отвечает {"out": "<name>(<input>)"}, а на вход "fail" - ошибкой 400
+func newTestUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Input == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set(domain.HeaderContentType, domain.JsonContentType)
+		_ = json.NewEncoder(w).Encode(map[string]string{"out": fmt.Sprintf("%s(%s)", r.URL.Path[1:], body.Input)})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+// testGraphNodes ноды upstream с указанными айди, узел графа с тем же именем передает ноде свой вход
+func testGraphNodes(upstream *httptest.Server, ids ...string) (map[string]domain.Node, map[string]map[string]interface{}) {
+	nodes := make(map[string]domain.Node, len(ids))
+	presets := make(map[string]map[string]interface{}, len(ids))
+	for _, id := range ids {
+		nodes[id] = domain.Node{
+			Id:                id,
+			Name:              id,
+			Url:               upstream.URL + "/" + id,
+			Method:            http.MethodPost,
+			ResponseDirection: "out",
+			Body:              map[string]domain.BodyField{"input": {Type: domain.DataFieldType}},
+		}
+		presets[id] = map[string]interface{}{"input": ""}
+	}
+
+	return nodes, presets
+}
+
+// runTestGraph выполняет граф сценария и возвращает события запуска
+func runTestGraph(t *testing.T, ctx context.Context, s *service, script domain.Script, nodes map[string]domain.Node, input string) (runOutcome, []domain.RunEvent, error) {
+	t.Helper()
+
+	graph, err := script.ExecutionGraph()
+	if err != nil {
+		t.Fatalf("ExecutionGraph() unexpected error: %v", err)
+	}
+
+	var events []domain.RunEvent
+	run := domain.ScriptRun{Id: "run", EnterData: input}
+	outcome, err := s.runGraph(ctx, run, script, graph, nodes, func(event domain.RunEvent) {
+		events = append(events, event)
+	})
+
+	return outcome, events, err
+}
+
+// eventTrace события в виде "type graph_node output|error"
+func eventTrace(events []domain.RunEvent) []string {
+	trace := make([]string, len(events))
+	for i, event := range events {
+		trace[i] = strings.TrimSpace(fmt.Sprintf("%s %s %s%s", event.Type, event.GraphNode, event.Output, event.Error))
+	}
+
+	return trace
+}
+
+func TestRunGraphEvents(t *testing.T) {
+	upstream := newTestUpstream(t)
+	nodes, presets := testGraphNodes(upstream, "first", "second", "never")
+
+	tests := []struct {
+		name       string
+		graph      domain.Graph
+		input      string
+		wantErr    string
+		wantEvents []string
+	}{
+		{
+			name: "chain of nodes",
+			graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first"},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+			}},
+			input: "x",
+			wantEvents: []string{
+				"node_started first",
+				"node_result first first(x)",
+				"node_started second",
+				"node_result second second(first(x))",
+			},
+		},
+		{
+			name: "skipped node",
+			graph: domain.Graph{Output: "first", Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first"},
+				{Name: "never", NodeId: "never", When: &domain.Condition{Op: domain.EqConditionOp, Value: "y"}},
+			}},
+			input: "x",
+			wantEvents: []string{
+				"node_started first",
+				"node_skipped never",
+				"node_result first first(x)",
+			},
+		},
+		{
+			name: "failed node stops the run",
+			graph: domain.Graph{Nodes: []domain.GraphNode{
+				{Name: "first", NodeId: "first"},
+				{Name: "second", NodeId: "second", Inputs: []string{"first"}},
+			}},
+			input:   "fail",
+			wantErr: "400",
+			wantEvents: []string{
+				"node_started first",
+				"node_result first node responded with status 400",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &service{nodeHandler: newTestNodeHandler(t)}
+			script := domain.Script{Graph: tt.graph, BodyPresets: presets}
+
+			_, events, err := runTestGraph(t, context.Background(), s, script, nodes, tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runGraph() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("runGraph() unexpected error: %v", err)
+			}
+
+			got := eventTrace(events)
+			if strings.Join(got, "\n") != strings.Join(tt.wantEvents, "\n") {
+				t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.wantEvents, "\n"))
+			}
+			for _, event := range events {
+				if event.RunId != "run" {
+					t.Fatalf("event %s run id = %q, want run", event.Type, event.RunId)
+				}
+			}
+		})
+	}
+}
+
+func TestRunResultEvent(t *testing.T) {
+	finished := runResultEvent(domain.ScriptRun{Id: "run", Status: domain.RunSucceeded, Result: "ok", ResultMime: domain.TextContentType})
+	if finished.Type != domain.RunFinishedEvent || finished.Output != "ok" || finished.Mime != domain.TextContentType {
+		t.Fatalf("runResultEvent(succeeded) = %+v", finished)
+	}
+
+	failed := runResultEvent(domain.ScriptRun{Id: "run", Status: domain.RunFailed, Error: "boom", Result: "partial"})
+	if failed.Type != domain.RunFailedEvent || failed.Error != "boom" || failed.Output != "" {
+		t.Fatalf("runResultEvent(failed) = %+v", failed)
+	}
+}
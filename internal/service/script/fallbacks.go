@@ -0,0 +1,65 @@
+package script
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// callNodeWithFallbacks вызывает ноду узла, а если она вернула ошибку, статус не 2xx или ответ без результата -
+// запасные ноды по порядку. Вызовы всех нод попадают в историю под именем узла, если не ответил никто -
+// ошибка содержит причины всех нод
+func (s *service) callNodeWithFallbacks(
+	ctx context.Context,
+	graphNode domain.GraphNode,
+	nodes map[string]domain.Node,
+	script domain.Script,
+	scope templateScope,
+) graphNodeResult {
+	step := graphNode.RunStep(graphNode.Name, graphNode.Position)
+
+	node := nodes[graphNode.NodeId]
+	output, mime, steps, err := s.execNode(ctx, step, node, script.BodyPresets, script.HeaderPresets, scope)
+	result := graphNodeResult{
+		name:     graphNode.Name,
+		nodeName: node.Name,
+		output:   output,
+		mime:     mime,
+		steps:    steps,
+		err:      err,
+	}
+	if err == nil {
+		return result
+	}
+
+	primaryErr := err
+	for _, fallbackId := range graphNode.Fallbacks {
+		// запуск отменен, запасные ноды уже не нужны
+		if ctx.Err() != nil {
+			break
+		}
+
+		fallback := nodes[fallbackId]
+		output, mime, steps, err := s.execNode(ctx, step, fallback, script.BodyPresets, script.HeaderPresets, scope)
+		result.steps = append(result.steps, steps...)
+		if err != nil {
+			result.err = fmt.Errorf("%w; fallback %s: %s", result.err, fallbackId, err.Error())
+			continue
+		}
+
+		result.nodeName = fallback.Name
+		result.output = output
+		result.mime = mime
+		result.err = nil
+		result.fallbacks = []domain.RunFallback{{
+			GraphNode:  graphNode.Name,
+			NodeId:     node.Id,
+			FallbackId: fallback.Id,
+			Reason:     primaryErr.Error(),
+		}}
+		return result
+	}
+
+	return result
+}
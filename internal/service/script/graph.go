@@ -0,0 +1,401 @@
+package script
+
+import (
+	"context"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+type (
+	// graphNodeResult результат узла графа. Вызовы нод возвращаются и при ошибке, чтобы сохранить их в историю
+	graphNodeResult struct {
+		name     string
+		nodeName string
+		output   string
+		mime     string
+		skipped  bool
+		failed   bool // узел пропущен из-за своей ошибки или ошибок входов
+		steps    []domain.RunStep
+		// запасные ноды и ошибки, обработанные по on_error, в том числе внутри вложенного сценария
+		fallbacks []domain.RunFallback
+		failures  []domain.RunFailure
+		handled   error // ошибка узла, которая не завершила запуск
+		err       error
+	}
+
+	// runOutcome итог выполнения графа: результат, вызовы нод для истории и отчет запуска.
+	// При ошибке заполнены только история и отчет
+	runOutcome struct {
+		output string
+		mime   string
+		steps  []domain.RunStep
+		report domain.RunReport
+	}
+
+	// graphRun состояние выполнения графа. Читается и меняется только из горутины runGraph
+	graphRun struct {
+		run   domain.ScriptRun
+		graph map[string]domain.GraphNode
+
+		pending    map[string]int      // сколько зависимостей узла еще не завершено
+		dependents map[string][]string // кто ждет завершения узла
+		results    map[string]graphNodeResult
+	}
+)
+
+func newGraphRun(run domain.ScriptRun, graph domain.Graph) *graphRun {
+	g := &graphRun{
+		run:        run,
+		graph:      make(map[string]domain.GraphNode, len(graph.Nodes)),
+		pending:    make(map[string]int, len(graph.Nodes)),
+		dependents: make(map[string][]string, len(graph.Nodes)),
+		results:    make(map[string]graphNodeResult, len(graph.Nodes)),
+	}
+
+	for _, node := range graph.Nodes {
+		g.graph[node.Name] = node
+		deps := node.Dependencies()
+		g.pending[node.Name] = len(deps)
+		for _, dep := range deps {
+			g.dependents[dep] = append(g.dependents[dep], node.Name)
+		}
+	}
+
+	return g
+}
+
+// roots узлы без зависимостей в порядке объявления
+func (g *graphRun) roots(graph domain.Graph) []string {
+	roots := []string{}
+	for _, node := range graph.Nodes {
+		if g.pending[node.Name] == 0 {
+			roots = append(roots, node.Name)
+		}
+	}
+
+	return roots
+}
+
+// complete сохраняет результат узла и возвращает узлы, у которых готовы все зависимости
+func (g *graphRun) complete(res graphNodeResult) []string {
+	g.results[res.name] = res
+
+	ready := []string{}
+	for _, dependent := range g.dependents[res.name] {
+		g.pending[dependent]--
+		if g.pending[dependent] == 0 {
+			ready = append(ready, dependent)
+		}
+	}
+
+	return ready
+}
+
+func (g *graphRun) output(name string) (graphNodeResult, bool) {
+	if name == domain.GraphInput {
+		return graphNodeResult{name: name, output: g.run.EnterData}, true
+	}
+
+	res, ok := g.results[name]
+	if !ok || res.skipped {
+		return graphNodeResult{}, false
+	}
+
+	return res, true
+}
+
+// input объединенные по порядку результаты входов узла. Узел пропускается, если пропущены все входы
+func (g *graphRun) input(node domain.GraphNode) (graphNodeResult, bool, error) {
+	if len(node.Inputs) == 0 {
+		res, ok := g.output(domain.GraphInput)
+		return res, ok, nil
+	}
+
+	inputs := []domain.MergeInput{}
+	for i, name := range node.Inputs {
+		res, ok := g.output(name)
+		if !ok {
+			continue
+		}
+
+		inputs = append(inputs, domain.MergeInput{Key: node.MergeKey(i), Output: res.output, Mime: res.mime})
+	}
+
+	if len(inputs) == 0 {
+		if node.Otherwise != "" {
+			res, ok := g.output(node.Otherwise)
+			return res, ok, nil
+		}
+
+		failed := true
+		for _, name := range node.Inputs {
+			failed = failed && g.results[name].failed
+		}
+
+		return graphNodeResult{failed: failed}, false, nil
+	}
+
+	output, mime, err := node.Merge.Merge(inputs)
+	if err != nil {
+		return graphNodeResult{}, false, err
+	}
+
+	return graphNodeResult{output: output, mime: mime}, true, nil
+}
+
+// holds проверяет условие узла. Если источник условия был пропущен, условие не выполняется
+func (g *graphRun) holds(cond *domain.Condition, input string) bool {
+	if cond == nil {
+		return true
+	}
+
+	data := input
+	if cond.Source != nil {
+		res, ok := g.output(cond.Source.Node)
+		if !ok {
+			return false
+		}
+		data = res.output
+	}
+
+	return cond.Eval(data)
+}
+
+// scope значения для шаблонов узла. Результаты копируются, потому что узел выполняется в отдельной горутине
+func (g *graphRun) scope(script domain.Script, graph domain.Graph, input graphNodeResult) templateScope {
+	outputs := make(map[string]string, len(g.results))
+	mimes := make(map[string]string, len(g.results))
+	for name, res := range g.results {
+		if !res.skipped {
+			outputs[name] = res.output
+			mimes[name] = res.mime
+		}
+	}
+
+	return templateScope{
+		graph:   graph,
+		input:   g.run.EnterData,
+		data:    input.output,
+		mime:    input.mime,
+		vars:    script.Options.Vars,
+		outputs: outputs,
+		mimes:   mimes,
+		files:   g.run.Files,
+		literal: !script.Options.Templates,
+	}
+}
+
+func (g *graphRun) event(eventType domain.RunEventType, node domain.GraphNode, res graphNodeResult) domain.RunEvent {
+	event := domain.RunEvent{
+		Type:      eventType,
+		RunId:     g.run.Id,
+		Step:      node.Step,
+		Chain:     node.Chain,
+		GraphNode: node.Name,
+		NodeName:  res.nodeName,
+		Mime:      res.mime,
+		Output:    res.output,
+	}
+
+	if res.err != nil {
+		event.Error = res.err.Error()
+	}
+	if res.handled != nil {
+		event.Error = res.handled.Error()
+	}
+
+	return event
+}
+
+// recoverNode применяет к ошибке узла его политику on_error, если она не дает ошибке завершить запуск
+func recoverNode(node domain.GraphNode, res graphNodeResult) graphNodeResult {
+	if !node.OnError.Handles() {
+		return res
+	}
+
+	res.failures = append(res.failures, domain.RunFailure{
+		GraphNode: node.Name,
+		Step:      node.Step,
+		Chain:     node.Chain,
+		Action:    node.OnError.Action,
+		Error:     res.err.Error(),
+	})
+	res.handled = res.err
+	res.err = nil
+
+	return withErrorPolicy(node, res)
+}
+
+// withErrorPolicy результат упавшего узла: значение по умолчанию или пропуск
+func withErrorPolicy(node domain.GraphNode, res graphNodeResult) graphNodeResult {
+	if node.OnError.Substitutes() && !node.PassError {
+		res.output = node.OnError.Default
+		res.mime = ""
+		return res
+	}
+
+	res.output = ""
+	res.skipped = true
+	res.failed = true
+	return res
+}
+
+// runGraph выполняет граф сценария: узел запускается, как только завершены все его зависимости.
+// Ошибка любого узла отменяет остальные, вызовы нод возвращаются и при ошибке, чтобы сохранить их в историю.
+// observe вызывается только из горутины runGraph, поэтому обработчику событий не нужна синхронизация:
+// дельты стримящей ноды узла-результата тоже передаются в нее через канал
+func (s *service) runGraph(
+	ctx context.Context,
+	run domain.ScriptRun,
+	script domain.Script,
+	graph domain.Graph,
+	nodes map[string]domain.Node,
+	observe runObserver,
+) (runOutcome, error) {
+	outputName, err := graph.OutputNode()
+	if err != nil {
+		return runOutcome{}, err
+	}
+
+	// при ошибке одного узла отменяем все выполняющиеся
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g := newGraphRun(run, graph)
+	resCh := make(chan graphNodeResult)
+	deltaCh := make(chan domain.RunEvent)
+	running := 0
+
+	streamName, streams := graph.StreamNode()
+	_, dry := dryRunFrom(ctx)
+
+	outcome := runOutcome{steps: []domain.RunStep{}}
+	var runErr error
+
+	ready := g.roots(graph)
+	for {
+		for len(ready) != 0 && runErr == nil {
+			node := g.graph[ready[0]]
+			ready = ready[1:]
+
+			input, ok, err := g.input(node)
+			if err != nil {
+				res := graphNodeResult{name: node.Name, err: err}
+				observe(g.event(domain.NodeResultEvent, node, res))
+				runErr = err
+				cancel()
+				continue
+			}
+
+			// входы пропущены из-за ошибок, узел тоже считается упавшим
+			if !ok && input.failed {
+				res := withErrorPolicy(node, graphNodeResult{name: node.Name})
+				observe(g.event(resultEventType(res), node, res))
+				ready = append(ready, g.complete(res)...)
+				continue
+			}
+
+			// пробный запуск проходит все ветки, условия на заглушках не имеют смысла
+			if !ok || (!dry && !g.holds(node.When, input.output)) {
+				res := graphNodeResult{name: node.Name, skipped: true}
+				observe(g.event(domain.NodeSkippedEvent, node, res))
+				ready = append(ready, g.complete(res)...)
+				continue
+			}
+
+			if node.IsJoin() {
+				res := graphNodeResult{name: node.Name, output: input.output, mime: input.mime}
+				observe(g.event(domain.NodeResultEvent, node, res))
+				ready = append(ready, g.complete(res)...)
+				continue
+			}
+
+			observe(g.event(domain.NodeStartedEvent, node, graphNodeResult{nodeName: nodes[node.NodeId].Name}))
+			nodeCtx := ctx
+			if streams && node.Name == streamName {
+				deltaEvent := g.event(domain.NodeDeltaEvent, node, graphNodeResult{nodeName: nodes[node.NodeId].Name, mime: domain.TextContentType})
+				nodeCtx = withStreamDeltas(ctx, func(delta string) {
+					event := deltaEvent
+					event.Output = delta
+					deltaCh <- event
+				})
+			}
+
+			running++
+			go func(ctx context.Context, node domain.GraphNode, scope templateScope) {
+				resCh <- s.callGraphNode(ctx, node, nodes, script, scope)
+			}(nodeCtx, node, g.scope(script, graph, input))
+		}
+
+		if running == 0 {
+			break
+		}
+
+		var res graphNodeResult
+		select {
+		case event := <-deltaCh:
+			observe(event)
+			continue
+		case res = <-resCh:
+		}
+		running--
+
+		// после отмены запуска ошибки узлов уже не обрабатываются политикой
+		node := g.graph[res.name]
+		if res.err != nil && runErr == nil && ctx.Err() == nil {
+			res = recoverNode(node, res)
+		}
+
+		outcome.steps = append(outcome.steps, res.steps...)
+		outcome.report.Fallbacks = append(outcome.report.Fallbacks, res.fallbacks...)
+		outcome.report.Failures = append(outcome.report.Failures, res.failures...)
+		observe(g.event(resultEventType(res), node, res))
+
+		if res.err != nil {
+			if runErr == nil {
+				runErr = res.err
+				cancel()
+			}
+			continue
+		}
+
+		ready = append(ready, g.complete(res)...)
+	}
+
+	if runErr != nil {
+		return outcome, runErr
+	}
+
+	// результат пропущенного узла - пустая строка
+	res, _ := g.output(outputName)
+	outcome.output = res.output
+	outcome.mime = res.mime
+	return outcome, nil
+}
+
+func resultEventType(res graphNodeResult) domain.RunEventType {
+	if res.skipped {
+		return domain.NodeSkippedEvent
+	}
+
+	return domain.NodeResultEvent
+}
+
+// callGraphNode выполняет узел графа, который вызывает ноды
+func (s *service) callGraphNode(
+	ctx context.Context,
+	graphNode domain.GraphNode,
+	nodes map[string]domain.Node,
+	script domain.Script,
+	scope templateScope,
+) graphNodeResult {
+	if graphNode.IsMap() {
+		return s.callMapNode(ctx, graphNode, nodes, script, scope)
+	}
+
+	if graphNode.IsScript() {
+		return s.callScriptNode(ctx, graphNode, scope)
+	}
+
+	return s.callNodeWithFallbacks(ctx, graphNode, nodes, script, scope)
+}
@@ -0,0 +1,119 @@
+package script
+
+import (
+	"context"
+	"strings"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+	"github.com/warehouse/ai-service/internal/repository/models"
+)
+
+const redactedValue = "***"
+
+// Части имен заголовков, значения которых нельзя сохранять в истории как есть
+var sensitiveHeaderParts = []string{"authorization", "key", "token", "secret", "password", "cookie"}
+
+func redactHeaders(node domain.Node, headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		redacted[name] = value
+
+		if node.ApiKey != "" && strings.Contains(value, node.ApiKey) {
+			redacted[name] = redactedValue
+			continue
+		}
+
+		if sensitiveHeader(name) {
+			redacted[name] = redactedValue
+		}
+	}
+
+	return redacted
+}
+
+func sensitiveHeader(name string) bool {
+	lowerName := strings.ToLower(name)
+	for _, part := range sensitiveHeaderParts {
+		if strings.Contains(lowerName, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *service) saveRunSteps(ctx context.Context, runId string, steps []domain.RunStep) *errors.Error {
+	if len(steps) == 0 {
+		return nil
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	modelSteps := make([]models.RunStep, len(steps))
+	for i, step := range steps {
+		modelSteps[i] = step.ToModel(runId)
+	}
+
+	if err := s.stepsRepo.CreateBatch(ctx, tx, modelSteps); err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
+
+func (s *service) ListRuns(ctx context.Context, acc *domain.Account, limit, offset int) ([]domain.ScriptRun, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	list, err := s.runsRepo.GetByAuthor(ctx, tx, acc.Id, limit, offset)
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+
+	runs := make([]domain.ScriptRun, len(list))
+	for i, run := range list {
+		runs[i], err = domain.ScriptRun{}.FromModel(run)
+		if err != nil {
+			return nil, errors.WD(errors.ParseError, err)
+		}
+	}
+
+	return runs, nil
+}
+
+func (s *service) GetRunHistory(ctx context.Context, acc *domain.Account, id string) (domain.ScriptRun, []domain.RunStep, *errors.Error) {
+	run, e := s.GetRun(ctx, acc, id)
+	if e != nil {
+		return domain.ScriptRun{}, nil, e
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.ScriptRun{}, nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	list, err := s.stepsRepo.GetByRunId(ctx, tx, run.Id)
+	if err != nil {
+		return domain.ScriptRun{}, nil, errors.DatabaseError(err)
+	}
+
+	steps := make([]domain.RunStep, len(list))
+	for i, step := range list {
+		steps[i] = domain.RunStep{}.FromModel(step)
+	}
+
+	return run, steps, nil
+}
@@ -0,0 +1,161 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/warehouse/ai-service/internal/domain"
+
+	"github.com/thedevsaddam/gojsonq/v2"
+)
+
+// mapItem элемент массива map и его mime
+type mapItem struct {
+	data string
+	mime string
+}
+
+// mapItems достает массив для map из входа узла. Элементы-строки передаются в ноды как есть, остальные - json.
+// Массив длиннее max_items не обрабатывается
+func mapItems(options domain.MapOptions, input string) ([]mapItem, error) {
+	var raw interface{}
+	if options.ItemsPath == "" {
+		if err := json.Unmarshal([]byte(input), &raw); err != nil {
+			return nil, fmt.Errorf("map input is not json array: %s", err.Error())
+		}
+	} else {
+		jq := gojsonq.New().FromString(input)
+		raw = jq.Find(options.ItemsPath)
+		if err := jq.Error(); err != nil {
+			return nil, fmt.Errorf("map input: %s", err.Error())
+		}
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("map input: value by path %q is not array", options.ItemsPath)
+	}
+	if len(values) > options.Limit() {
+		return nil, fmt.Errorf("map input: %d items, max_items is %d", len(values), options.Limit())
+	}
+
+	items := make([]mapItem, len(values))
+	for i, value := range values {
+		if str, ok := value.(string); ok {
+			items[i] = mapItem{data: str, mime: domain.TextContentType}
+			continue
+		}
+
+		item, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = mapItem{data: string(item), mime: domain.JsonContentType}
+	}
+
+	return items, nil
+}
+
+// callMapNode выполняет цепочку нод узла для каждого элемента массива, не больше Concurrency элементов одновременно.
+// Ошибка на любом элементе отменяет остальные. Вызовы нод в истории помечаются именем узла с номером элемента.
+// Результат - json массив, json результаты элементов попадают в него значениями, а не строками
+func (s *service) callMapNode(
+	ctx context.Context,
+	graphNode domain.GraphNode,
+	nodes map[string]domain.Node,
+	script domain.Script,
+	scope templateScope,
+) graphNodeResult {
+	result := graphNodeResult{name: graphNode.Name, mime: domain.JsonContentType}
+
+	options := domain.MapOptions{}
+	if graphNode.Map != nil {
+		options = *graphNode.Map
+	}
+
+	items, err := mapItems(options, scope.data)
+	// в пробном запуске вход может быть заглушкой, тогда цепочка нод заполняется для одного элемента-заглушки
+	if _, dry := dryRunFrom(ctx); dry && err != nil {
+		items, err = []mapItem{{data: fmt.Sprintf("<item of %s>", graphNode.Name), mime: domain.TextContentType}}, nil
+	}
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	concurrency := options.Concurrency
+	if concurrency == 0 || concurrency > domain.MaxMapConcurrency {
+		concurrency = domain.MaxMapConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]interface{}, len(items))
+	itemSteps := make([][]domain.RunStep, len(items))
+
+	// причиной считаем первую ошибку, а не отмену остальных элементов из-за нее
+	var firstErr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// горутина элемента запускается только после того, как он занял слот, поэтому одновременно
+	// живут не больше concurrency горутин
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+items:
+	for i, item := range items {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break items
+		}
+
+		wg.Add(1)
+		go func(i int, item mapItem) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			name := fmt.Sprintf("%s[%d]", graphNode.Name, i)
+			for position, nodeId := range graphNode.Each {
+				output, mime, steps, err := s.execNode(ctx, graphNode.RunStep(name, position), nodes[nodeId], script.BodyPresets, script.HeaderPresets, scope.withData(item.data, item.mime))
+				itemSteps[i] = append(itemSteps[i], steps...)
+				if err != nil {
+					fail(fmt.Errorf("item %d: %w", i, err))
+					return
+				}
+
+				item = mapItem{data: output, mime: mime}
+			}
+
+			outputs[i] = domain.TypedValue(item.data, item.mime)
+		}(i, item)
+	}
+	wg.Wait()
+
+	for i := range items {
+		result.steps = append(result.steps, itemSteps[i]...)
+	}
+
+	if firstErr != nil {
+		result.err = firstErr
+		return result
+	}
+
+	raw, err := json.Marshal(outputs)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.output = string(raw)
+
+	return result
+}
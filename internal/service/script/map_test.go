@@ -0,0 +1,161 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestMapItems(t *testing.T) {
+	tests := []struct {
+		name    string
+		options domain.MapOptions
+		input   string
+		want    []mapItem
+		wantErr string
+	}{
+		{
+			name:  "strings and json values",
+			input: `["a", 1, {"b": true}]`,
+			want: []mapItem{
+				{data: "a", mime: domain.TextContentType},
+				{data: "1", mime: domain.JsonContentType},
+				{data: `{"b":true}`, mime: domain.JsonContentType},
+			},
+		},
+		{
+			name:    "items path",
+			options: domain.MapOptions{ItemsPath: "data.items"},
+			input:   `{"data": {"items": ["x", "y"]}}`,
+			want:    []mapItem{{data: "x", mime: domain.TextContentType}, {data: "y", mime: domain.TextContentType}},
+		},
+		{name: "empty array", input: `[]`, want: []mapItem{}},
+		{name: "not json", input: `a, b`, wantErr: "map input is not json array"},
+		{name: "not array", options: domain.MapOptions{ItemsPath: "data"}, input: `{"data": "x"}`, wantErr: "is not array"},
+		{name: "more than max items", options: domain.MapOptions{MaxItems: 2}, input: `[1, 2, 3]`, wantErr: "3 items, max_items is 2"},
+		{name: "exactly max items", options: domain.MapOptions{MaxItems: 2}, input: `[1, 2]`, want: []mapItem{{data: "1", mime: domain.JsonContentType}, {data: "2", mime: domain.JsonContentType}}},
+		{name: "default max items", input: "[" + strings.Repeat("0,", domain.DefaultMapMaxItems) + "0]", wantErr: fmt.Sprintf("max_items is %d", domain.DefaultMapMaxItems)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapItems(tt.options, tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("mapItems() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mapItems() unexpected error: %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("mapItems() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallMapNode(t *testing.T) {
+	tests := []struct {
+		name        string
+		items       int
+		concurrency int
+		failItem    int // -1 - все элементы успешны
+		wantOutput  string
+		wantErr     string
+	}{
+		{name: "results keep item order", items: 12, concurrency: 3, failItem: -1, wantOutput: `["r0","r1","r2","r3","r4","r5","r6","r7","r8","r9","r10","r11"]`},
+		{name: "one at a time", items: 4, concurrency: 1, failItem: -1, wantOutput: `["r0","r1","r2","r3"]`},
+		{name: "failed item stops the map", items: 40, concurrency: 2, failItem: 1, wantErr: "item 1:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inFlight, maxInFlight, calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				current := inFlight.Add(1)
+				defer inFlight.Add(-1)
+				for {
+					seen := maxInFlight.Load()
+					if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+						break
+					}
+				}
+
+				var body struct {
+					Item string `json:"item"`
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				// поздние элементы отвечают быстрее, чтобы порядок результатов не совпадал с порядком ответов
+				item, _ := strconv.Atoi(body.Item)
+				time.Sleep(time.Duration(tt.items-item) * time.Millisecond)
+				if body.Item == fmt.Sprint(tt.failItem) {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				w.Header().Set(domain.HeaderContentType, domain.JsonContentType)
+				_, _ = fmt.Fprintf(w, `{"out":"r%s"}`, body.Item)
+			}))
+			defer srv.Close()
+
+			items := make([]string, tt.items)
+			for i := range items {
+				items[i] = fmt.Sprintf(`"%d"`, i)
+			}
+
+			s := &service{nodeHandler: newTestNodeHandler(t)}
+			graphNode := domain.GraphNode{
+				Name: "each",
+				Kind: domain.MapGraphNodeKind,
+				Each: []string{"node"},
+				Map:  &domain.MapOptions{Concurrency: tt.concurrency},
+			}
+			nodes := map[string]domain.Node{"node": {
+				Id:                "node",
+				Name:              "node",
+				Url:               srv.URL,
+				Method:            http.MethodPost,
+				ResponseDirection: "out",
+				Body:              map[string]domain.BodyField{"item": {Type: domain.DataFieldType}},
+			}}
+			script := domain.Script{BodyPresets: map[string]map[string]interface{}{"node": {"item": ""}}}
+			scope := templateScope{data: "[" + strings.Join(items, ",") + "]", mime: domain.JsonContentType}
+
+			res := s.callMapNode(context.Background(), graphNode, nodes, script, scope)
+
+			if got := maxInFlight.Load(); got > int32(tt.concurrency) {
+				t.Fatalf("max concurrent calls = %d, want at most %d", got, tt.concurrency)
+			}
+			if tt.wantErr != "" {
+				if res.err == nil || !strings.Contains(res.err.Error(), tt.wantErr) {
+					t.Fatalf("callMapNode() error = %v, want %q", res.err, tt.wantErr)
+				}
+				if got := calls.Load(); got >= int32(tt.items) {
+					t.Fatalf("upstream calls = %d, want the failure to stop the rest of %d items", got, tt.items)
+				}
+				return
+			}
+			if res.err != nil {
+				t.Fatalf("callMapNode() unexpected error: %v", res.err)
+			}
+			if res.output != tt.wantOutput {
+				t.Fatalf("callMapNode() output = %s, want %s", res.output, tt.wantOutput)
+			}
+			if len(res.steps) != tt.items || res.steps[0].GraphNode != "each[0]" {
+				t.Fatalf("callMapNode() steps = %d, first %q, want %d steps starting with each[0]", len(res.steps), res.steps[0].GraphNode, tt.items)
+			}
+		})
+	}
+}
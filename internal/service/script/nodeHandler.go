@@ -2,68 +2,158 @@ package script
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
 	"time"
 
+	cacheAdpt "github.com/warehouse/ai-service/internal/adapter/cache"
+	cassetteAdpt "github.com/warehouse/ai-service/internal/adapter/cassette"
+	ratelimitAdpt "github.com/warehouse/ai-service/internal/adapter/ratelimit"
 	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
 )
 
 type (
 	nodeHandler struct {
-		requestTimeout time.Duration
+		client         *http.Client
+		egress         *egress.Policy
+		defaultTimeout time.Duration
+		breakers       breaker.Registry
+		cassettes      cassetteAdpt.Adapter
+		rateLimits     ratelimitAdpt.Adapter
+		cache          cacheAdpt.Adapter
+	}
+
+	nodeResponse struct {
+		Body       []byte
+		Streamed   string // склейка дельт потокового ответа
+		Header     http.Header
+		StatusCode int
+		StartedAt  time.Time
+		Latency    time.Duration
+		CacheHit   bool // ответ взят из кеша ноды
+		Replayed   bool // ответ воспроизведен из кассеты, нода не вызывалась
 	}
 )
 
+// Один клиент на весь сервис, чтобы соединения к нодам переиспользовались между запусками
 func newNodeHandler(
-	requestTimeout time.Duration,
-) nodeHandler {
-	return nodeHandler{
-		requestTimeout: requestTimeout,
+	client *http.Client,
+	egressPolicy *egress.Policy,
+	defaultTimeout time.Duration,
+	breakers breaker.Registry,
+	cassettes cassetteAdpt.Adapter,
+	rateLimits ratelimitAdpt.Adapter,
+	cache cacheAdpt.Adapter,
+) *nodeHandler {
+	return &nodeHandler{
+		client:         client,
+		egress:         egressPolicy,
+		defaultTimeout: defaultTimeout,
+		breakers:       breakers,
+		cassettes:      cassettes,
+		rateLimits:     rateLimits,
+		cache:          cache,
 	}
 }
 
 // В редакторе скрипта, мы уже вносим необходимые настройки, поэтому мы сохраняем json запроса, в который нужно лишь подставить пользовательский запрос/промпт
 // Для этого запроса надо сделать путь как с ответом
-// TODO: Подумать над контекстами (10 секунд 100% мало и надо сделать отдельный чисто для запросов к иишкам)
+// Каждая попытка ограничена таймаутом ноды, а отмена ctx запуска сразу обрывает запрос
 func (s *nodeHandler) makeHTTPRequest(
+	ctx context.Context,
 	node domain.Node,
 	headers map[string]string,
-	request []byte,
-) ([]byte, error) {
-	var buffer bytes.Buffer
-	httpClient := http.Client{}
+	request nodeRequest,
+) (nodeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, node.Timeout(s.defaultTimeout))
+	defer cancel()
+
+	// политика могла измениться после создания ноды, адрес соединения дополнительно проверяет клиент
+	if err := s.egress.CheckURL(node.Url); err != nil {
+		return nodeResponse{}, err
+	}
 
 	url, err := url.Parse(node.Url)
 	if err != nil {
-		return nil, err
+		return nodeResponse{}, err
 	}
 
-	if err := json.Compact(&buffer, request); err != nil {
-		return nil, err
+	body, err := requestBody(request)
+	if err != nil {
+		return nodeResponse{}, err
 	}
 
-	req, err := http.NewRequest(string(node.Method), url.String(), &buffer)
+	req, err := http.NewRequestWithContext(ctx, string(node.Method), url.String(), bytes.NewReader(body))
 	if err != nil {
-		return nil, err
+		return nodeResponse{}, err
 	}
 
+	// json пресеты заголовков могут уточнить, а для multipart и form Content-Type задает кодировщик из-за boundary
+	if request.contentType == domain.JsonContentType {
+		req.Header.Set(domain.HeaderContentType, request.contentType)
+	}
 	for k, v := range headers {
 		req.Header.Set(k, v)
 	}
+	if request.contentType != domain.JsonContentType {
+		req.Header.Set(domain.HeaderContentType, request.contentType)
+	}
 
-	res, err := httpClient.Do(req)
+	startedAt := time.Now()
+	res, hash, err := s.send(ctx, node, req, body)
 	if err != nil {
-		return nil, err
+		return nodeResponse{StartedAt: startedAt, Latency: time.Since(startedAt)}, err
 	}
 	defer res.Body.Close()
 
-	resp, err := io.ReadAll(res.Body)
+	response := nodeResponse{
+		Header:     res.Header,
+		StatusCode: res.StatusCode,
+		StartedAt:  startedAt,
+	}
+	if cassette, ok := cassetteFrom(ctx); ok && cassette.Mode == domain.CassetteReplay {
+		response.Replayed = true
+	}
+
+	// неуспешный ответ стримящей ноды - обычная ошибка, ее не разбираем на события
+	succeeded := res.StatusCode >= 200 && res.StatusCode < 300
+	if node.Stream.Enabled() && succeeded {
+		response.Body, response.Streamed, err = readStream(res.Body, node.Stream, streamDeltas(ctx))
+	} else {
+		response.Body, err = io.ReadAll(res.Body)
+	}
+	response.Latency = time.Since(startedAt)
 	if err != nil {
+		return response, err
+	}
+
+	// записываются и неуспешные ответы, чтобы воспроизведение повторило и ошибки ноды
+	if err := s.record(ctx, node, hash, response); err != nil {
+		return response, err
+	}
+
+	if !succeeded {
+		return response, statusError{code: res.StatusCode}
+	}
+
+	return response, nil
+}
+
+// requestBody тело запроса в том виде, в котором оно уходит в ноду: json без пробелов
+func requestBody(request nodeRequest) ([]byte, error) {
+	if request.contentType != domain.JsonContentType {
+		return request.body, nil
+	}
+
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, request.body); err != nil {
 		return nil, err
 	}
 
-	return resp, nil
+	return buffer.Bytes(), nil
 }
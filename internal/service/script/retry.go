@@ -0,0 +1,191 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
+)
+
+// statusError нода ответила неуспешным http статусом
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("node responded with status %d", e.code)
+}
+
+// callNode выполняет запрос к ноде с повторами по ее политике, onAttempt вызывается после каждой попытки
+func (h *nodeHandler) callNode(
+	ctx context.Context,
+	node domain.Node,
+	headers map[string]string,
+	request nodeRequest,
+	onAttempt func(attempt int, res nodeResponse, err error),
+) (nodeResponse, error) {
+	policy := node.RetryPolicy
+
+	// повторный запрос отдается из кеша без очереди, брейкера и обращения к ноде
+	key := h.cacheKey(ctx, node, headers, request)
+	if res, ok := h.cached(ctx, node, key); ok {
+		onAttempt(1, res, nil)
+		return res, nil
+	}
+
+	// дельты, уже отправленные клиенту, не отозвать: повтор отправил бы ответ заново поверх оборванного,
+	// поэтому после первой дельты попытка не повторяется
+	emitted := false
+	if onDelta, ok := ctx.Value(streamDeltasCtxKey{}).(func(delta string)); ok {
+		ctx = withStreamDeltas(ctx, func(delta string) {
+			emitted = true
+			onDelta(delta)
+		})
+	}
+
+	for attempt := 1; ; attempt++ {
+		// очередь к ноде ждем до брейкера, чтобы не занимать пробные запросы полуоткрытого брейкера
+		if err := h.waitRateLimit(ctx, node); err != nil {
+			err = fmt.Errorf("node %s: %w", node.Name, err)
+			onAttempt(attempt, nodeResponse{StartedAt: time.Now()}, err)
+			return nodeResponse{}, err
+		}
+
+		done, err := h.breakers.Acquire(ctx, node.Id)
+		if err != nil {
+			// брейкер разомкнут или его состояние недоступно, апстрим не трогаем и не ждем таймаута
+			err = fmt.Errorf("node %s: %w", node.Name, err)
+			onAttempt(attempt, nodeResponse{StartedAt: time.Now()}, err)
+			return nodeResponse{}, err
+		}
+
+		res, err := h.makeHTTPRequest(ctx, node, headers, request)
+		done(breakerOutcome(ctx, err))
+		onAttempt(attempt, res, err)
+		if err == nil {
+			h.store(ctx, node, key, res)
+		}
+
+		// отмена запуска не повод для повтора, даже если выглядит как таймаут
+		if err == nil || ctx.Err() != nil || emitted || attempt >= policy.Attempts() || !retryable(policy, err) {
+			return res, err
+		}
+
+		delay := policy.Backoff(attempt)
+		if after, ok := retryAfter(res.Header); ok {
+			// ждать дольше, чем допускает политика, нельзя: запуск висел бы столько, сколько скажет апстрим,
+			// поэтому такой повтор не делаем и сразу отдаем ошибку
+			if after > policy.MaxBackoff() {
+				return res, fmt.Errorf("%w: retry after %s exceeds max backoff %s", err, after, policy.MaxBackoff())
+			}
+			delay = after
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitRateLimit ждет очереди по ограничению ноды, общему для всех запусков. Воспроизведение из кассеты
+// апстрим не вызывает, поэтому не ограничивается
+func (h *nodeHandler) waitRateLimit(ctx context.Context, node domain.Node) error {
+	if cassette, ok := cassetteFrom(ctx); ok && cassette.Mode == domain.CassetteReplay {
+		return nil
+	}
+
+	return h.rateLimits.Wait(ctx, node.Id, node.RateLimit)
+}
+
+// breakerOutcome ошибки клиента (4xx кроме 429) и отмена запуска не говорят о том, что апстрим лежит
+func breakerOutcome(ctx context.Context, err error) breaker.Outcome {
+	if err == nil {
+		return breaker.Success
+	}
+
+	// промах кассеты и запрет политики egress не связаны с состоянием апстрима
+	if ctx.Err() != nil || errors.Is(err, errCassetteMiss) || errors.Is(err, egress.ErrDenied) {
+		return breaker.Ignored
+	}
+
+	var statusErr statusError
+	if errors.As(err, &statusErr) && statusErr.code < 500 && statusErr.code != http.StatusTooManyRequests {
+		return breaker.Ignored
+	}
+
+	return breaker.Failure
+}
+
+func retryable(policy domain.RetryPolicy, err error) bool {
+	// запрет политики egress повтором не исправить, а ошибка соединения из-за него выглядит как сетевая
+	if errors.Is(err, egress.ErrDenied) {
+		return false
+	}
+
+	var statusErr statusError
+	if errors.As(err, &statusErr) {
+		return policy.RetryableStatus(statusErr.code)
+	}
+
+	kind, ok := networkErrorKind(err)
+	return ok && policy.RetryableError(kind)
+}
+
+func networkErrorKind(err error) (domain.RetryErrorKind, bool) {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return domain.RetryOnDNS, true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return domain.RetryOnTimeout, true
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return domain.RetryOnConnection, true
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return domain.RetryOnConnection, true
+	}
+
+	return "", false
+}
+
+// retryAfter разбирает заголовок Retry-After, который может быть количеством секунд или http датой
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
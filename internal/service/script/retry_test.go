@@ -0,0 +1,251 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	cacheAdpt "github.com/warehouse/ai-service/internal/adapter/cache"
+	ratelimitAdpt "github.com/warehouse/ai-service/internal/adapter/ratelimit"
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRetryable(t *testing.T) {
+	policy := domain.RetryPolicy{
+		MaxAttempts:   3,
+		RetryStatuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+		RetryErrors:   []domain.RetryErrorKind{domain.RetryOnTimeout, domain.RetryOnConnection},
+	}
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "retryable status", err: statusError{code: http.StatusTooManyRequests}, want: true},
+		{name: "wrapped retryable status", err: fmt.Errorf("node a: %w", statusError{code: http.StatusServiceUnavailable}), want: true},
+		{name: "other status", err: statusError{code: http.StatusInternalServerError}, want: false},
+		{name: "client error", err: statusError{code: http.StatusBadRequest}, want: false},
+		{name: "timeout", err: timeoutError{}, want: true},
+		{name: "connection refused", err: dial, want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "unexpected eof", err: io.ErrUnexpectedEOF, want: true},
+		{name: "dns is not in policy", err: &net.DNSError{Err: "no such host", Name: "node.local"}, want: false},
+		{name: "egress denied", err: fmt.Errorf("dial: %w", egress.ErrDenied), want: false},
+		{name: "unknown error", err: fmt.Errorf("bad request body"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(policy, tt.err); got != tt.want {
+				t.Fatalf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkErrorKind(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   domain.RetryErrorKind
+		wantOk bool
+	}{
+		{name: "dns", err: &net.DNSError{Err: "no such host"}, want: domain.RetryOnDNS, wantOk: true},
+		{name: "dns timeout is dns", err: &net.DNSError{Err: "timeout", IsTimeout: true}, want: domain.RetryOnDNS, wantOk: true},
+		{name: "timeout", err: fmt.Errorf("call: %w", timeoutError{}), want: domain.RetryOnTimeout, wantOk: true},
+		{name: "refused", err: syscall.ECONNREFUSED, want: domain.RetryOnConnection, wantOk: true},
+		{name: "eof", err: io.EOF, want: domain.RetryOnConnection, wantOk: true},
+		{name: "op error", err: &net.OpError{Op: "read", Err: fmt.Errorf("broken pipe")}, want: domain.RetryOnConnection, wantOk: true},
+		{name: "not a network error", err: fmt.Errorf("boom")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := networkErrorKind(tt.err)
+			if got != tt.want || ok != tt.wantOk {
+				t.Fatalf("networkErrorKind(%v) = %q, %v, want %q, %v", tt.err, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		min    time.Duration
+		max    time.Duration
+		wantOk bool
+	}{
+		{name: "no header"},
+		{name: "seconds", value: "3", min: 3 * time.Second, max: 3 * time.Second, wantOk: true},
+		{name: "zero", value: "0", wantOk: true},
+		{name: "negative seconds", value: "-1"},
+		{name: "garbage", value: "soon"},
+		{
+			name:   "http date",
+			value:  time.Now().Add(time.Minute).UTC().Format(http.TimeFormat),
+			min:    58 * time.Second,
+			max:    time.Minute,
+			wantOk: true,
+		},
+		{name: "date in the past", value: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+
+			got, ok := retryAfter(header)
+			if ok != tt.wantOk || got < tt.min || got > tt.max {
+				t.Fatalf("retryAfter(%q) = %s, %v, want [%s, %s], %v", tt.value, got, ok, tt.min, tt.max, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestBreakerOutcome(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want breaker.Outcome
+	}{
+		{name: "success", ctx: context.Background(), want: breaker.Success},
+		{name: "server error", ctx: context.Background(), err: statusError{code: http.StatusBadGateway}, want: breaker.Failure},
+		{name: "too many requests", ctx: context.Background(), err: statusError{code: http.StatusTooManyRequests}, want: breaker.Failure},
+		{name: "client error", ctx: context.Background(), err: statusError{code: http.StatusNotFound}, want: breaker.Ignored},
+		{name: "network error", ctx: context.Background(), err: timeoutError{}, want: breaker.Failure},
+		{name: "canceled run", ctx: canceled, err: timeoutError{}, want: breaker.Ignored},
+		{name: "cassette miss", ctx: context.Background(), err: fmt.Errorf("cassette: %w", errCassetteMiss), want: breaker.Ignored},
+		{name: "egress denied", ctx: context.Background(), err: egress.ErrDenied, want: breaker.Ignored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breakerOutcome(tt.ctx, tt.err); got != tt.want {
+				t.Fatalf("breakerOutcome(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallNodeRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		retryAfter   string
+		policy       domain.RetryPolicy
+		wantAttempts int
+		wantErr      string
+	}{
+		{
+			name:         "backoff without header",
+			policy:       domain.RetryPolicy{MaxAttempts: 3, BackoffBaseMs: 1, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			wantAttempts: 2,
+		},
+		{
+			name:         "retry after within max backoff",
+			retryAfter:   "0",
+			policy:       domain.RetryPolicy{MaxAttempts: 3, BackoffBaseMs: 1, BackoffCapMs: 1000, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			wantAttempts: 2,
+		},
+		{
+			name:         "retry after over cap fails fast",
+			retryAfter:   "120",
+			policy:       domain.RetryPolicy{MaxAttempts: 3, BackoffBaseMs: 1, BackoffCapMs: 1000, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			wantAttempts: 1,
+			wantErr:      "exceeds max backoff",
+		},
+		{
+			name:         "retry after without backoff fails fast",
+			retryAfter:   "1",
+			policy:       domain.RetryPolicy{MaxAttempts: 3, RetryStatuses: []int{http.StatusServiceUnavailable}},
+			wantAttempts: 1,
+			wantErr:      "exceeds max backoff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) == 1 {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				_, _ = w.Write([]byte(`{"ok":true}`))
+			}))
+			defer srv.Close()
+
+			h := newTestNodeHandler(t)
+			node := domain.Node{Id: "node", Name: "node", Url: srv.URL, Method: http.MethodPost, RetryPolicy: tt.policy}
+
+			attempts := 0
+			res, err := h.callNode(context.Background(), node, nil, nodeRequest{body: []byte(`{}`), contentType: domain.JsonContentType},
+				func(int, nodeResponse, error) { attempts++ })
+
+			if attempts != tt.wantAttempts || int(calls.Load()) != tt.wantAttempts {
+				t.Fatalf("attempts = %d, upstream calls = %d, want %d", attempts, calls.Load(), tt.wantAttempts)
+			}
+			if tt.wantErr != "" {
+				var statusErr statusError
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.As(err, &statusErr) {
+					t.Fatalf("callNode() error = %v, want status error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || res.StatusCode != http.StatusOK {
+				t.Fatalf("callNode() = %d, %v, want 200", res.StatusCode, err)
+			}
+		})
+	}
+}
+
+func newTestNodeHandler(t *testing.T) *nodeHandler {
+	t.Helper()
+
+	policy, err := egress.NewPolicy(egress.Settings{AllowPrivate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rateLimits, err := ratelimitAdpt.NewAdapter(config.RateLimit{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := cacheAdpt.NewAdapter(config.Cache{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newNodeHandler(policy.Client(), policy, 5*time.Second, breaker.NewRegistry(breaker.Settings{}, breaker.NewMemoryStore()), nil, rateLimits, cache)
+}
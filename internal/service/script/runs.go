@@ -0,0 +1,293 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+
+	"github.com/rs/xid"
+)
+
+// Enqueue создает запуск скрипта и отправляет его в очередь, сам скрипт выполнит воркер
+func (s *service) Enqueue(ctx context.Context, acc *domain.Account, request models.RunScriptRequest) (domain.ScriptRun, *errors.Error) {
+	run, e := s.createRun(ctx, acc, request, domain.RunQueued)
+	if e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	if err := s.runsAdapter.Publish(ctx, domain.RunMessage{RunId: run.Id}); err != nil {
+		run.Status = domain.RunFailed
+		run.Error = fmt.Sprintf("enqueue run: %s", err.Error())
+		if e := s.saveRunStatus(context.WithoutCancel(ctx), run); e != nil {
+			return domain.ScriptRun{}, e
+		}
+
+		return domain.ScriptRun{}, s.log.ServiceBrokerAdapterError(err)
+	}
+
+	return run, nil
+}
+
+// RunStream выполняет скрипт в текущем запросе, передавая ход выполнения в observe
+func (s *service) RunStream(
+	ctx context.Context,
+	acc *domain.Account,
+	request models.RunScriptRequest,
+	observe func(event domain.RunEvent),
+) (domain.ScriptRun, *errors.Error) {
+	run, e := s.createRun(ctx, acc, request, domain.RunRunning)
+	if e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	outcome, e := s.run(ctx, run, observe)
+	run, e = s.finishRun(ctx, run, outcome, e)
+	if e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	observe(runResultEvent(run))
+	return run, nil
+}
+
+func (s *service) createRun(ctx context.Context, acc *domain.Account, request models.RunScriptRequest, status domain.RunStatus) (domain.ScriptRun, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.ScriptRun{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	script, err := s.scriptRepo.GetById(ctx, tx, request.Id)
+	if err != nil {
+		return domain.ScriptRun{}, errors.DatabaseError(err)
+	}
+	if !canRunScript(acc, script.AuthorId) {
+		return domain.ScriptRun{}, errors.WD(errors.PermissionDenied, fmt.Errorf("script %s", request.Id))
+	}
+
+	cassette, e := s.runCassette(acc, request.Cassette)
+	if e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	files, e := s.storeRunFiles(ctx, request.Files)
+	if e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	now := time.Now()
+	run := domain.ScriptRun{
+		Id:        xid.New().String(),
+		ScriptId:  request.Id,
+		AuthorId:  acc.Id,
+		Status:    status,
+		EnterData: request.EnterData,
+		Files:     files,
+		Cassette:  cassette,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	modelRun, err := run.ToModel()
+	if err != nil {
+		return domain.ScriptRun{}, errors.WD(errors.ParseError, err)
+	}
+
+	if _, err := s.runsRepo.Create(ctx, tx, modelRun); err != nil {
+		return domain.ScriptRun{}, errors.DatabaseError(err)
+	}
+
+	// Коммитим до публикации, чтобы воркер гарантированно нашел запуск в базе
+	if err := tx.Commit(); err != nil {
+		return domain.ScriptRun{}, s.log.ServiceTxError(err)
+	}
+
+	return run, nil
+}
+
+// canRunScript запускать сценарий может только его автор или администратор, как и вызывать его из своих сценариев
+func canRunScript(acc *domain.Account, authorId string) bool {
+	return authorId == acc.Id || acc.Role == domain.RoleAdmin
+}
+
+func (s *service) GetRun(ctx context.Context, acc *domain.Account, id string) (domain.ScriptRun, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.ScriptRun{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := s.runsRepo.GetById(ctx, tx, id)
+	if err != nil {
+		return domain.ScriptRun{}, errors.DatabaseError(err)
+	}
+	run, err := domain.ScriptRun{}.FromModel(res)
+	if err != nil {
+		return domain.ScriptRun{}, errors.WD(errors.ParseError, err)
+	}
+
+	if run.AuthorId != acc.Id && acc.Role != domain.RoleAdmin {
+		return domain.ScriptRun{}, errors.PermissionDenied
+	}
+
+	return run, nil
+}
+
+// Execute выполняет запуск из очереди. Запуск сначала атомарно переводится из queued в running, поэтому
+// повторно доставленное сообщение не выполняет ноды второй раз. Ошибка выполнения самого скрипта сохраняется
+// в запуск, наружу возвращаются только ошибки, из-за которых запуск не удалось начать или сохранить,
+// такой запуск по возможности помечается failed
+func (s *service) Execute(ctx context.Context, runId string) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := s.runsRepo.GetById(ctx, tx, runId)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	run, err := domain.ScriptRun{}.FromModel(res)
+	if err != nil {
+		return errors.WD(errors.ParseError, err)
+	}
+
+	switch run.Status {
+	case domain.RunQueued:
+	case domain.RunRunning:
+		// сообщение доставлено повторно, а воркер, который его взял, остановился посреди запуска:
+		// ноды могли уже вызываться, поэтому запуск не повторяется
+		_, e := s.transitRun(ctx, run, domain.RunFailed, "run was interrupted: worker stopped before it finished", domain.RunRunning)
+		return e
+	default:
+		return nil
+	}
+
+	claimed, e := s.transitRun(ctx, run, domain.RunRunning, "", domain.RunQueued)
+	if e != nil {
+		s.failRun(ctx, run, e, domain.RunQueued)
+		return e
+	}
+	if !claimed {
+		// запуск уже взял другой воркер
+		return nil
+	}
+	run.Status = domain.RunRunning
+
+	outcome, e := s.run(ctx, run, noopObserver)
+	if _, e = s.finishRun(ctx, run, outcome, e); e != nil {
+		s.failRun(ctx, run, e, domain.RunRunning)
+		return e
+	}
+
+	return nil
+}
+
+// transitRun переводит запуск в статус to, только если сейчас он в одном из статусов from
+func (s *service) transitRun(ctx context.Context, run domain.ScriptRun, to domain.RunStatus, reason string, from ...domain.RunStatus) (bool, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return false, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	run.Status = to
+	run.Error = reason
+	run.UpdatedAt = time.Now()
+	modelRun, err := run.ToModel()
+	if err != nil {
+		return false, errors.WD(errors.ParseError, err)
+	}
+
+	statuses := make([]string, 0, len(from))
+	for _, status := range from {
+		statuses = append(statuses, string(status))
+	}
+
+	ok, err := s.runsRepo.UpdateStatusFrom(ctx, tx, modelRun, statuses)
+	if err != nil {
+		return false, errors.DatabaseError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, s.log.ServiceTxError(err)
+	}
+
+	return ok, nil
+}
+
+// failRun помечает failed запуск, который не удалось начать или сохранить, чтобы он не остался
+// в очереди или в работе навсегда. Если база недоступна, остается только ошибка в логе
+func (s *service) failRun(ctx context.Context, run domain.ScriptRun, cause *errors.Error, from domain.RunStatus) {
+	if _, e := s.transitRun(context.WithoutCancel(ctx), run, domain.RunFailed, runErrorText(cause), from); e != nil {
+		s.log.ServiceError(e)
+	}
+}
+
+// finishRun сохраняет историю и итоговый статус запуска по результату выполнения
+func (s *service) finishRun(ctx context.Context, run domain.ScriptRun, outcome runOutcome, runErr *errors.Error) (domain.ScriptRun, *errors.Error) {
+	if runErr != nil {
+		run.Status = domain.RunFailed
+		run.Error = runErrorText(runErr)
+	} else {
+		run.Status = domain.RunSucceeded
+		run.Result = outcome.output
+		run.ResultMime = outcome.mime
+	}
+	run.Report = outcome.report
+	run.Usage = runUsage(outcome.steps)
+
+	// Контекст запуска к этому моменту может быть уже отменен по таймауту, а историю и статус сохранить нужно
+	saveCtx := context.WithoutCancel(ctx)
+	if e := s.saveRunSteps(saveCtx, run.Id, outcome.steps); e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	if e := s.saveRunStatus(saveCtx, run); e != nil {
+		return domain.ScriptRun{}, e
+	}
+
+	return run, nil
+}
+
+func (s *service) saveRunStatus(ctx context.Context, run domain.ScriptRun) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	run.UpdatedAt = time.Now()
+	modelRun, err := run.ToModel()
+	if err != nil {
+		return errors.WD(errors.ParseError, err)
+	}
+
+	if err := s.runsRepo.UpdateStatus(ctx, tx, modelRun); err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
+
+func runErrorText(e *errors.Error) string {
+	if e.Details != nil {
+		return fmt.Sprintf("%s: %s", e.Reason, e.Details.Error())
+	}
+
+	return e.Reason
+}
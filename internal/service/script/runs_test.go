@@ -0,0 +1,116 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	runsRepo "github.com/warehouse/ai-service/internal/repository/operations/runs"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+)
+
+type testTx struct{}
+
+func (testTx) Commit() error { return nil }
+func (testTx) Rollback()     {}
+func (testTx) Txm() *sqlx.Tx { return nil }
+
+type testTxRepo struct{}
+
+func (testTxRepo) StartTransaction(context.Context) (transactions.Transaction, error) {
+	return testTx{}, nil
+}
+
+// testTransition переход статуса, который сервис запросил у репозитория
+type testTransition struct {
+	to    string
+	from  string
+	error string
+}
+
+type testRunsRepo struct {
+	runsRepo.Repository
+
+	run         models.ScriptRun
+	claimed     bool  // результат перехода queued -> running
+	claimErr    error // ошибка перехода queued -> running
+	transitions []testTransition
+}
+
+func (r *testRunsRepo) GetById(context.Context, transactions.Transaction, string) (models.ScriptRun, error) {
+	return r.run, nil
+}
+
+func (r *testRunsRepo) UpdateStatusFrom(_ context.Context, _ transactions.Transaction, run models.ScriptRun, from []string) (bool, error) {
+	r.transitions = append(r.transitions, testTransition{to: run.Status, from: strings.Join(from, ","), error: run.Error})
+
+	if run.Status == string(domain.RunRunning) && slices.Equal(from, []string{string(domain.RunQueued)}) {
+		return r.claimed, r.claimErr
+	}
+
+	return true, nil
+}
+
+func TestExecuteClaimsRun(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          domain.RunStatus
+		claimed         bool
+		claimErr        error
+		wantErr         bool
+		wantTransitions []testTransition
+	}{
+		{name: "finished run is not executed again", status: domain.RunSucceeded},
+		{name: "failed run is not executed again", status: domain.RunFailed},
+		{
+			name:            "run claimed by another worker",
+			status:          domain.RunQueued,
+			wantTransitions: []testTransition{{to: "running", from: "queued"}},
+		},
+		{
+			name:   "redelivered run interrupted in the middle",
+			status: domain.RunRunning,
+			wantTransitions: []testTransition{
+				{to: "failed", from: "running", error: "run was interrupted: worker stopped before it finished"},
+			},
+		},
+		{
+			name:     "run that can't be claimed is failed",
+			status:   domain.RunQueued,
+			claimErr: fmt.Errorf("connection reset"),
+			wantErr:  true,
+			wantTransitions: []testTransition{
+				{to: "running", from: "queued"},
+				{to: "failed", from: "queued", error: "database failed: connection reset"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &testRunsRepo{
+				run:      models.ScriptRun{Id: xid.New(), Status: string(tt.status)},
+				claimed:  tt.claimed,
+				claimErr: tt.claimErr,
+			}
+			s := &service{log: logger.NewLogger(zap.NewNop()), txRepo: testTxRepo{}, runsRepo: repo}
+
+			e := s.Execute(context.Background(), repo.run.Id.String())
+			if (e != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, want error %v", e, tt.wantErr)
+			}
+			if fmt.Sprint(repo.transitions) != fmt.Sprint(tt.wantTransitions) {
+				t.Fatalf("transitions = %+v, want %+v", repo.transitions, tt.wantTransitions)
+			}
+		})
+	}
+}
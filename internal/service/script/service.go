@@ -2,24 +2,40 @@ package script
 
 import (
 	"context"
-	"strconv"
-	"strings"
-	"sync"
 
+	blobAdpt "github.com/warehouse/ai-service/internal/adapter/blob"
+	cacheAdpt "github.com/warehouse/ai-service/internal/adapter/cache"
+	cassetteAdpt "github.com/warehouse/ai-service/internal/adapter/cassette"
+	ratelimitAdpt "github.com/warehouse/ai-service/internal/adapter/ratelimit"
+	runsAdpt "github.com/warehouse/ai-service/internal/adapter/runs"
 	"github.com/warehouse/ai-service/internal/config"
 	"github.com/warehouse/ai-service/internal/domain"
 	"github.com/warehouse/ai-service/internal/handler/models"
+	"github.com/warehouse/ai-service/internal/pkg/breaker"
+	"github.com/warehouse/ai-service/internal/pkg/egress"
 	"github.com/warehouse/ai-service/internal/pkg/errors"
 	"github.com/warehouse/ai-service/internal/pkg/logger"
 	nodesRepo "github.com/warehouse/ai-service/internal/repository/operations/nodes"
+	runsRepo "github.com/warehouse/ai-service/internal/repository/operations/runs"
 	scriptRepo "github.com/warehouse/ai-service/internal/repository/operations/script"
+	stepsRepo "github.com/warehouse/ai-service/internal/repository/operations/steps"
 	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
 )
 
 type (
 	Service interface {
-		Run(ctx context.Context, request models.RunScriptRequest) (string, *errors.Error)
+		Enqueue(ctx context.Context, acc *domain.Account, request models.RunScriptRequest) (domain.ScriptRun, *errors.Error)
+		Execute(ctx context.Context, runId string) *errors.Error
+		DryRun(ctx context.Context, acc *domain.Account, request models.RunScriptRequest) ([]domain.DryRunRequest, *errors.Error)
+		RunStream(ctx context.Context, acc *domain.Account, request models.RunScriptRequest, observe func(event domain.RunEvent)) (domain.ScriptRun, *errors.Error)
+		GetRun(ctx context.Context, acc *domain.Account, id string) (domain.ScriptRun, *errors.Error)
+		ListRuns(ctx context.Context, acc *domain.Account, limit, offset int) ([]domain.ScriptRun, *errors.Error)
+		GetRunHistory(ctx context.Context, acc *domain.Account, id string) (domain.ScriptRun, []domain.RunStep, *errors.Error)
+		DownloadBlob(ctx context.Context, key, expires, signature string) ([]byte, string, *errors.Error)
 		Create(ctx context.Context, acc *domain.Account, request models.CreateScriptRequest) (domain.Script, *errors.Error)
+		Usage(ctx context.Context, acc *domain.Account, filter domain.UsageFilter) ([]domain.UsageTotal, *errors.Error)
 	}
 
 	service struct {
@@ -29,6 +45,13 @@ type (
 		txRepo     transactions.Repository
 		nodesRepo  nodesRepo.Repository
 		scriptRepo scriptRepo.Repository
+		runsRepo   runsRepo.Repository
+		stepsRepo  stepsRepo.Repository
+
+		runsAdapter runsAdpt.Adapter
+		blobAdapter blobAdpt.Adapter
+
+		nodeHandler *nodeHandler
 	}
 )
 
@@ -38,17 +61,31 @@ func NewService(
 	txRepo transactions.Repository,
 	nodesRepo nodesRepo.Repository,
 	scriptRepo scriptRepo.Repository,
+	runsRepo runsRepo.Repository,
+	stepsRepo stepsRepo.Repository,
+	runsAdapter runsAdpt.Adapter,
+	blobAdapter blobAdpt.Adapter,
+	cassetteAdapter cassetteAdpt.Adapter,
+	ratelimitAdapter ratelimitAdpt.Adapter,
+	cacheAdapter cacheAdpt.Adapter,
+	breakers breaker.Registry,
+	egressPolicy *egress.Policy,
 ) Service {
 	return &service{
-		cfg:        cfg,
-		log:        log,
-		txRepo:     txRepo,
-		nodesRepo:  nodesRepo,
-		scriptRepo: scriptRepo,
+		cfg:         cfg,
+		log:         log,
+		txRepo:      txRepo,
+		nodesRepo:   nodesRepo,
+		scriptRepo:  scriptRepo,
+		runsRepo:    runsRepo,
+		stepsRepo:   stepsRepo,
+		runsAdapter: runsAdapter,
+		blobAdapter: blobAdapter,
+		nodeHandler: newNodeHandler(egressPolicy.Client(), egressPolicy, cfg.Timeouts.NodeTimeout, breakers, cassetteAdapter, ratelimitAdapter, cacheAdapter),
 	}
 }
 
-// 1. Валидация
+// 1. Валидация (сценарий задается шагами и цепочками или графом)
 // 2. Генерируем заполненный JSON, который потом будет передаваться в апишку
 // 2. Сохранение
 func (s *service) Create(ctx context.Context, acc *domain.Account, request models.CreateScriptRequest) (domain.Script, *errors.Error) {
@@ -58,22 +95,35 @@ func (s *service) Create(ctx context.Context, acc *domain.Account, request model
 	}
 	defer tx.Rollback()
 
-	workflowMap := make(map[int]map[int][]string)
-	for key, value := range request.Workflow {
-		stepKey, err := strconv.Atoi(key)
-		if err != nil {
-			return domain.Script{}, errors.WD(errors.ParseError, err)
-		}
+	workflowMap, err := domain.ParseWorkflow(request.Workflow)
+	if err != nil {
+		return domain.Script{}, errors.WD(errors.ValidationFailed, err)
+	}
 
-		stepChainMap := s.parseStep(value)
-		workflowMap[stepKey] = stepChainMap
+	// TODO: добавить айди автора
+	script := domain.Script{
+		Id:              xid.New().String(),
+		Name:            request.Name,
+		Workflow:        workflowMap,
+		BodyPresets:     request.BodyPresets,
+		HeaderPresets:   request.HeaderPresets,
+		Options:         domain.ScriptOptions{Steps: request.Steps, Vars: request.Vars, Templates: true},
+		AuthorId:        acc.Id,
+		WarehouseApiKey: "test_key",
+	}
+	if request.Graph != nil {
+		script.Graph = *request.Graph
 	}
 
-	usedNodes, e := s.validateWorkflow(ctx, tx, request.Workflow)
+	usedNodes, e := s.validateWorkflow(ctx, tx, script)
 	if e != nil {
 		return domain.Script{}, e
 	}
 
+	if e := s.validateSubScripts(ctx, tx, acc, script); e != nil {
+		return domain.Script{}, e
+	}
+
 	if e := s.validateBodyPresets(usedNodes, request.BodyPresets); e != nil {
 		return domain.Script{}, e
 	}
@@ -82,14 +132,8 @@ func (s *service) Create(ctx context.Context, acc *domain.Account, request model
 		return domain.Script{}, e
 	}
 
-	// TODO: добавить айди автора
-	script := domain.Script{
-		Name:            request.Name,
-		Workflow:        workflowMap,
-		BodyPresets:     request.BodyPresets,
-		HeaderPresets:   request.HeaderPresets,
-		AuthorId:        acc.Id,
-		WarehouseApiKey: "test_key",
+	if e := s.validatePresetTemplates(script, usedNodes); e != nil {
+		return domain.Script{}, e
 	}
 
 	modelScript, err := script.ToModel()
@@ -97,13 +141,10 @@ func (s *service) Create(ctx context.Context, acc *domain.Account, request model
 		return domain.Script{}, errors.WD(errors.ParseError, err)
 	}
 
-	createdScript, err := s.scriptRepo.Create(ctx, tx, modelScript)
-	if err != nil {
+	if _, err := s.scriptRepo.Create(ctx, tx, modelScript); err != nil {
 		return domain.Script{}, errors.DatabaseError(err)
 	}
 
-	script.Id = createdScript.Id.String()
-
 	if err := tx.Commit(); err != nil {
 		return domain.Script{}, s.log.ServiceTxError(err)
 	}
@@ -111,63 +152,68 @@ func (s *service) Create(ctx context.Context, acc *domain.Account, request model
 	return script, nil
 }
 
-func (s *service) Run(ctx context.Context, request models.RunScriptRequest) (string, *errors.Error) {
+// run выполняет скрипт запуска по его графу. История и отчет возвращаются и при ошибке, чтобы их можно было сохранить
+func (s *service) run(ctx context.Context, run domain.ScriptRun, observe runObserver) (runOutcome, *errors.Error) {
+	script, graph, nodes, e := s.loadScript(ctx, run.ScriptId)
+	if e != nil {
+		return runOutcome{}, e
+	}
+
+	// кассеты и кеш ответов у каждого автора свои
+	cassette := run.Cassette
+	cassette.Owner = run.AuthorId
+	ctx = withCacheScope(withCassette(ctx, cassette), run.AuthorId)
+	outcome, err := s.runGraph(ctx, run, script, graph, nodes, observe)
+	if err != nil {
+		return outcome, execError(err)
+	}
+
+	if outcome.output, err = s.resultURL(ctx, outcome.output); err != nil {
+		return outcome, execError(err)
+	}
+
+	return outcome, nil
+}
+
+// loadScript достает сценарий, его граф и ноды, которые граф вызывает
+func (s *service) loadScript(ctx context.Context, scriptId string) (domain.Script, domain.Graph, map[string]domain.Node, *errors.Error) {
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
-		return "", s.log.ServiceTxError(err)
+		return domain.Script{}, domain.Graph{}, nil, s.log.ServiceTxError(err)
 	}
 	defer tx.Rollback()
 
-	res, err := s.scriptRepo.GetById(ctx, tx, request.Id)
+	res, err := s.scriptRepo.GetById(ctx, tx, scriptId)
 	if err != nil {
-		return "", errors.DatabaseError(err)
+		return domain.Script{}, domain.Graph{}, nil, errors.DatabaseError(err)
 	}
 	script, err := domain.Script{}.FromModel(res)
 	if err != nil {
-		return "", errors.WD(errors.ParseError, err)
+		return domain.Script{}, domain.Graph{}, nil, errors.WD(errors.ParseError, err)
 	}
 
-	scriptMap, e := s.fillScriptMap(ctx, tx, script.Workflow)
-	if e != nil {
-		return "", e
+	graph, err := script.ExecutionGraph()
+	if err != nil {
+		return domain.Script{}, domain.Graph{}, nil, errors.WD(errors.ValidationFailed, err)
 	}
 
-	stepCtx := request.EnterData
-	for i := 1; i < len(scriptMap); i++ {
-		step, stepOk := scriptMap[i]
-
-		if stepOk {
-			var stepWg sync.WaitGroup
-			stepCh := make(chan domain.ChainResult, len(step))
-
-			for j := 0; j < len(step); j++ {
-				chain, chainOk := step[j]
-
-				if chainOk {
-					stepWg.Add(1)
-					go s.chainHandler(&stepWg, stepCh, script.BodyPresets, script.HeaderPresets, chain, stepCtx)
-				}
-			}
-
-			stepWg.Wait()
-
-			// читаем данные с канала и объединяем в общий контект для следующего шага
-			newContext := []string{}
-			for res := range stepCh {
-				if res.Error != nil {
-					return "", errors.ExecError(res.Error)
-				}
-
-				newContext = append(newContext, res.Response)
-			}
-
-			stepCtx = strings.Join(newContext, ". ")
-		}
+	nodes, e := s.loadGraphNodes(ctx, tx, graph)
+	if e != nil {
+		return domain.Script{}, domain.Graph{}, nil, e
 	}
 
 	if err := tx.Commit(); err != nil {
-		return "", s.log.ServiceTxError(err)
+		return domain.Script{}, domain.Graph{}, nil, s.log.ServiceTxError(err)
+	}
+
+	return script, graph, nodes, nil
+}
+
+// execError ошибка выполнения скрипта, разомкнутый брейкер отдаем отдельной причиной
+func execError(err error) *errors.Error {
+	if breaker.IsOpen(err) {
+		return errors.WD(errors.CircuitOpen, err)
 	}
 
-	return stepCtx, nil
+	return errors.ExecError(err)
 }
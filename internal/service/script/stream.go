@@ -0,0 +1,87 @@
+package script
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+type streamDeltasCtxKey struct{}
+
+// withStreamDeltas дельты стримящей ноды, вызванной с этим ctx, передаются в onDelta по мере получения
+func withStreamDeltas(ctx context.Context, onDelta func(delta string)) context.Context {
+	return context.WithValue(ctx, streamDeltasCtxKey{}, onDelta)
+}
+
+func streamDeltas(ctx context.Context) func(delta string) {
+	onDelta, ok := ctx.Value(streamDeltasCtxKey{}).(func(delta string))
+	if !ok {
+		return func(string) {}
+	}
+
+	return onDelta
+}
+
+// readStream читает потоковый ответ ноды по событиям. Возвращает ответ целиком для истории и склейку дельт -
+// результат ноды. Каждая дельта сразу уходит в onDelta
+func readStream(body io.Reader, options domain.StreamOptions, onDelta func(delta string)) ([]byte, string, error) {
+	var raw bytes.Buffer
+	var text strings.Builder
+	err := scanStream(io.TeeReader(body, &raw), options.Format, func(data string) {
+		if delta, ok := options.Delta(data); ok {
+			text.WriteString(delta)
+			onDelta(delta)
+		}
+	})
+
+	return raw.Bytes(), text.String(), err
+}
+
+// scanStream передает в dispatch данные каждого события потока, кроме завершающего [DONE]
+func scanStream(body io.Reader, format domain.StreamFormat, dispatch func(data string)) error {
+	reader := bufio.NewReader(body)
+	emit := func(data string) {
+		if data != domain.StreamDoneMarker {
+			dispatch(data)
+		}
+	}
+
+	// строки data: одного события sse склеиваются через перевод строки, событие заканчивается пустой строкой
+	event := []string{}
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch format {
+		case domain.SSEStreamFormat:
+			if line == "" && len(event) != 0 {
+				emit(strings.Join(event, "\n"))
+				event = event[:0]
+			}
+			if data, ok := strings.CutPrefix(line, "data:"); ok {
+				event = append(event, strings.TrimPrefix(data, " "))
+			}
+		case domain.NdjsonStreamFormat:
+			if strings.TrimSpace(line) != "" {
+				emit(line)
+			}
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(event) != 0 {
+		emit(strings.Join(event, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package script
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestReadStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    domain.StreamOptions
+		body       string
+		wantDeltas []string
+	}{
+		{
+			name:       "sse with delta path",
+			options:    domain.StreamOptions{Format: domain.SSEStreamFormat, DeltaPath: "choices[0].delta.content"},
+			body:       "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"lo\"}}]}\n\ndata: [DONE]\n\n",
+			wantDeltas: []string{"Hel", "lo"},
+		},
+		{
+			name:       "sse data lines of one event are joined",
+			options:    domain.StreamOptions{Format: domain.SSEStreamFormat},
+			body:       "event: message\ndata: first\ndata: second\n\n: comment\ndata:third\n\n",
+			wantDeltas: []string{"first\nsecond", "third"},
+		},
+		{
+			name:       "sse with crlf and the last event without blank line",
+			options:    domain.StreamOptions{Format: domain.SSEStreamFormat},
+			body:       "data: a\r\n\r\ndata: b",
+			wantDeltas: []string{"a", "b"},
+		},
+		{
+			name:       "ndjson",
+			options:    domain.StreamOptions{Format: domain.NdjsonStreamFormat, DeltaPath: "response"},
+			body:       "{\"response\":\"Hel\"}\n\n{\"response\":\"lo\"}\n{\"done\":true}",
+			wantDeltas: []string{"Hel", "lo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas []string
+			raw, text, err := readStream(strings.NewReader(tt.body), tt.options, func(delta string) {
+				deltas = append(deltas, delta)
+			})
+			if err != nil {
+				t.Fatalf("readStream() unexpected error: %v", err)
+			}
+			if string(raw) != tt.body {
+				t.Fatalf("raw = %q, want the whole body %q", raw, tt.body)
+			}
+			if strings.Join(deltas, "|") != strings.Join(tt.wantDeltas, "|") {
+				t.Fatalf("deltas = %q, want %q", deltas, tt.wantDeltas)
+			}
+			if text != strings.Join(tt.wantDeltas, "") {
+				t.Fatalf("text = %q, want joined deltas", text)
+			}
+		})
+	}
+}
+
+func TestRunGraphStreamDeltas(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.HeaderContentType, "text/event-stream")
+		for _, delta := range []string{"Hel", "lo"} {
+			_, _ = w.Write([]byte("data: {\"text\":\"" + delta + "\"}\n\n"))
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	t.Cleanup(upstream.Close)
+
+	nodes, presets := testGraphNodes(newTestUpstream(t), "first")
+	nodes["stream"] = domain.Node{
+		Id:     "stream",
+		Name:   "stream",
+		Url:    upstream.URL,
+		Method: http.MethodPost,
+		Stream: domain.StreamOptions{Format: domain.SSEStreamFormat, DeltaPath: "text"},
+		Body:   map[string]domain.BodyField{"input": {Type: domain.DataFieldType}},
+	}
+	presets["stream"] = map[string]interface{}{"input": ""}
+
+	script := domain.Script{
+		BodyPresets: presets,
+		Graph: domain.Graph{Nodes: []domain.GraphNode{
+			{Name: "first", NodeId: "first"},
+			{Name: "stream", NodeId: "stream", Inputs: []string{"first"}},
+		}},
+	}
+	s := &service{nodeHandler: newTestNodeHandler(t)}
+
+	outcome, events, err := runTestGraph(t, context.Background(), s, script, nodes, "x")
+	if err != nil {
+		t.Fatalf("runGraph() unexpected error: %v", err)
+	}
+	if outcome.output != "Hello" {
+		t.Fatalf("output = %q, want joined deltas", outcome.output)
+	}
+
+	// дельты идут только от узла, чей результат станет результатом запуска
+	want := []string{
+		"node_started first",
+		"node_result first first(x)",
+		"node_started stream",
+		"node_delta stream Hel",
+		"node_delta stream lo",
+		"node_result stream Hello",
+	}
+	if got := eventTrace(events); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
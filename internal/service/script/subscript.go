@@ -0,0 +1,131 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+)
+
+// maxScriptDepth сколько уровней вложенных сценариев можно выполнить из одного запуска, сам сценарий - первый уровень
+const maxScriptDepth = 5
+
+type scriptDepthCtxKey struct{}
+
+func scriptDepth(ctx context.Context) int {
+	depth, ok := ctx.Value(scriptDepthCtxKey{}).(int)
+	if !ok {
+		return 1
+	}
+
+	return depth
+}
+
+// validateSubScripts проверяет вложенные сценарии: они существуют, автор имеет к ним доступ,
+// вложенность не превышает maxScriptDepth и сценарии не вызывают друг друга по кругу
+func (s *service) validateSubScripts(ctx context.Context, tx transactions.Transaction, acc *domain.Account, script domain.Script) *errors.Error {
+	graph, err := script.ExecutionGraph()
+	if err != nil {
+		return errors.WD(errors.ValidationFailed, err)
+	}
+
+	return s.validateScriptRefs(ctx, tx, acc, graph.ScriptIds(), []string{script.Id})
+}
+
+func (s *service) validateScriptRefs(ctx context.Context, tx transactions.Transaction, acc *domain.Account, ids []string, path []string) *errors.Error {
+	for _, id := range ids {
+		if slices.Contains(path, id) {
+			cycle := strings.Join(append(path, id), " -> ")
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("scripts call each other in a cycle: %s", cycle))
+		}
+
+		if len(path) >= maxScriptDepth {
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("scripts nesting is deeper than %d: %s -> %s", maxScriptDepth, strings.Join(path, " -> "), id))
+		}
+
+		res, err := s.scriptRepo.GetById(ctx, tx, id)
+		if err != nil {
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("script %s: %s", id, err.Error()))
+		}
+		subScript, err := domain.Script{}.FromModel(res)
+		if err != nil {
+			return errors.WD(errors.ParseError, err)
+		}
+
+		// доступ проверяем только к сценариям, на которые ссылается автор,
+		// их вложенные сценарии проверялись при их создании
+		if len(path) == 1 && !canRunScript(acc, subScript.AuthorId) {
+			return errors.WD(errors.PermissionDenied, fmt.Errorf("script %s", id))
+		}
+
+		graph, err := subScript.ExecutionGraph()
+		if err != nil {
+			return errors.WD(errors.ValidationFailed, fmt.Errorf("script %s: %s", id, err.Error()))
+		}
+
+		if e := s.validateScriptRefs(ctx, tx, acc, graph.ScriptIds(), append(slices.Clone(path), id)); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// callScriptNode выполняет вложенный сценарий с входом узла в качестве начальных данных, файлы запуска доступны и ему.
+// Вызовы нод вложенного сценария попадают в историю запуска с именем узла в качестве префикса
+func (s *service) callScriptNode(ctx context.Context, graphNode domain.GraphNode, scope templateScope) graphNodeResult {
+	result := graphNodeResult{name: graphNode.Name}
+
+	depth := scriptDepth(ctx) + 1
+	if depth > maxScriptDepth {
+		result.err = fmt.Errorf("script %s: nesting is deeper than %d", graphNode.ScriptId, maxScriptDepth)
+		return result
+	}
+	ctx = context.WithValue(ctx, scriptDepthCtxKey{}, depth)
+	if dry, ok := dryRunFrom(ctx); ok {
+		ctx = withDryRun(ctx, dry.nested(graphNode.Name))
+	}
+
+	script, graph, nodes, e := s.loadScript(ctx, graphNode.ScriptId)
+	if e != nil {
+		result.err = fmt.Errorf("script %s: %s", graphNode.ScriptId, runErrorText(e))
+		return result
+	}
+	result.nodeName = script.Name
+
+	subRun := domain.ScriptRun{ScriptId: script.Id, EnterData: scope.data, Files: scope.files}
+	outcome, err := s.runGraph(ctx, subRun, script, graph, nodes, noopObserver)
+
+	for _, step := range outcome.steps {
+		step.Step = graphNode.Step
+		if graphNode.Chain != nil {
+			step.Chain = *graphNode.Chain
+		}
+		step.Position = graphNode.Position
+		step.GraphNode = graphNode.Name + "/" + step.GraphNode
+		result.steps = append(result.steps, step)
+	}
+	for _, fallback := range outcome.report.Fallbacks {
+		fallback.GraphNode = graphNode.Name + "/" + fallback.GraphNode
+		result.fallbacks = append(result.fallbacks, fallback)
+	}
+	for _, failure := range outcome.report.Failures {
+		failure.GraphNode = graphNode.Name + "/" + failure.GraphNode
+		failure.Step = graphNode.Step
+		failure.Chain = graphNode.Chain
+		result.failures = append(result.failures, failure)
+	}
+
+	if err != nil {
+		result.err = fmt.Errorf("script %s: %w", graphNode.ScriptId, err)
+		return result
+	}
+
+	result.output = outcome.output
+	result.mime = outcome.mime
+	return result
+}
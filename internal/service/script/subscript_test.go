@@ -0,0 +1,273 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/repository/models"
+	nodesRepo "github.com/warehouse/ai-service/internal/repository/operations/nodes"
+	scriptRepo "github.com/warehouse/ai-service/internal/repository/operations/script"
+	"github.com/warehouse/ai-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
+)
+
+type testScriptRepo struct {
+	scriptRepo.Repository
+
+	scripts map[string]models.Script
+}
+
+func (r *testScriptRepo) GetById(_ context.Context, _ transactions.Transaction, id string) (models.Script, error) {
+	script, ok := r.scripts[id]
+	if !ok {
+		return models.Script{}, fmt.Errorf("script not found")
+	}
+
+	return script, nil
+}
+
+type testNodesRepo struct {
+	nodesRepo.Repository
+
+	nodes map[string]models.Node
+}
+
+func (r *testNodesRepo) GetByIds(_ context.Context, _ transactions.Transaction, ids []string) ([]models.Node, error) {
+	list := []models.Node{}
+	for _, id := range ids {
+		if node, ok := r.nodes[id]; ok {
+			list = append(list, node)
+		}
+	}
+
+	// как и postgres, пустой результат считаем ошибкой
+	if len(list) == 0 {
+		return nil, fmt.Errorf("nodes with provided ids not found")
+	}
+
+	return list, nil
+}
+
+// testScripts сценарии по именам: у каждого свой айди, граф сценария по очереди вызывает вложенные сценарии calls
+type testScripts struct {
+	t     *testing.T
+	ids   map[string]string
+	repo  *testScriptRepo
+	nodes *testNodesRepo
+}
+
+func newTestScripts(t *testing.T) *testScripts {
+	return &testScripts{
+		t:     t,
+		ids:   map[string]string{},
+		repo:  &testScriptRepo{scripts: map[string]models.Script{}},
+		nodes: &testNodesRepo{nodes: map[string]models.Node{}},
+	}
+}
+
+func (s *testScripts) id(name string) string {
+	if _, ok := s.ids[name]; !ok {
+		s.ids[name] = xid.New().String()
+	}
+
+	return s.ids[name]
+}
+
+// add сохраняет сценарий, без вызовов сценарий передает вход дальше без изменений
+func (s *testScripts) add(name, author string, calls ...string) domain.Script {
+	s.t.Helper()
+
+	graph := domain.Graph{Nodes: []domain.GraphNode{{Name: "pass", Kind: domain.JoinGraphNodeKind, Inputs: []string{domain.GraphInput}}}}
+	if len(calls) != 0 {
+		graph.Nodes = nil
+		for i, call := range calls {
+			node := domain.GraphNode{Name: fmt.Sprintf("call%d", i), Kind: domain.ScriptGraphNodeKind, ScriptId: s.id(call)}
+			if i > 0 {
+				node.Inputs = []string{fmt.Sprintf("call%d", i-1)}
+			}
+			graph.Nodes = append(graph.Nodes, node)
+		}
+	}
+
+	return s.save(domain.Script{Id: s.id(name), Name: name, AuthorId: author, Graph: graph})
+}
+
+func (s *testScripts) save(script domain.Script) domain.Script {
+	s.t.Helper()
+
+	model, err := script.ToModel()
+	if err != nil {
+		s.t.Fatalf("ToModel() unexpected error: %v", err)
+	}
+	s.repo.scripts[script.Id] = model
+
+	return script
+}
+
+func (s *testScripts) service() *service {
+	return &service{
+		txRepo:      testTxRepo{},
+		scriptRepo:  s.repo,
+		nodesRepo:   s.nodes,
+		nodeHandler: newTestNodeHandler(s.t),
+	}
+}
+
+func TestValidateSubScripts(t *testing.T) {
+	author := &domain.Account{Id: "author", Role: domain.RoleUser}
+	admin := &domain.Account{Id: "admin", Role: domain.RoleAdmin}
+
+	tests := []struct {
+		name    string
+		acc     *domain.Account
+		setup   func(s *testScripts) domain.Script
+		wantErr string
+	}{
+		{
+			name: "nesting up to the limit",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				s.add("a", "author", "b")
+				s.add("b", "author", "c")
+				s.add("c", "author", "d")
+				s.add("d", "author")
+				return s.add("root", "author", "a")
+			},
+		},
+		{
+			name: "nesting deeper than the limit",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				s.add("a", "author", "b")
+				s.add("b", "author", "c")
+				s.add("c", "author", "d")
+				s.add("d", "author", "e")
+				s.add("e", "author")
+				return s.add("root", "author", "a")
+			},
+			wantErr: fmt.Sprintf("deeper than %d", maxScriptDepth),
+		},
+		{
+			name: "scripts call each other",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				s.add("a", "author", "b")
+				s.add("b", "author", "a")
+				return s.add("root", "author", "a")
+			},
+			wantErr: "cycle",
+		},
+		{
+			name: "script calls itself",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				return s.add("root", "author", "root")
+			},
+			wantErr: "cycle",
+		},
+		{
+			name: "unknown script",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				return s.add("root", "author", "missing")
+			},
+			wantErr: "script not found",
+		},
+		{
+			name: "script of another author",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				s.add("foreign", "other")
+				return s.add("root", "author", "foreign")
+			},
+			wantErr: "permission denied",
+		},
+		{
+			name: "admin uses script of another author",
+			acc:  admin,
+			setup: func(s *testScripts) domain.Script {
+				s.add("foreign", "other")
+				return s.add("root", "admin", "foreign")
+			},
+		},
+		{
+			name: "nested script of another author is checked by its own author",
+			acc:  author,
+			setup: func(s *testScripts) domain.Script {
+				s.add("foreign", "other")
+				s.add("own", "author", "foreign")
+				return s.add("root", "author", "own")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scripts := newTestScripts(t)
+			script := tt.setup(scripts)
+
+			e := scripts.service().validateSubScripts(context.Background(), testTx{}, tt.acc, script)
+			if tt.wantErr == "" {
+				if e != nil {
+					t.Fatalf("validateSubScripts() unexpected error: %+v", e)
+				}
+				return
+			}
+			if e == nil || !strings.Contains(e.Reason+": "+fmt.Sprint(e.Details), tt.wantErr) {
+				t.Fatalf("validateSubScripts() error = %+v, want %q", e, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCallScriptNode(t *testing.T) {
+	upstream := newTestUpstream(t)
+	modelId := xid.New()
+	nodeId := modelId.String()
+	nodes, presets := testGraphNodes(upstream, nodeId)
+
+	scripts := newTestScripts(t)
+	nodeModel, err := nodes[nodeId].ToModel()
+	if err != nil {
+		t.Fatalf("ToModel() unexpected error: %v", err)
+	}
+	nodeModel.Id = modelId
+	scripts.nodes.nodes[nodeId] = nodeModel
+
+	scripts.save(domain.Script{
+		Id:          scripts.id("inner"),
+		Name:        "inner",
+		BodyPresets: presets,
+		Graph:       domain.Graph{Nodes: []domain.GraphNode{{Name: "call", NodeId: nodeId}}},
+	})
+	outer := scripts.add("outer", "author", "inner")
+
+	outcome, _, err := runTestGraph(t, context.Background(), scripts.service(), outer, nil, "x")
+	if err != nil {
+		t.Fatalf("runGraph() unexpected error: %v", err)
+	}
+	if want := fmt.Sprintf("%s(x)", nodeId); outcome.output != want {
+		t.Fatalf("output = %q, want %q", outcome.output, want)
+	}
+	if len(outcome.steps) != 1 || outcome.steps[0].GraphNode != "call0/call" {
+		t.Fatalf("steps = %+v, want one step of call0/call", outcome.steps)
+	}
+}
+
+func TestCallScriptNodeDepth(t *testing.T) {
+	// цикл, сохраненный до проверки вложенности, останавливается на maxScriptDepth при выполнении
+	scripts := newTestScripts(t)
+	loop := scripts.add("loop", "author", "loop")
+
+	_, _, err := runTestGraph(t, context.Background(), scripts.service(), loop, nil, "x")
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("nesting is deeper than %d", maxScriptDepth)) {
+		t.Fatalf("runGraph() error = %v, want nesting limit", err)
+	}
+	if got := strings.Count(err.Error(), "script "+scripts.id("loop")); got != maxScriptDepth {
+		t.Fatalf("nested calls in error = %d, want %d: %v", got, maxScriptDepth, err)
+	}
+}
@@ -0,0 +1,190 @@
+package script
+
+import (
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// templateScope значения, доступные шаблонам в пресетах ноды в момент ее вызова
+type templateScope struct {
+	graph   domain.Graph
+	input   string            // вход сценария
+	data    string            // вход узла
+	mime    string            // mime входа узла
+	vars    map[string]string // переменные сценария
+	outputs map[string]string // результаты завершенных и не пропущенных узлов
+	mimes   map[string]string // mime результатов узлов
+	files   map[string]string // файлы запуска, значение - ссылка на blob
+	literal bool              // сценарий создан до шаблонов: пресеты не рендерятся
+}
+
+func (sc templateScope) withData(data, mime string) templateScope {
+	sc.data = data
+	sc.mime = mime
+	return sc
+}
+
+func (sc templateScope) value(ref domain.TemplateRef) string {
+	switch ref.Source {
+	case domain.InputTemplateSource:
+		return sc.input
+	case domain.DataTemplateSource:
+		return sc.data
+	case domain.VarTemplateSource:
+		return sc.vars[ref.Var]
+	case domain.FileTemplateSource:
+		return sc.files[ref.File]
+	}
+
+	ref, err := sc.graph.ResolveRef(ref)
+	if err != nil {
+		return ""
+	}
+
+	output, ok := sc.outputs[ref.Node]
+	if !ok {
+		return ""
+	}
+
+	value, _ := domain.ExtractValue(output, ref.Path)
+	return value
+}
+
+func (sc templateScope) render(template string) string {
+	if sc.literal {
+		return template
+	}
+
+	return domain.Template(template).Render(sc.value)
+}
+
+// typed значение шаблона с типом: пустой шаблон - вход узла, шаблон из одной ссылки на вход или результат
+// отдает json значение как есть, остальные шаблоны рендерятся в строку
+func (sc templateScope) typed(template string) interface{} {
+	// до шаблонов поле data всегда получало вход узла, пресет не использовался
+	if template == "" || sc.literal {
+		return domain.TypedValue(sc.data, sc.mime)
+	}
+
+	ref, ok := domain.Template(template).SingleRef()
+	if !ok {
+		return sc.render(template)
+	}
+
+	switch ref.Source {
+	case domain.DataTemplateSource:
+		return domain.TypedValue(sc.data, sc.mime)
+	case domain.OutputTemplateSource:
+		resolved, err := sc.graph.ResolveRef(ref)
+		if err != nil {
+			return ""
+		}
+
+		output, ok := sc.outputs[resolved.Node]
+		if !ok {
+			return ""
+		}
+
+		value, ok := domain.LookupValue(output, sc.mimes[resolved.Node], resolved.Path)
+		if !ok {
+			return ""
+		}
+		return value
+	}
+
+	return sc.value(ref)
+}
+
+// templateFields шаблоны из пресетов полей prompt, data и file, в том числе во вложенных объектах
+func templateFields(fields map[string]domain.BodyField, presets map[string]interface{}) ([]string, error) {
+	templates := []string{}
+	for name, field := range fields {
+		preset, ok := presets[name]
+		if !ok {
+			continue
+		}
+
+		switch field.Type {
+		case domain.PromptFieldType, domain.DataFieldType, domain.FileFieldType:
+			if template, ok := preset.(string); ok && template != "" {
+				templates = append(templates, template)
+			}
+		case domain.ObjectFieldType:
+			nestedFields, err := field.NestedFields()
+			if err != nil {
+				return nil, err
+			}
+
+			nestedPresets, ok := preset.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("can't parse to nested structure")
+			}
+
+			nested, err := templateFields(nestedFields, nestedPresets)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, nested...)
+		}
+	}
+
+	return templates, nil
+}
+
+// validateTemplates проверяет ссылки шаблонов в пресетах: переменные объявлены, а шаги, цепочки и узлы,
+// на которые ссылается шаблон, выполняются раньше каждого узла графа, вызывающего ноду с этими пресетами
+func validateTemplates(script domain.Script, graph domain.Graph, usedNodes map[string]domain.Node) error {
+	instances := make(map[string][]string)
+	for _, graphNode := range graph.Nodes {
+		if graphNode.NodeId != "" {
+			instances[graphNode.NodeId] = append(instances[graphNode.NodeId], graphNode.Name)
+		}
+		for _, nodeId := range graphNode.Fallbacks {
+			instances[nodeId] = append(instances[nodeId], graphNode.Name)
+		}
+		for _, nodeId := range graphNode.Each {
+			instances[nodeId] = append(instances[nodeId], graphNode.Name)
+		}
+	}
+
+	for nodeId, presets := range script.BodyPresets {
+		templates, err := templateFields(usedNodes[nodeId].Body, presets)
+		if err != nil {
+			return fmt.Errorf("node %s: %s", nodeId, err.Error())
+		}
+
+		for _, template := range templates {
+			refs, err := domain.Template(template).Refs()
+			if err != nil {
+				return fmt.Errorf("node %s: %s", nodeId, err.Error())
+			}
+
+			for _, ref := range refs {
+				if ref.Source == domain.VarTemplateSource {
+					if _, ok := script.Options.Vars[ref.Var]; !ok {
+						return fmt.Errorf("node %s: variable %s is not declared", nodeId, ref.Var)
+					}
+					continue
+				}
+
+				if ref.Source != domain.OutputTemplateSource {
+					continue
+				}
+
+				resolved, err := graph.ResolveRef(ref)
+				if err != nil {
+					return fmt.Errorf("node %s: %s", nodeId, err.Error())
+				}
+
+				for _, instance := range instances[nodeId] {
+					if !graph.IsAncestor(resolved.Node, instance) {
+						return fmt.Errorf("node %s: reference %s is not executed before %s", nodeId, ref.Expr, instance)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
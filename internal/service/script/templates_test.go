@@ -0,0 +1,43 @@
+package script
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestTemplateScopeLiteral(t *testing.T) {
+	tests := []struct {
+		name      string
+		literal   bool
+		template  string
+		wantText  string
+		wantTyped interface{}
+	}{
+		{name: "template", template: "{{input}}: {{vars.lang}}", wantText: "in: ru", wantTyped: "in: ru"},
+		{name: "escaped template", template: `\{{input}}`, wantText: "{{input}}", wantTyped: "{{input}}"},
+		{name: "data ref keeps type", template: "{{data}}", wantText: `{"a":1}`, wantTyped: map[string]interface{}{"a": json.Number("1")}},
+		{name: "script before templates", literal: true, template: "{{input}}: {{vars.lang}}", wantText: "{{input}}: {{vars.lang}}", wantTyped: map[string]interface{}{"a": json.Number("1")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := templateScope{
+				input:   "in",
+				data:    `{"a":1}`,
+				mime:    domain.JsonContentType,
+				vars:    map[string]string{"lang": "ru"},
+				literal: tt.literal,
+			}
+
+			if got := scope.render(tt.template); got != tt.wantText {
+				t.Fatalf("render(%q) = %q, want %q", tt.template, got, tt.wantText)
+			}
+			if got := scope.typed(tt.template); !reflect.DeepEqual(got, tt.wantTyped) {
+				t.Fatalf("typed(%q) = %#v, want %#v", tt.template, got, tt.wantTyped)
+			}
+		})
+	}
+}
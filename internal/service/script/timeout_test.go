@@ -0,0 +1,127 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+// newBlockingUpstream нода, которая не отвечает, пока клиент не оборвет запрос
+func newBlockingUpstream(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// сервер замечает обрыв соединения, только когда тело запроса прочитано
+		_, _ = io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestCallNodeTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := newBlockingUpstream(t, &calls)
+
+	tests := []struct {
+		name         string
+		timeoutMs    int64
+		policy       domain.RetryPolicy
+		wantAttempts int
+	}{
+		{name: "node timeout", timeoutMs: 20, wantAttempts: 1},
+		{name: "each attempt has its own timeout", timeoutMs: 20, wantAttempts: 2, policy: domain.RetryPolicy{
+			MaxAttempts: 2, BackoffBaseMs: 1, RetryErrors: []domain.RetryErrorKind{domain.RetryOnTimeout},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls.Store(0)
+			h := newTestNodeHandler(t)
+			node := domain.Node{Id: "node", Name: "node", Url: srv.URL, Method: http.MethodPost, TimeoutMs: tt.timeoutMs, RetryPolicy: tt.policy}
+
+			attempts := 0
+			startedAt := time.Now()
+			_, err := h.callNode(context.Background(), node, nil, nodeRequest{body: []byte(`{}`), contentType: domain.JsonContentType},
+				func(int, nodeResponse, error) { attempts++ })
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("callNode() error = %v, want deadline exceeded", err)
+			}
+			if elapsed := time.Since(startedAt); elapsed > time.Second {
+				t.Fatalf("callNode() took %s, want the node timeout instead of the default", elapsed)
+			}
+			if attempts != tt.wantAttempts {
+				t.Fatalf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestCallNodeCanceled(t *testing.T) {
+	var calls atomic.Int32
+	srv := newBlockingUpstream(t, &calls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	h := newTestNodeHandler(t)
+	// повторы по таймауту не должны продолжаться после отмены запуска
+	node := domain.Node{Id: "node", Name: "node", Url: srv.URL, Method: http.MethodPost, RetryPolicy: domain.RetryPolicy{
+		MaxAttempts: 3, BackoffBaseMs: 1, RetryErrors: []domain.RetryErrorKind{domain.RetryOnTimeout, domain.RetryOnConnection},
+	}}
+
+	startedAt := time.Now()
+	_, err := h.callNode(ctx, node, nil, nodeRequest{body: []byte(`{}`), contentType: domain.JsonContentType}, func(int, nodeResponse, error) {})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("callNode() error = %v, want canceled", err)
+	}
+	if elapsed := time.Since(startedAt); elapsed > time.Second {
+		t.Fatalf("callNode() took %s after cancel", elapsed)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("upstream calls = %d, want 1", got)
+	}
+}
+
+func TestRunGraphCancelsOnFailure(t *testing.T) {
+	var calls atomic.Int32
+	slow := newBlockingUpstream(t, &calls)
+	upstream := newTestUpstream(t)
+	nodes, presets := testGraphNodes(upstream, "failing")
+	nodes["slow"] = domain.Node{Id: "slow", Name: "slow", Url: slow.URL, Method: http.MethodPost}
+
+	script := domain.Script{
+		BodyPresets: presets,
+		Graph: domain.Graph{Output: "join", Nodes: []domain.GraphNode{
+			{Name: "slow", NodeId: "slow"},
+			{Name: "failing", NodeId: "failing"},
+			{Name: "join", Kind: domain.JoinGraphNodeKind, Inputs: []string{"slow", "failing"}},
+		}},
+	}
+	s := &service{nodeHandler: newTestNodeHandler(t)}
+
+	// упавший узел отменяет соседний, который иначе ждал бы таймаута ноды
+	startedAt := time.Now()
+	_, _, err := runTestGraph(t, context.Background(), s, script, nodes, "fail")
+	if err == nil {
+		t.Fatalf("runGraph() error = nil, want node error")
+	}
+	if elapsed := time.Since(startedAt); elapsed > 2*time.Second {
+		t.Fatalf("runGraph() took %s, want the slow node to be canceled", elapsed)
+	}
+}
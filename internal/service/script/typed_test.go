@@ -0,0 +1,76 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/warehouse/ai-service/internal/domain"
+)
+
+func TestRunGraphTypedValues(t *testing.T) {
+	var received []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(domain.HeaderContentType, domain.JsonContentType)
+		if r.URL.Path == "/source" {
+			_, _ = io.WriteString(w, `{"out":{"items":[1,{"b":true}],"count":2,"title":"t"}}`)
+			return
+		}
+
+		received, _ = io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, `{"out":"ok"}`)
+	}))
+	defer srv.Close()
+
+	nodes := map[string]domain.Node{
+		"source": {Id: "source", Name: "source", Url: srv.URL + "/source", Method: http.MethodPost, ResponseDirection: "out"},
+		"target": {Id: "target", Name: "target", Url: srv.URL + "/target", Method: http.MethodPost, ResponseDirection: "out", Body: map[string]domain.BodyField{
+			"items":  {Type: domain.DataFieldType},
+			"count":  {Type: domain.DataFieldType},
+			"whole":  {Type: domain.DataFieldType},
+			"prompt": {Type: domain.PromptFieldType},
+		}},
+	}
+	script := domain.Script{
+		Options: domain.ScriptOptions{Templates: true},
+		BodyPresets: map[string]map[string]interface{}{"target": {
+			"items":  "{{nodes.source.output.items}}",
+			"count":  "{{nodes.source.output.count}}",
+			"whole":  "",
+			"prompt": "{{nodes.source.output.title}}: {{nodes.source.output.count}}",
+		}},
+		Graph: domain.Graph{Nodes: []domain.GraphNode{
+			{Name: "source", NodeId: "source"},
+			{Name: "target", NodeId: "target", Inputs: []string{"source"}},
+		}},
+	}
+	s := &service{nodeHandler: newTestNodeHandler(t)}
+
+	outcome, events, err := runTestGraph(t, context.Background(), s, script, nodes, "x")
+	if err != nil {
+		t.Fatalf("runGraph() unexpected error: %v", err)
+	}
+
+	// ссылка целиком передает json значение, шаблон с текстом - строку
+	want := `{"count":2,"items":[1,{"b":true}],"prompt":"t: 2","whole":{"count":2,"items":[1,{"b":true}],"title":"t"}}`
+	var body map[string]interface{}
+	if err := json.Unmarshal(received, &body); err != nil {
+		t.Fatalf("target body is not json: %s", received)
+	}
+	if got, _ := json.Marshal(body); string(got) != want {
+		t.Fatalf("target body = %s, want %s", got, want)
+	}
+
+	// строка из json ответа становится текстом, а объект остается json
+	if outcome.output != "ok" || outcome.mime != domain.TextContentType {
+		t.Fatalf("outcome = %q (%s), want text", outcome.output, outcome.mime)
+	}
+	for _, event := range events {
+		if event.Type == domain.NodeResultEvent && event.GraphNode == "source" && !domain.IsJsonMime(event.Mime) {
+			t.Fatalf("source result mime = %q, want json", event.Mime)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+)
+
+// callUsage расход одного вызова ноды. Ответ из кеша или кассеты ноду не вызывал и ничего не стоил
+func callUsage(node domain.Node, res nodeResponse) domain.Usage {
+	if !node.Usage.Enabled() || res.CacheHit || res.Replayed || len(res.Body) == 0 {
+		return domain.Usage{}
+	}
+
+	if !node.Stream.Enabled() || res.StatusCode < 200 || res.StatusCode >= 300 {
+		usage, _ := node.Usage.Extract(res.Body)
+		return usage
+	}
+
+	// провайдеры присылают расход в одном из последних событий потока
+	var usage domain.Usage
+	_ = scanStream(bytes.NewReader(res.Body), node.Stream.Format, func(data string) {
+		if found, ok := node.Usage.Extract([]byte(data)); ok {
+			usage = found
+		}
+	})
+
+	return usage
+}
+
+// runUsage сумма расхода по всем вызовам нод запуска
+func runUsage(steps []domain.RunStep) domain.Usage {
+	var usage domain.Usage
+	for _, step := range steps {
+		usage = usage.Add(step.Usage)
+	}
+
+	return usage
+}
+
+// Usage расход вызовов нод за период. Пользователь видит расход только своих сценариев, админ - всех
+func (s *service) Usage(ctx context.Context, acc *domain.Account, filter domain.UsageFilter) ([]domain.UsageTotal, *errors.Error) {
+	if err := filter.GroupBy.Validate(); err != nil {
+		return nil, errors.WD(errors.ValidationFailed, err)
+	}
+	if !filter.From.Before(filter.To) {
+		return nil, errors.WD(errors.ValidationFailed, fmt.Errorf("from should be before to"))
+	}
+
+	filter.AuthorId = ""
+	if acc.Role != domain.RoleAdmin {
+		filter.AuthorId = acc.Id
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	list, err := s.stepsRepo.UsageTotals(ctx, tx, filter.ToModel())
+	if err != nil {
+		return nil, errors.DatabaseError(err)
+	}
+
+	totals := make([]domain.UsageTotal, len(list))
+	for i, total := range list {
+		totals[i] = domain.UsageTotal{}.FromModel(total)
+	}
+
+	return totals, nil
+}
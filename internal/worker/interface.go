@@ -0,0 +1,6 @@
+package worker
+
+type Worker interface {
+	Start()
+	Stop() error
+}
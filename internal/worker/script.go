@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/broker"
+	"github.com/warehouse/ai-service/internal/config"
+	"github.com/warehouse/ai-service/internal/domain"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/service/script"
+
+	rmq "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const scriptConsumerTag = "script_worker"
+
+type scriptWorker struct {
+	log        logger.Logger
+	channel    *rmq.Channel
+	queue      string
+	runTimeout time.Duration
+
+	scriptService script.Service
+
+	wg    sync.WaitGroup
+	slots chan struct{} // ограничивает количество одновременно выполняемых запусков
+}
+
+func NewScriptWorker(
+	log logger.Logger,
+	cfg config.Worker,
+	timeouts config.Timeouts,
+	client *broker.RabbitClient,
+	scriptQueue string,
+	scriptService script.Service,
+) (Worker, error) {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	if _, err := client.DeclareDurableQueue(scriptQueue); err != nil {
+		return nil, err
+	}
+
+	// Отдельный канал, чтобы prefetch воркера не влиял на публикацию из общего канала
+	ch, err := client.Conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error while opening the worker channel %w", err)
+	}
+
+	if err := ch.Qos(concurrency, 0, false); err != nil {
+		return nil, fmt.Errorf("error while setting worker prefetch %w", err)
+	}
+
+	return &scriptWorker{
+		log:           log.Named("script_worker"),
+		channel:       ch,
+		queue:         scriptQueue,
+		runTimeout:    timeouts.RunTimeout,
+		scriptService: scriptService,
+		slots:         make(chan struct{}, concurrency),
+	}, nil
+}
+
+func (w *scriptWorker) Start() {
+	w.log.Zap().Info("Start script worker", zap.String("queue", w.queue), zap.Int("concurrency", cap(w.slots)))
+
+	deliveries, err := w.channel.Consume(w.queue, scriptConsumerTag, false, false, false, false, nil)
+	if err != nil {
+		w.log.Zap().Panic("consume script queue", zap.Error(err))
+	}
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		for delivery := range deliveries {
+			w.slots <- struct{}{}
+			w.wg.Add(1)
+			go w.handle(delivery)
+		}
+	}()
+}
+
+func (w *scriptWorker) Stop() error {
+	w.log.Zap().Info("Stop script worker")
+
+	// После отмены брокер закрывает канал доставок, дожидаемся уже взятых в работу запусков
+	if err := w.channel.Cancel(scriptConsumerTag, false); err != nil {
+		return err
+	}
+
+	w.wg.Wait()
+	return w.channel.Close()
+}
+
+func (w *scriptWorker) handle(delivery rmq.Delivery) {
+	defer w.wg.Done()
+	defer func() { <-w.slots }()
+
+	var message domain.RunMessage
+	if err := json.Unmarshal(delivery.Body, &message); err != nil {
+		w.log.Zap().Warn("malformed run message", zap.Error(err))
+		_ = delivery.Reject(false)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), w.runTimeout)
+	defer cancel()
+
+	// Сообщение подтверждается в любом случае: запуск, который не удалось начать или сохранить, сервис
+	// помечает failed. Повторная доставка после падения воркера до Ack выполнение не повторяет
+	if e := w.scriptService.Execute(ctx, message.RunId); e != nil {
+		w.log.ServiceErrorWithFields(e, zap.String("run_id", message.RunId))
+	}
+
+	_ = delivery.Ack(false)
+}
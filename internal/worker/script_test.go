@@ -0,0 +1,89 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/warehouse/ai-service/internal/pkg/errors"
+	"github.com/warehouse/ai-service/internal/pkg/logger"
+	"github.com/warehouse/ai-service/internal/service/script"
+
+	rmq "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+type testAcknowledger struct {
+	acks, nacks, rejects int
+}
+
+func (a *testAcknowledger) Ack(uint64, bool) error {
+	a.acks++
+	return nil
+}
+
+func (a *testAcknowledger) Nack(uint64, bool, bool) error {
+	a.nacks++
+	return nil
+}
+
+func (a *testAcknowledger) Reject(uint64, bool) error {
+	a.rejects++
+	return nil
+}
+
+type testScriptService struct {
+	script.Service
+
+	err      *errors.Error
+	executed []string
+}
+
+func (s *testScriptService) Execute(_ context.Context, runId string) *errors.Error {
+	s.executed = append(s.executed, runId)
+	return s.err
+}
+
+func TestScriptWorkerHandle(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		redelivered  bool
+		err          *errors.Error
+		wantExecuted int
+		wantAcks     int
+		wantRejects  int
+	}{
+		{name: "executed run is acked", body: `{"run_id":"r1"}`, wantExecuted: 1, wantAcks: 1},
+		{name: "failed run is acked, not requeued", body: `{"run_id":"r1"}`, err: errors.InternalError, wantExecuted: 1, wantAcks: 1},
+		{name: "redelivered run is handed to the service", body: `{"run_id":"r1"}`, redelivered: true, wantExecuted: 1, wantAcks: 1},
+		{name: "malformed message is rejected", body: `{`, wantRejects: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &testScriptService{err: tt.err}
+			w := &scriptWorker{
+				log:           logger.NewLogger(zap.NewNop()),
+				runTimeout:    time.Second,
+				scriptService: svc,
+				slots:         make(chan struct{}, 1),
+			}
+			ack := &testAcknowledger{}
+
+			w.slots <- struct{}{}
+			w.wg.Add(1)
+			w.handle(rmq.Delivery{Acknowledger: ack, Body: []byte(tt.body), Redelivered: tt.redelivered})
+
+			if len(svc.executed) != tt.wantExecuted {
+				t.Fatalf("Execute() calls = %d, want %d", len(svc.executed), tt.wantExecuted)
+			}
+			if ack.acks != tt.wantAcks || ack.nacks != 0 || ack.rejects != tt.wantRejects {
+				t.Fatalf("acks = %d, nacks = %d, rejects = %d, want %d, 0, %d", ack.acks, ack.nacks, ack.rejects, tt.wantAcks, tt.wantRejects)
+			}
+			if len(w.slots) != 0 {
+				t.Fatalf("slot is not released")
+			}
+		})
+	}
+}
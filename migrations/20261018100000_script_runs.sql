@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+CREATE TABLE public.script_runs (
+  id public.xid NOT NULL DEFAULT xid(),
+  script_id TEXT NOT NULL,
+  author TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL,
+  enter_data TEXT NOT NULL,
+  result TEXT NOT NULL DEFAULT '',
+  error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE public.script_runs
+ADD CONSTRAINT script_runs_pkey PRIMARY KEY (id);
+
+CREATE INDEX script_runs_author_idx ON public.script_runs (author, created_at DESC);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.script_runs;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+CREATE TABLE public.run_steps (
+  id BIGSERIAL NOT NULL,
+  run_id TEXT NOT NULL,
+  step INTEGER NOT NULL,
+  chain INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  node_id TEXT NOT NULL,
+  request_body TEXT NOT NULL,
+  request_headers JSON NOT NULL,
+  response TEXT NOT NULL,
+  output TEXT NOT NULL,
+  status_code INTEGER NOT NULL,
+  latency_ms BIGINT NOT NULL,
+  error TEXT NOT NULL,
+  started_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE public.run_steps
+ADD CONSTRAINT run_steps_pkey PRIMARY KEY (id);
+
+CREATE INDEX run_steps_run_idx ON public.run_steps (run_id);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.run_steps;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.nodes
+ADD COLUMN retry_policy JSON NOT NULL DEFAULT '{}';
+
+ALTER TABLE public.run_steps
+ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.run_steps DROP COLUMN attempt;
+ALTER TABLE public.nodes DROP COLUMN retry_policy;
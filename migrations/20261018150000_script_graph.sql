@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.script
+ADD COLUMN graph JSON NOT NULL DEFAULT '{}';
+
+ALTER TABLE public.run_steps
+ADD COLUMN graph_node TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.run_steps DROP COLUMN graph_node;
+ALTER TABLE public.script DROP COLUMN graph;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+CREATE TABLE public.cassette_entries (
+  cassette TEXT NOT NULL,
+  node_id TEXT NOT NULL,
+  request_hash TEXT NOT NULL,
+  status_code INTEGER NOT NULL,
+  headers JSON NOT NULL,
+  body BYTEA NOT NULL,
+  recorded_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE public.cassette_entries
+ADD CONSTRAINT cassette_entries_pkey PRIMARY KEY (cassette, node_id, request_hash);
+
+ALTER TABLE public.script_runs
+ADD COLUMN cassette JSON NOT NULL DEFAULT '{}';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.script_runs DROP COLUMN cassette;
+DROP TABLE public.cassette_entries;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.nodes
+ADD COLUMN rate_limit JSON NOT NULL DEFAULT '{}';
+
+CREATE TABLE public.rate_limit_buckets (
+  key TEXT NOT NULL,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE public.rate_limit_buckets
+ADD CONSTRAINT rate_limit_buckets_pkey PRIMARY KEY (key);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.rate_limit_buckets;
+ALTER TABLE public.nodes DROP COLUMN rate_limit;
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.nodes
+ADD COLUMN cache JSON NOT NULL DEFAULT '{}';
+
+ALTER TABLE public.run_steps
+ADD COLUMN cache_hit BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE public.node_response_cache (
+  key TEXT NOT NULL,
+  node_id TEXT NOT NULL,
+  status_code INTEGER NOT NULL,
+  headers JSON NOT NULL,
+  body BYTEA NOT NULL,
+  streamed TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE public.node_response_cache
+ADD CONSTRAINT node_response_cache_pkey PRIMARY KEY (key);
+
+CREATE INDEX node_response_cache_created_at_idx ON public.node_response_cache (created_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.node_response_cache;
+ALTER TABLE public.run_steps DROP COLUMN cache_hit;
+ALTER TABLE public.nodes DROP COLUMN cache;
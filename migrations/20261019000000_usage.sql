@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.nodes
+ADD COLUMN usage JSON NOT NULL DEFAULT '{}';
+
+ALTER TABLE public.run_steps
+ADD COLUMN prompt_tokens BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN completion_tokens BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+ALTER TABLE public.script_runs
+ADD COLUMN prompt_tokens BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN completion_tokens BIGINT NOT NULL DEFAULT 0,
+ADD COLUMN cost DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+CREATE INDEX run_steps_started_at_idx ON public.run_steps (started_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP INDEX public.run_steps_started_at_idx;
+ALTER TABLE public.script_runs DROP COLUMN prompt_tokens, DROP COLUMN completion_tokens, DROP COLUMN cost;
+ALTER TABLE public.run_steps DROP COLUMN prompt_tokens, DROP COLUMN completion_tokens, DROP COLUMN cost;
+ALTER TABLE public.nodes DROP COLUMN usage;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE public.cassette_entries
+ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE public.cassette_entries DROP CONSTRAINT cassette_entries_pkey;
+
+ALTER TABLE public.cassette_entries
+ADD CONSTRAINT cassette_entries_pkey PRIMARY KEY (owner, cassette, node_id, request_hash);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DELETE FROM public.cassette_entries WHERE owner <> '';
+ALTER TABLE public.cassette_entries DROP CONSTRAINT cassette_entries_pkey;
+ALTER TABLE public.cassette_entries
+ADD CONSTRAINT cassette_entries_pkey PRIMARY KEY (cassette, node_id, request_hash);
+ALTER TABLE public.cassette_entries DROP COLUMN owner;
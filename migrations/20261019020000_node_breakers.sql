@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+CREATE TABLE public.node_breakers (
+  key TEXT NOT NULL,
+  state TEXT NOT NULL,
+  failures INTEGER NOT NULL,
+  opened_at TIMESTAMPTZ NOT NULL,
+  in_flight INTEGER NOT NULL,
+  passed INTEGER NOT NULL,
+  probed_at TIMESTAMPTZ NOT NULL
+);
+
+ALTER TABLE public.node_breakers
+ADD CONSTRAINT node_breakers_pkey PRIMARY KEY (key);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.node_breakers;